@@ -0,0 +1,111 @@
+package clamav
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+)
+
+const defaultBatchConcurrency = 4
+
+// ScanMultiple scans files concurrently via multipart upload, with at most the number
+// of scans set by WithBatchConcurrency (default: 4) in flight.
+//
+// Exactly one BatchResult is sent per file, in completion order; use Index to match
+// results to inputs. Files that could not be scanned, including those the server
+// returned an ERROR verdict for and those skipped because ctx was canceled, carry a
+// typed *Error in Err instead of a Result.
+// The channel is buffered for all results and closed when the batch is done, so
+// the caller may stop reading early without leaking goroutines.
+func (c *Client) ScanMultiple(ctx context.Context, files []FileInput) <-chan *BatchResult {
+	return c.scanBatch(ctx, len(files), func(i int) string { return files[i].Filename },
+		func(ctx context.Context, i int) (*ScanResult, error) {
+			return c.ScanReader(ctx, bytes.NewReader(files[i].Data), files[i].Filename)
+		})
+}
+
+// ScanMultipleCallback is like ScanMultiple but invokes fn for each result.
+// fn is called from a single goroutine. It blocks until all results have been
+// delivered and returns a timeout error if files were skipped because ctx was canceled.
+func (c *Client) ScanMultipleCallback(ctx context.Context, files []FileInput, fn func(*BatchResult)) error {
+	var err error
+	for result := range c.ScanMultiple(ctx, files) {
+		if result.skipped {
+			err = result.Err
+		}
+		fn(result)
+	}
+	return err
+}
+
+// ScanMultipleReaders is like ScanMultiple for readers. Each input is sent with
+// StreamScanReader, so inputs of known size are streamed without buffering.
+// Each reader is read by a single goroutine and is not closed.
+func (c *Client) ScanMultipleReaders(ctx context.Context, inputs []ReaderInput) <-chan *BatchResult {
+	return c.scanBatch(ctx, len(inputs), func(i int) string { return inputs[i].Filename },
+		func(ctx context.Context, i int) (*ScanResult, error) {
+			return c.StreamScanReader(ctx, inputs[i].Reader, inputs[i].Filename, inputs[i].Size)
+		})
+}
+
+// scanBatch runs scan for indices 0..n-1 on a bounded set of workers.
+func (c *Client) scanBatch(ctx context.Context, n int, name func(int) string, scan func(context.Context, int) (*ScanResult, error)) <-chan *BatchResult {
+	results := make(chan *BatchResult, n)
+
+	workers := c.batchConcurrency
+	if workers <= 0 {
+		workers = defaultBatchConcurrency
+	}
+	if workers > n {
+		workers = n
+	}
+
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				res := &BatchResult{Index: i, Filename: name(i)}
+				if res.Err = batchContextError(ctx); res.Err != nil {
+					res.skipped = true
+				} else if result, err := scan(ctx, i); err != nil {
+					res.Err = asError(err)
+				} else if res.Err = result.Err(); res.Err == nil {
+					res.Result = result
+				}
+				results <- res
+			}
+		}()
+	}
+
+	go func() {
+		for i := 0; i < n; i++ {
+			indices <- i
+		}
+		close(indices)
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
+
+// batchContextError returns a timeout error if ctx is done.
+func batchContextError(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return NewTimeoutError("batch canceled", err)
+	}
+	return nil
+}
+
+// asError returns err as a *Error, wrapping errors from other sources as service errors.
+func asError(err error) error {
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+	return NewServiceError("scan failed", 0, err)
+}
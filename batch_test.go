@@ -0,0 +1,246 @@
+package clamav
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DevHatRo/clamav-api-sdk-go/internal/testutil"
+)
+
+func TestScanMultiple(t *testing.T) {
+	t.Run("results keep input index", func(t *testing.T) {
+		srv := testutil.NewMockServer(map[string]http.HandlerFunc{
+			"/api/scan": testutil.ScanHandler(func(data []byte, filename string) (int, interface{}) {
+				if strings.Contains(string(data), "EICAR") {
+					return http.StatusOK, testutil.InfectedScanResponse()
+				}
+				return http.StatusOK, testutil.CleanScanResponse()
+			}),
+		})
+		defer srv.Close()
+
+		client := mustNewClient(t, srv.URL)
+		defer func() { _ = client.Close() }()
+
+		files := []FileInput{
+			{Data: []byte("clean 0"), Filename: "a.txt"},
+			{Data: []byte("EICAR 1"), Filename: "b.txt"},
+			{Data: []byte("clean 2"), Filename: "c.txt"},
+		}
+
+		var results []*BatchResult
+		for r := range client.ScanMultiple(context.Background(), files) {
+			results = append(results, r)
+		}
+		sort.Slice(results, func(i, j int) bool { return results[i].Index < results[j].Index })
+
+		if len(results) != 3 {
+			t.Fatalf("got %d results, want 3", len(results))
+		}
+		for i, r := range results {
+			if r.Index != i || r.Filename != files[i].Filename {
+				t.Errorf("result %d = {Index: %d, Filename: %q}", i, r.Index, r.Filename)
+			}
+			if r.Err != nil {
+				t.Errorf("result %d: unexpected error: %v", i, r.Err)
+			}
+		}
+		if !results[1].Result.IsInfected() {
+			t.Errorf("expected b.txt infected, got %q", results[1].Result.Status)
+		}
+	})
+
+	t.Run("error verdicts are typed", func(t *testing.T) {
+		srv := testutil.NewMockServer(map[string]http.HandlerFunc{
+			"/api/scan": testutil.ScanHandler(func(data []byte, filename string) (int, interface{}) {
+				return http.StatusOK, map[string]interface{}{"status": "ERROR", "message": "Can't allocate memory"}
+			}),
+		})
+		defer srv.Close()
+
+		client := mustNewClient(t, srv.URL)
+		defer func() { _ = client.Close() }()
+
+		for r := range client.ScanMultiple(context.Background(), []FileInput{{Data: []byte("x"), Filename: "a.txt"}}) {
+			if r.Result != nil || !IsScanError(r.Err) {
+				t.Errorf("result = %+v, want a scan error and nil Result", r)
+			}
+		}
+	})
+
+	t.Run("per-file errors are typed", func(t *testing.T) {
+		srv := testutil.NewMockServer(map[string]http.HandlerFunc{
+			"/api/scan": testutil.ScanHandler(func(data []byte, filename string) (int, interface{}) {
+				if filename == "big.bin" {
+					return http.StatusRequestEntityTooLarge, map[string]string{"message": "File too large"}
+				}
+				return http.StatusOK, testutil.CleanScanResponse()
+			}),
+		})
+		defer srv.Close()
+
+		client := mustNewClient(t, srv.URL)
+		defer func() { _ = client.Close() }()
+
+		files := []FileInput{
+			{Data: []byte("ok"), Filename: "ok.txt"},
+			{Data: []byte("too big"), Filename: "big.bin"},
+		}
+
+		var failed *BatchResult
+		for r := range client.ScanMultiple(context.Background(), files) {
+			if r.Err != nil {
+				failed = r
+			}
+		}
+		if failed == nil {
+			t.Fatal("expected a failed result")
+		}
+		if failed.Index != 1 || failed.Result != nil {
+			t.Errorf("failed = %+v, want index 1 with nil Result", failed)
+		}
+		if !IsValidationError(failed.Err) {
+			t.Errorf("expected validation error, got: %v", failed.Err)
+		}
+	})
+
+	t.Run("respects concurrency limit", func(t *testing.T) {
+		var inFlight, peak int32
+		srv := testutil.NewMockServer(map[string]http.HandlerFunc{
+			"/api/scan": testutil.ScanHandler(func(data []byte, filename string) (int, interface{}) {
+				n := atomic.AddInt32(&inFlight, 1)
+				for {
+					p := atomic.LoadInt32(&peak)
+					if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				atomic.AddInt32(&inFlight, -1)
+				return http.StatusOK, testutil.CleanScanResponse()
+			}),
+		})
+		defer srv.Close()
+
+		client := mustNewClient(t, srv.URL, WithBatchConcurrency(2))
+		defer func() { _ = client.Close() }()
+
+		files := make([]FileInput, 8)
+		for i := range files {
+			files[i] = FileInput{Data: []byte("data"), Filename: fmt.Sprintf("f%d.txt", i)}
+		}
+
+		count := 0
+		err := client.ScanMultipleCallback(context.Background(), files, func(r *BatchResult) {
+			count++
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if count != 8 {
+			t.Errorf("count = %d, want 8", count)
+		}
+		if peak > 2 {
+			t.Errorf("peak concurrency = %d, want <= 2", peak)
+		}
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		client := mustNewClient(t, "http://localhost:6000")
+		defer func() { _ = client.Close() }()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		count := 0
+		err := client.ScanMultipleCallback(ctx, []FileInput{{Data: []byte("x")}, {Data: []byte("y")}}, func(r *BatchResult) {
+			count++
+			if !IsTimeoutError(r.Err) {
+				t.Errorf("expected timeout error, got: %v", r.Err)
+			}
+		})
+		if !IsTimeoutError(err) {
+			t.Errorf("expected timeout error, got: %v", err)
+		}
+		if count != 2 {
+			t.Errorf("count = %d, want 2", count)
+		}
+	})
+
+	t.Run("canceled after completion", func(t *testing.T) {
+		srv := testutil.NewMockServer(map[string]http.HandlerFunc{
+			"/api/scan": testutil.ScanHandler(func(data []byte, filename string) (int, interface{}) {
+				return http.StatusOK, testutil.CleanScanResponse()
+			}),
+		})
+		defer srv.Close()
+
+		client := mustNewClient(t, srv.URL)
+		defer func() { _ = client.Close() }()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		files := []FileInput{{Data: []byte("x")}, {Data: []byte("y")}}
+		count := 0
+		err := client.ScanMultipleCallback(ctx, files, func(r *BatchResult) {
+			// Cancel once the last result arrived: no file is skipped.
+			if count++; count == len(files) {
+				cancel()
+			}
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("empty batch", func(t *testing.T) {
+		client := mustNewClient(t, "http://localhost:6000")
+		defer func() { _ = client.Close() }()
+
+		for r := range client.ScanMultiple(context.Background(), nil) {
+			t.Errorf("unexpected result: %+v", r)
+		}
+	})
+}
+
+func TestScanMultipleReaders(t *testing.T) {
+	var streamed, multipart int32
+	srv := testutil.NewMockServer(map[string]http.HandlerFunc{
+		"/api/stream-scan": testutil.ScanHandler(func(data []byte, filename string) (int, interface{}) {
+			atomic.AddInt32(&streamed, 1)
+			return http.StatusOK, testutil.CleanScanResponse()
+		}),
+		"/api/scan": testutil.ScanHandler(func(data []byte, filename string) (int, interface{}) {
+			atomic.AddInt32(&multipart, 1)
+			return http.StatusOK, testutil.CleanScanResponse()
+		}),
+	})
+	defer srv.Close()
+
+	client := mustNewClient(t, srv.URL)
+	defer func() { _ = client.Close() }()
+
+	inputs := []ReaderInput{
+		{Reader: strings.NewReader("known"), Filename: "known.txt", Size: 5},
+		{Reader: strings.NewReader("unknown"), Filename: "unknown.txt", Size: UnknownSize},
+	}
+
+	count := 0
+	for r := range client.ScanMultipleReaders(context.Background(), inputs) {
+		if r.Err != nil {
+			t.Errorf("%s: unexpected error: %v", r.Filename, r.Err)
+		}
+		count++
+	}
+	if count != 2 {
+		t.Errorf("count = %d, want 2", count)
+	}
+	if streamed != 2 || multipart != 0 {
+		t.Errorf("streamed = %d, multipart = %d, want all streamed", streamed, multipart)
+	}
+}
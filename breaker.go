@@ -0,0 +1,254 @@
+package clamav
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBreakerFailureRatio = 0.5
+	defaultBreakerMinRequests  = 10
+	defaultBreakerWindow       = 30 * time.Second
+	defaultBreakerCooldown     = 10 * time.Second
+)
+
+// CircuitState is the state of a CircuitBreaker.
+type CircuitState int
+
+const (
+	// CircuitClosed lets calls through and counts their failures.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects calls immediately with a circuit-open error.
+	CircuitOpen
+	// CircuitHalfOpen is the state while a HealthCheck probe decides whether to close the circuit.
+	CircuitHalfOpen
+)
+
+// String returns the lowercase name of the state.
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreakerConfig configures a CircuitBreaker.
+// Zero fields are replaced with defaults.
+type CircuitBreakerConfig struct {
+	// FailureRatio is the fraction of failed calls within a window that opens the circuit (default: 0.5).
+	FailureRatio float64
+	// MinRequests is the number of calls a window must contain before the ratio is evaluated (default: 10).
+	MinRequests int
+	// Window is the length of the counting window; counts reset when it elapses (default: 30s).
+	Window time.Duration
+	// Cooldown is how long the circuit stays open before a HealthCheck probe is attempted (default: 10s).
+	Cooldown time.Duration
+	// IsFailure reports whether an error counts as a failure. If nil, IsBreakerFailure is used.
+	IsFailure func(error) bool
+	// OnStateChange, if set, is called after every state transition.
+	// It runs while the breaker's lock is held and must not call back into the breaker.
+	OnStateChange func(from, to CircuitState)
+}
+
+// CircuitBreaker wraps a Scanner and fails fast with a circuit-open error while the
+// ClamAV endpoint is failing, instead of letting every call wait for its timeout.
+//
+// After Cooldown, the next call runs HealthCheck on the wrapped scanner as a probe
+// (half-open state): a healthy response closes the circuit and the call proceeds,
+// anything else reopens it. A probe canceled by its caller reopens the circuit without
+// restarting the cooldown. It is safe for concurrent use.
+type CircuitBreaker struct {
+	scanner Scanner
+	cfg     CircuitBreakerConfig
+	now     func() time.Time
+
+	mu          sync.Mutex
+	state       CircuitState
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+}
+
+var _ Scanner = (*CircuitBreaker)(nil)
+
+// NewCircuitBreaker returns a CircuitBreaker that guards s.
+func NewCircuitBreaker(s Scanner, cfg CircuitBreakerConfig) *CircuitBreaker {
+	if cfg.FailureRatio <= 0 || cfg.FailureRatio > 1 {
+		cfg.FailureRatio = defaultBreakerFailureRatio
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = defaultBreakerMinRequests
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = defaultBreakerWindow
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = defaultBreakerCooldown
+	}
+	if cfg.IsFailure == nil {
+		cfg.IsFailure = IsBreakerFailure
+	}
+
+	return &CircuitBreaker{
+		scanner: s,
+		cfg:     cfg,
+		now:     time.Now,
+	}
+}
+
+// IsBreakerFailure reports whether err indicates an unhealthy endpoint: connection,
+// timeout and service errors count, while validation errors and caller cancellation do not.
+func IsBreakerFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	return IsConnectionError(err) || IsTimeoutError(err) || IsServiceError(err)
+}
+
+// State returns the current state of the circuit.
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// HealthCheck checks if the ClamAV service is healthy, unless the circuit is open.
+func (b *CircuitBreaker) HealthCheck(ctx context.Context) (*HealthCheckResult, error) {
+	return guard(ctx, b, func() (*HealthCheckResult, error) { return b.scanner.HealthCheck(ctx) })
+}
+
+// Version returns the ClamAV API server version info, unless the circuit is open.
+func (b *CircuitBreaker) Version(ctx context.Context) (*VersionResult, error) {
+	return guard(ctx, b, func() (*VersionResult, error) { return b.scanner.Version(ctx) })
+}
+
+// ScanFile scans data with the wrapped scanner, unless the circuit is open.
+func (b *CircuitBreaker) ScanFile(ctx context.Context, data []byte, filename string) (*ScanResult, error) {
+	return guard(ctx, b, func() (*ScanResult, error) { return b.scanner.ScanFile(ctx, data, filename) })
+}
+
+// ScanReader scans r with the wrapped scanner, unless the circuit is open.
+func (b *CircuitBreaker) ScanReader(ctx context.Context, r io.Reader, filename string) (*ScanResult, error) {
+	return guard(ctx, b, func() (*ScanResult, error) { return b.scanner.ScanReader(ctx, r, filename) })
+}
+
+// ScanFilePath scans a file from disk with the wrapped scanner, unless the circuit is open.
+func (b *CircuitBreaker) ScanFilePath(ctx context.Context, filePath string) (*ScanResult, error) {
+	return guard(ctx, b, func() (*ScanResult, error) { return b.scanner.ScanFilePath(ctx, filePath) })
+}
+
+// StreamScanReader streams r to the wrapped scanner, unless the circuit is open.
+func (b *CircuitBreaker) StreamScanReader(ctx context.Context, r io.Reader, filename string, size int64) (*ScanResult, error) {
+	return guard(ctx, b, func() (*ScanResult, error) { return b.scanner.StreamScanReader(ctx, r, filename, size) })
+}
+
+// Close closes the wrapped scanner.
+func (b *CircuitBreaker) Close() error {
+	return b.scanner.Close()
+}
+
+// guard runs fn if the circuit allows it and records the outcome.
+func guard[T any](ctx context.Context, b *CircuitBreaker, fn func() (T, error)) (T, error) {
+	var zero T
+	if err := b.allow(ctx); err != nil {
+		return zero, err
+	}
+
+	result, err := fn()
+	b.record(err)
+	return result, err
+}
+
+// allow returns a circuit-open error if the call must be rejected. When the cooldown
+// has elapsed, the calling goroutine runs the half-open probe.
+func (b *CircuitBreaker) allow(ctx context.Context) error {
+	b.mu.Lock()
+	switch b.state {
+	case CircuitClosed:
+		b.mu.Unlock()
+		return nil
+	case CircuitHalfOpen:
+		b.mu.Unlock()
+		return NewCircuitOpenError("circuit breaker is half-open, probe in progress")
+	}
+
+	if b.now().Sub(b.openedAt) < b.cfg.Cooldown {
+		b.mu.Unlock()
+		return NewCircuitOpenError("circuit breaker is open")
+	}
+	b.setState(CircuitHalfOpen)
+	b.mu.Unlock()
+
+	health, err := b.scanner.HealthCheck(ctx)
+	healthy := err == nil && health.Healthy
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !healthy && ctx.Err() != nil {
+		// The caller gave up, which says nothing about the endpoint: reopen without
+		// restarting the cooldown, so that the next call probes again.
+		b.setState(CircuitOpen)
+		return NewTimeoutError("health check probe canceled", ctx.Err())
+	}
+	if !healthy {
+		b.trip()
+		return NewCircuitOpenError("circuit breaker is open, health check probe failed")
+	}
+	b.reset()
+	b.setState(CircuitClosed)
+	return nil
+}
+
+// record counts the outcome of a call and opens the circuit when the failure ratio is reached.
+func (b *CircuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != CircuitClosed {
+		return
+	}
+	if b.now().Sub(b.windowStart) >= b.cfg.Window {
+		b.reset()
+	}
+
+	b.requests++
+	if b.cfg.IsFailure(err) {
+		b.failures++
+	}
+
+	if b.requests >= b.cfg.MinRequests && float64(b.failures)/float64(b.requests) >= b.cfg.FailureRatio {
+		b.trip()
+	}
+}
+
+// trip opens the circuit. The caller must hold b.mu.
+func (b *CircuitBreaker) trip() {
+	b.openedAt = b.now()
+	b.setState(CircuitOpen)
+}
+
+// reset starts a new counting window. The caller must hold b.mu.
+func (b *CircuitBreaker) reset() {
+	b.windowStart = b.now()
+	b.requests = 0
+	b.failures = 0
+}
+
+// setState transitions to state and notifies OnStateChange. The caller must hold b.mu.
+func (b *CircuitBreaker) setState(state CircuitState) {
+	from := b.state
+	b.state = state
+	if from != state && b.cfg.OnStateChange != nil {
+		b.cfg.OnStateChange(from, state)
+	}
+}
@@ -0,0 +1,225 @@
+package clamav
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubScanner is a Scanner whose behavior is defined by function fields.
+// Unset scan functions return a clean result.
+type stubScanner struct {
+	mu          sync.Mutex
+	healthFunc  func() (*HealthCheckResult, error)
+	scanFunc    func(data []byte, filename string) (*ScanResult, error)
+	healthCalls int
+	scanCalls   int
+}
+
+func (s *stubScanner) HealthCheck(context.Context) (*HealthCheckResult, error) {
+	s.mu.Lock()
+	s.healthCalls++
+	s.mu.Unlock()
+	if s.healthFunc != nil {
+		return s.healthFunc()
+	}
+	return &HealthCheckResult{Healthy: true, Message: "ok"}, nil
+}
+
+func (s *stubScanner) Version(context.Context) (*VersionResult, error) {
+	return &VersionResult{Version: "test"}, nil
+}
+
+func (s *stubScanner) ScanFile(_ context.Context, data []byte, filename string) (*ScanResult, error) {
+	s.mu.Lock()
+	s.scanCalls++
+	s.mu.Unlock()
+	if s.scanFunc != nil {
+		return s.scanFunc(data, filename)
+	}
+	return &ScanResult{Status: "OK", Filename: filename}, nil
+}
+
+func (s *stubScanner) ScanReader(ctx context.Context, r io.Reader, filename string) (*ScanResult, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, NewValidationError("failed to read data", err)
+	}
+	return s.ScanFile(ctx, data, filename)
+}
+
+func (s *stubScanner) ScanFilePath(ctx context.Context, filePath string) (*ScanResult, error) {
+	return s.ScanFile(ctx, []byte(filePath), filePath)
+}
+
+func (s *stubScanner) StreamScanReader(ctx context.Context, r io.Reader, filename string, _ int64) (*ScanResult, error) {
+	return s.ScanReader(ctx, r, filename)
+}
+
+func (s *stubScanner) Close() error { return nil }
+
+func TestCircuitBreaker(t *testing.T) {
+	failing := func([]byte, string) (*ScanResult, error) {
+		return nil, NewConnectionError("connection failed", nil)
+	}
+
+	newBreaker := func(stub *stubScanner) (*CircuitBreaker, *time.Time) {
+		now := time.Unix(1000, 0)
+		b := NewCircuitBreaker(stub, CircuitBreakerConfig{
+			FailureRatio: 0.5,
+			MinRequests:  4,
+			Window:       time.Minute,
+			Cooldown:     10 * time.Second,
+		})
+		b.now = func() time.Time { return now }
+		return b, &now
+	}
+
+	t.Run("opens after failure ratio and fails fast", func(t *testing.T) {
+		stub := &stubScanner{scanFunc: failing}
+		b, _ := newBreaker(stub)
+
+		for i := 0; i < 4; i++ {
+			if _, err := b.ScanFile(context.Background(), []byte("x"), "f"); !IsConnectionError(err) {
+				t.Fatalf("call %d: expected connection error, got: %v", i, err)
+			}
+		}
+		if b.State() != CircuitOpen {
+			t.Fatalf("state = %v, want open", b.State())
+		}
+
+		_, err := b.ScanFile(context.Background(), []byte("x"), "f")
+		if !IsCircuitOpenError(err) {
+			t.Errorf("expected circuit-open error, got: %v", err)
+		}
+		if stub.scanCalls != 4 {
+			t.Errorf("scanCalls = %d, want 4", stub.scanCalls)
+		}
+	})
+
+	t.Run("stays closed below min requests and for validation errors", func(t *testing.T) {
+		stub := &stubScanner{scanFunc: func([]byte, string) (*ScanResult, error) {
+			return nil, NewValidationError("bad input", nil)
+		}}
+		b, _ := newBreaker(stub)
+
+		for i := 0; i < 10; i++ {
+			_, _ = b.ScanFile(context.Background(), []byte("x"), "f")
+		}
+		if b.State() != CircuitClosed {
+			t.Errorf("state = %v, want closed", b.State())
+		}
+	})
+
+	t.Run("window resets counts", func(t *testing.T) {
+		stub := &stubScanner{scanFunc: failing}
+		b, now := newBreaker(stub)
+
+		for i := 0; i < 3; i++ {
+			_, _ = b.ScanFile(context.Background(), []byte("x"), "f")
+		}
+		*now = now.Add(2 * time.Minute)
+		_, _ = b.ScanFile(context.Background(), []byte("x"), "f")
+		if b.State() != CircuitClosed {
+			t.Errorf("state = %v, want closed after window reset", b.State())
+		}
+	})
+
+	t.Run("half-open probe closes on healthy", func(t *testing.T) {
+		stub := &stubScanner{scanFunc: failing}
+		var transitions []string
+		b, now := newBreaker(stub)
+		b.cfg.OnStateChange = func(from, to CircuitState) {
+			transitions = append(transitions, from.String()+"->"+to.String())
+		}
+
+		for i := 0; i < 4; i++ {
+			_, _ = b.ScanFile(context.Background(), []byte("x"), "f")
+		}
+		*now = now.Add(11 * time.Second)
+		stub.scanFunc = nil
+
+		result, err := b.ScanReader(context.Background(), bytes.NewReader([]byte("x")), "f")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !result.IsClean() {
+			t.Errorf("expected clean, got status %q", result.Status)
+		}
+		if stub.healthCalls != 1 {
+			t.Errorf("healthCalls = %d, want 1", stub.healthCalls)
+		}
+
+		want := []string{"closed->open", "open->half-open", "half-open->closed"}
+		if len(transitions) != len(want) {
+			t.Fatalf("transitions = %v, want %v", transitions, want)
+		}
+		for i := range want {
+			if transitions[i] != want[i] {
+				t.Errorf("transitions[%d] = %q, want %q", i, transitions[i], want[i])
+			}
+		}
+	})
+
+	t.Run("half-open probe reopens on unhealthy", func(t *testing.T) {
+		stub := &stubScanner{
+			scanFunc:   failing,
+			healthFunc: func() (*HealthCheckResult, error) { return &HealthCheckResult{Healthy: false}, nil },
+		}
+		b, now := newBreaker(stub)
+
+		for i := 0; i < 4; i++ {
+			_, _ = b.ScanFile(context.Background(), []byte("x"), "f")
+		}
+		*now = now.Add(11 * time.Second)
+
+		_, err := b.ScanFile(context.Background(), []byte("x"), "f")
+		if !IsCircuitOpenError(err) {
+			t.Errorf("expected circuit-open error, got: %v", err)
+		}
+		if b.State() != CircuitOpen {
+			t.Errorf("state = %v, want open", b.State())
+		}
+
+		// The cooldown restarts, so the next call fails fast without probing.
+		_, _ = b.ScanFile(context.Background(), []byte("x"), "f")
+		if stub.healthCalls != 1 {
+			t.Errorf("healthCalls = %d, want 1", stub.healthCalls)
+		}
+	})
+	t.Run("canceled probe does not restart the cooldown", func(t *testing.T) {
+		stub := &stubScanner{scanFunc: failing}
+		b, now := newBreaker(stub)
+
+		for i := 0; i < 4; i++ {
+			_, _ = b.ScanFile(context.Background(), []byte("x"), "f")
+		}
+		*now = now.Add(11 * time.Second)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		stub.healthFunc = func() (*HealthCheckResult, error) {
+			cancel()
+			return nil, NewTimeoutError("request canceled", context.Canceled)
+		}
+		_, err := b.ScanFile(ctx, []byte("x"), "f")
+		if !IsTimeoutError(err) || !errors.Is(err, context.Canceled) {
+			t.Errorf("expected timeout error wrapping context.Canceled, got: %v", err)
+		}
+		if b.State() != CircuitOpen {
+			t.Errorf("state = %v, want open", b.State())
+		}
+
+		// The next call probes again right away and closes the circuit.
+		stub.healthFunc, stub.scanFunc = nil, nil
+		if _, err := b.ScanFile(context.Background(), []byte("x"), "f"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if stub.healthCalls != 2 || b.State() != CircuitClosed {
+			t.Errorf("healthCalls = %d, state = %v, want 2 and closed", stub.healthCalls, b.State())
+		}
+	})
+}
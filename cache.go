@@ -0,0 +1,190 @@
+package clamav
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/DevHatRo/clamav-api-sdk-go/internal/readerutil"
+)
+
+const defaultCacheTTL = time.Hour
+
+// Cache stores scan verdicts keyed by the hex SHA-256 of the scanned content.
+// Implementations must be safe for concurrent use.
+type Cache interface {
+	// Get returns the verdict stored under key, or false if there is none or it has expired.
+	Get(ctx context.Context, key string) (*ScanResult, bool)
+	// Set stores a verdict under key for ttl. Failures are not reported; a cache
+	// that cannot store an entry simply misses on the next Get.
+	Set(ctx context.Context, key string, result *ScanResult, ttl time.Duration)
+}
+
+// CachingScanner wraps a Scanner and serves verdicts for previously scanned content
+// from a Cache, so identical files are sent to ClamAV only once per TTL.
+//
+// Content is identified by its SHA-256. Seekable readers (including files) are hashed
+// before scanning so a cached verdict can be returned without uploading them; other
+// readers are hashed while they are streamed to the scanner and their verdict is only
+// stored. Only "OK" and "FOUND" verdicts are cached. Results served from the cache
+// have Cached set. It is safe for concurrent use if the wrapped Scanner and Cache are.
+//
+// Entries are not invalidated when the ClamAV signature database is updated, so
+// the TTL bounds how long a file that later becomes detectable is reported clean.
+type CachingScanner struct {
+	scanner Scanner
+	cache   Cache
+	ttl     time.Duration
+}
+
+var _ Scanner = (*CachingScanner)(nil)
+
+// NewCachingScanner returns a CachingScanner that caches the verdicts of s in cache
+// for ttl (default: 1h).
+func NewCachingScanner(s Scanner, cache Cache, ttl time.Duration) *CachingScanner {
+	if ttl <= 0 {
+		ttl = defaultCacheTTL
+	}
+	return &CachingScanner{scanner: s, cache: cache, ttl: ttl}
+}
+
+// HealthCheck checks if the ClamAV service is healthy.
+func (c *CachingScanner) HealthCheck(ctx context.Context) (*HealthCheckResult, error) {
+	return c.scanner.HealthCheck(ctx)
+}
+
+// Version returns the ClamAV API server version info.
+func (c *CachingScanner) Version(ctx context.Context) (*VersionResult, error) {
+	return c.scanner.Version(ctx)
+}
+
+// ScanFile returns the cached verdict for data, or scans it and caches the result.
+func (c *CachingScanner) ScanFile(ctx context.Context, data []byte, filename string) (*ScanResult, error) {
+	sum := sha256.Sum256(data)
+	key := hex.EncodeToString(sum[:])
+	if result, ok := c.lookup(ctx, key, filename); ok {
+		return result, nil
+	}
+
+	result, err := c.scanner.ScanFile(ctx, data, filename)
+	if err != nil {
+		return nil, err
+	}
+	c.store(ctx, key, result)
+	return result, nil
+}
+
+// ScanReader returns the cached verdict for the content of r, or scans it and caches the result.
+func (c *CachingScanner) ScanReader(ctx context.Context, r io.Reader, filename string) (*ScanResult, error) {
+	return c.scanReader(ctx, r, filename, UnknownSize, func(r io.Reader) (*ScanResult, error) {
+		return c.scanner.ScanReader(ctx, r, filename)
+	})
+}
+
+// ScanFilePath returns the cached verdict for the file at filePath, or scans it and
+// caches the result. On a cache miss the file is scanned with ScanReader.
+func (c *CachingScanner) ScanFilePath(ctx context.Context, filePath string) (*ScanResult, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, NewValidationError("failed to open file", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	return c.ScanReader(ctx, f, filepath.Base(filePath))
+}
+
+// StreamScanReader returns the cached verdict for the content of r, or streams it to
+// the wrapped scanner and caches the result.
+func (c *CachingScanner) StreamScanReader(ctx context.Context, r io.Reader, filename string, size int64) (*ScanResult, error) {
+	return c.scanReader(ctx, r, filename, size, func(r io.Reader) (*ScanResult, error) {
+		return c.scanner.StreamScanReader(ctx, r, filename, size)
+	})
+}
+
+// Close closes the wrapped scanner.
+func (c *CachingScanner) Close() error {
+	return c.scanner.Close()
+}
+
+// scanReader hashes r up front if it can be rewound, and otherwise while scan consumes it.
+func (c *CachingScanner) scanReader(ctx context.Context, r io.Reader, filename string, size int64, scan func(io.Reader) (*ScanResult, error)) (*ScanResult, error) {
+	if rewind, ok := readerutil.Rewinder(r); ok {
+		h := sha256.New()
+		if _, err := io.Copy(h, r); err != nil {
+			return nil, NewValidationError("failed to read data", err)
+		}
+		if err := rewind(); err != nil {
+			return nil, NewValidationError("failed to rewind reader", err)
+		}
+
+		key := hex.EncodeToString(h.Sum(nil))
+		if result, ok := c.lookup(ctx, key, filename); ok {
+			return result, nil
+		}
+		result, err := scan(r)
+		if err != nil {
+			return nil, err
+		}
+		c.store(ctx, key, result)
+		return result, nil
+	}
+
+	hr := &hashingReader{r: r, h: sha256.New()}
+	result, err := scan(hr)
+	if err != nil {
+		return nil, err
+	}
+	// A verdict for partially read content must not be cached under a hash of that part.
+	if hr.eof || (size >= 0 && hr.n == size) {
+		c.store(ctx, hex.EncodeToString(hr.h.Sum(nil)), result)
+	}
+	return result, nil
+}
+
+// lookup returns a copy of the cached verdict for key, marked as cached.
+func (c *CachingScanner) lookup(ctx context.Context, key, filename string) (*ScanResult, bool) {
+	cached, ok := c.cache.Get(ctx, key)
+	if !ok {
+		return nil, false
+	}
+	result := *cached
+	result.Cached = true
+	result.Filename = filename
+	return &result, true
+}
+
+// store caches a copy of result if it is a definitive verdict. The file name is not
+// cached: the same content may be uploaded under other names.
+func (c *CachingScanner) store(ctx context.Context, key string, result *ScanResult) {
+	if !result.IsClean() && !result.IsInfected() {
+		return
+	}
+	entry := *result
+	entry.Cached = false
+	entry.Filename = ""
+	c.cache.Set(ctx, key, &entry, c.ttl)
+}
+
+// hashingReader hashes everything read through it and records whether EOF was reached.
+type hashingReader struct {
+	r   io.Reader
+	h   hash.Hash
+	n   int64
+	eof bool
+}
+
+// Read reads from the source and feeds the bytes read to the hash.
+func (r *hashingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.h.Write(p[:n])
+	r.n += int64(n)
+	if err == io.EOF {
+		r.eof = true
+	}
+	return n, err
+}
@@ -0,0 +1,280 @@
+package clamav
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// --- Caching scanner tests ---
+
+func TestCachingScanner(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("ScanFile hit", func(t *testing.T) {
+		stub := infectedStub()
+		c := NewCachingScanner(stub, NewMemoryCache(0), time.Minute)
+
+		first, err := c.ScanFile(ctx, []byte("EICAR"), "a.com")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if first.Cached {
+			t.Error("first scan should not be cached")
+		}
+
+		second, err := c.ScanFile(ctx, []byte("EICAR"), "b.com")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !second.Cached || !second.IsInfected() || second.Filename != "b.com" {
+			t.Errorf("second = %+v, want cached FOUND for b.com", second)
+		}
+		third, err := c.ScanFile(ctx, []byte("EICAR"), "")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !third.Cached || third.Filename != "" {
+			t.Errorf("third = %+v, want cached without the name of another upload", third)
+		}
+		if stub.scanCalls != 1 {
+			t.Errorf("scanCalls = %d, want 1", stub.scanCalls)
+		}
+	})
+
+	t.Run("errors are not cached", func(t *testing.T) {
+		stub := &stubScanner{scanFunc: func(_ []byte, filename string) (*ScanResult, error) {
+			return &ScanResult{Status: "ERROR", Message: "scan failed", Filename: filename}, nil
+		}}
+		c := NewCachingScanner(stub, NewMemoryCache(0), time.Minute)
+		for i := 0; i < 2; i++ {
+			if _, err := c.ScanFile(ctx, []byte("data"), "f.txt"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if stub.scanCalls != 2 {
+			t.Errorf("scanCalls = %d, want 2", stub.scanCalls)
+		}
+	})
+
+	t.Run("seekable reader is hashed before scanning", func(t *testing.T) {
+		stub := infectedStub()
+		c := NewCachingScanner(stub, NewMemoryCache(0), time.Minute)
+		if _, err := c.ScanFile(ctx, []byte("clean"), "a.txt"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		result, err := c.StreamScanReader(ctx, strings.NewReader("clean"), "b.txt", 5)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !result.Cached {
+			t.Error("expected cached result")
+		}
+
+		result, err = c.ScanReader(ctx, strings.NewReader("other"), "c.txt")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.Cached || stub.scanCalls != 2 {
+			t.Errorf("Cached = %v, scanCalls = %d, want a fresh scan", result.Cached, stub.scanCalls)
+		}
+	})
+
+	t.Run("non-seekable reader is hashed while streaming", func(t *testing.T) {
+		stub := infectedStub()
+		c := NewCachingScanner(stub, NewMemoryCache(0), time.Minute)
+
+		result, err := c.StreamScanReader(ctx, io.MultiReader(strings.NewReader("EICAR")), "a.com", UnknownSize)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.Cached {
+			t.Error("first scan should not be cached")
+		}
+
+		result, err = c.ScanFile(ctx, []byte("EICAR"), "b.com")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !result.Cached {
+			t.Error("expected verdict stored from the streamed scan")
+		}
+	})
+
+	t.Run("partially read content is not cached", func(t *testing.T) {
+		cache := NewMemoryCache(0)
+		partial := &partialScanner{stubScanner: infectedStub()}
+		c := NewCachingScanner(partial, cache, time.Minute)
+
+		if _, err := c.ScanReader(ctx, io.MultiReader(strings.NewReader("clean data")), "a.txt"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cache.Len() != 0 {
+			t.Errorf("cache has %d entries, want 0", cache.Len())
+		}
+	})
+
+	t.Run("ScanFilePath", func(t *testing.T) {
+		stub := infectedStub()
+		c := NewCachingScanner(stub, NewMemoryCache(0), time.Minute)
+		path := filepath.Join(t.TempDir(), "eicar.com")
+		if err := os.WriteFile(path, []byte("EICAR"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 2; i++ {
+			result, err := c.ScanFilePath(ctx, path)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Cached != (i == 1) || result.Filename != "eicar.com" {
+				t.Errorf("scan %d = %+v", i, result)
+			}
+		}
+	})
+}
+
+// partialScanner reads only the first byte of readers before returning a verdict.
+type partialScanner struct {
+	*stubScanner
+}
+
+func (p *partialScanner) ScanReader(ctx context.Context, r io.Reader, filename string) (*ScanResult, error) {
+	buf := make([]byte, 1)
+	if _, err := r.Read(buf); err != nil {
+		return nil, NewValidationError("failed to read data", err)
+	}
+	return p.ScanFile(ctx, buf, filename)
+}
+
+func TestMemoryCache(t *testing.T) {
+	ctx := context.Background()
+	clean := &ScanResult{Status: "OK"}
+
+	t.Run("evicts least recently used", func(t *testing.T) {
+		m := NewMemoryCache(2)
+		m.Set(ctx, "a", clean, time.Minute)
+		m.Set(ctx, "b", clean, time.Minute)
+		m.Get(ctx, "a")
+		m.Set(ctx, "c", clean, time.Minute)
+
+		if _, ok := m.Get(ctx, "b"); ok {
+			t.Error("b should have been evicted")
+		}
+		for _, key := range []string{"a", "c"} {
+			if _, ok := m.Get(ctx, key); !ok {
+				t.Errorf("%s should be cached", key)
+			}
+		}
+	})
+
+	t.Run("expires entries", func(t *testing.T) {
+		m := NewMemoryCache(0)
+		now := time.Unix(1000, 0)
+		m.now = func() time.Time { return now }
+		m.Set(ctx, "a", clean, time.Minute)
+
+		now = now.Add(59 * time.Second)
+		if _, ok := m.Get(ctx, "a"); !ok {
+			t.Error("entry should not have expired yet")
+		}
+		now = now.Add(time.Second)
+		if _, ok := m.Get(ctx, "a"); ok {
+			t.Error("entry should have expired")
+		}
+		if m.Len() != 0 {
+			t.Errorf("Len() = %d, want 0", m.Len())
+		}
+	})
+
+	t.Run("returns copies", func(t *testing.T) {
+		m := NewMemoryCache(0)
+		m.Set(ctx, "a", &ScanResult{Status: "FOUND", Message: "Eicar"}, time.Minute)
+		got, _ := m.Get(ctx, "a")
+		got.Message = "changed"
+		if again, _ := m.Get(ctx, "a"); again.Message != "Eicar" {
+			t.Errorf("Message = %q, want %q", again.Message, "Eicar")
+		}
+	})
+}
+
+func TestDiskCache(t *testing.T) {
+	ctx := context.Background()
+	key := strings.Repeat("ab", 32)
+
+	t.Run("round trip", func(t *testing.T) {
+		d, err := NewDiskCache(filepath.Join(t.TempDir(), "cache"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		d.Set(ctx, key, &ScanResult{Status: "FOUND", Message: "Eicar", ScanTime: 0.5, Filename: "x"}, time.Minute)
+
+		got, ok := d.Get(ctx, key)
+		if !ok {
+			t.Fatal("expected a cached entry")
+		}
+		want := ScanResult{Status: "FOUND", Message: "Eicar", ScanTime: 0.5}
+		if *got != want {
+			t.Errorf("Get() = %+v, want %+v", *got, want)
+		}
+	})
+
+	t.Run("expiry and prune", func(t *testing.T) {
+		dir := t.TempDir()
+		d, err := NewDiskCache(dir)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		now := time.Unix(1000, 0)
+		d.now = func() time.Time { return now }
+		d.Set(ctx, key, &ScanResult{Status: "OK"}, time.Minute)
+		d.Set(ctx, strings.Repeat("cd", 32), &ScanResult{Status: "OK"}, time.Hour)
+
+		now = now.Add(2 * time.Minute)
+		if err := d.Prune(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		entries, _ := os.ReadDir(dir)
+		if len(entries) != 1 {
+			t.Errorf("got %d files after Prune, want 1", len(entries))
+		}
+	})
+
+	t.Run("rejects invalid keys", func(t *testing.T) {
+		dir := t.TempDir()
+		d, err := NewDiskCache(dir)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		d.Set(ctx, "../escape", &ScanResult{Status: "OK"}, time.Minute)
+		if _, ok := d.Get(ctx, "../escape"); ok {
+			t.Error("invalid key should not be cached")
+		}
+		if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "escape.json")); !os.IsNotExist(err) {
+			t.Errorf("file written outside the cache directory: %v", err)
+		}
+	})
+
+	t.Run("with caching scanner", func(t *testing.T) {
+		d, err := NewDiskCache(t.TempDir())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		stub := infectedStub()
+		c := NewCachingScanner(stub, d, time.Minute)
+		for i := 0; i < 2; i++ {
+			if _, err := c.ScanReader(ctx, bytes.NewReader([]byte("EICAR")), "a.com"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if stub.scanCalls != 1 {
+			t.Errorf("scanCalls = %d, want 1", stub.scanCalls)
+		}
+	})
+}
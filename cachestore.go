@@ -0,0 +1,208 @@
+package clamav
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultMemoryCacheSize = 10000
+
+// MemoryCache is an in-memory Cache that evicts the least recently used entry
+// once it holds its maximum number of entries.
+type MemoryCache struct {
+	maxEntries int
+	now        func() time.Time
+
+	mu    sync.Mutex
+	order *list.List // front is most recently used
+	items map[string]*list.Element
+}
+
+var _ Cache = (*MemoryCache)(nil)
+
+// memoryCacheEntry is an element of MemoryCache.order.
+type memoryCacheEntry struct {
+	key     string
+	result  ScanResult
+	expires time.Time
+}
+
+// NewMemoryCache returns a MemoryCache holding up to maxEntries verdicts (default: 10000).
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	if maxEntries <= 0 {
+		maxEntries = defaultMemoryCacheSize
+	}
+	return &MemoryCache{
+		maxEntries: maxEntries,
+		now:        time.Now,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// Get returns the verdict stored under key and marks it as recently used.
+func (m *MemoryCache) Get(_ context.Context, key string) (*ScanResult, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*memoryCacheEntry)
+	if !m.now().Before(entry.expires) {
+		m.order.Remove(el)
+		delete(m.items, key)
+		return nil, false
+	}
+
+	m.order.MoveToFront(el)
+	result := entry.result
+	return &result, true
+}
+
+// Set stores a verdict under key, evicting the least recently used entry if the cache is full.
+func (m *MemoryCache) Set(_ context.Context, key string, result *ScanResult, ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	expires := m.now().Add(ttl)
+	if el, ok := m.items[key]; ok {
+		entry := el.Value.(*memoryCacheEntry)
+		entry.result, entry.expires = *result, expires
+		m.order.MoveToFront(el)
+		return
+	}
+
+	m.items[key] = m.order.PushFront(&memoryCacheEntry{key: key, result: *result, expires: expires})
+	for m.order.Len() > m.maxEntries {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.items, oldest.Value.(*memoryCacheEntry).key)
+	}
+}
+
+// Len returns the number of entries, including expired entries not yet evicted.
+func (m *MemoryCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.order.Len()
+}
+
+// DiskCache is a Cache that stores each verdict as a JSON file in a directory,
+// so verdicts survive restarts and can be shared by processes on the same host.
+type DiskCache struct {
+	dir string
+	now func() time.Time
+}
+
+var _ Cache = (*DiskCache)(nil)
+
+// diskCacheEntry is the JSON content of a DiskCache file.
+type diskCacheEntry struct {
+	Status   ScanStatus `json:"status"`
+	Message  string     `json:"message"`
+	ScanTime float64    `json:"time"`
+	Expires  time.Time  `json:"expires"`
+}
+
+// NewDiskCache returns a DiskCache storing its entries in dir, which is created if needed.
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, NewValidationError("failed to create cache directory", err)
+	}
+	return &DiskCache{dir: dir, now: time.Now}, nil
+}
+
+// Get returns the verdict stored under key. Expired entries are removed.
+func (d *DiskCache) Get(_ context.Context, key string) (*ScanResult, bool) {
+	path, ok := d.path(key)
+	if !ok {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	var entry diskCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		_ = os.Remove(path)
+		return nil, false
+	}
+	if !d.now().Before(entry.Expires) {
+		_ = os.Remove(path)
+		return nil, false
+	}
+
+	return &ScanResult{Status: entry.Status, Message: entry.Message, ScanTime: entry.ScanTime}, true
+}
+
+// Set stores a verdict under key. The file is written to a temporary name and renamed,
+// so concurrent readers never see a partial entry.
+func (d *DiskCache) Set(_ context.Context, key string, result *ScanResult, ttl time.Duration) {
+	path, ok := d.path(key)
+	if !ok {
+		return
+	}
+	data, err := json.Marshal(diskCacheEntry{
+		Status:   result.Status,
+		Message:  result.Message,
+		ScanTime: result.ScanTime,
+		Expires:  d.now().Add(ttl),
+	})
+	if err != nil {
+		return
+	}
+
+	f, err := os.CreateTemp(d.dir, ".tmp-*")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+}
+
+// Prune removes expired and unreadable entries from the cache directory.
+func (d *DiskCache) Prune() error {
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return NewValidationError("failed to read cache directory", err)
+	}
+	for _, e := range entries {
+		key, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || e.IsDir() {
+			continue
+		}
+		// Get removes the file if it has expired or cannot be decoded.
+		d.Get(context.Background(), key)
+	}
+	return nil
+}
+
+// path returns the file of key, and false if key is not a valid hex digest.
+func (d *DiskCache) path(key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	for _, c := range key {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return "", false
+		}
+	}
+	return filepath.Join(d.dir, key+".json"), true
+}
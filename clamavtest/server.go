@@ -0,0 +1,375 @@
+// Package clamavtest provides an in-process fake of the ClamAV REST API for tests of
+// code that uses the SDK.
+//
+// The fake serves the four API endpoints the way the real service does: it reports
+// content containing the EICAR test string as infected, rejects uploads above a
+// configurable size with 413, and can be scripted to fail. Every request is recorded
+// for assertions:
+//
+//	srv := clamavtest.NewServer(clamavtest.WithMaxSize(1 << 20))
+//	defer srv.Close()
+//
+//	client := srv.Client()
+//	result, err := client.ScanFile(ctx, []byte(clamavtest.EICAR), "eicar.com")
+//	// result.Status == "FOUND", result.Message == clamavtest.EICARSignature
+//
+//	srv.FailNext(clamavtest.Failure{Path: "/api/scan", StatusCode: http.StatusBadGateway})
+//	_, err = client.ScanFile(ctx, []byte("hello"), "a.txt") // service error
+//
+//	reqs := srv.Requests() // both scans, with filenames and content
+package clamavtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	clamav "github.com/DevHatRo/clamav-api-sdk-go"
+)
+
+// EICAR is the standard antivirus test string. Content containing it is reported as
+// infected with EICARSignature.
+const EICAR = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// EICARSignature is the signature name reported for EICAR content, as by ClamAV.
+const EICARSignature = "Eicar-Test-Signature"
+
+// API endpoint paths.
+const (
+	PathHealthCheck = "/api/health-check"
+	PathVersion     = "/api/version"
+	PathScan        = "/api/scan"
+	PathStreamScan  = "/api/stream-scan"
+)
+
+const (
+	defaultVersion = "test"
+	scanTime       = 0.001
+)
+
+// Request is a request received by the fake server.
+type Request struct {
+	// Method and Path are the HTTP method and URL path.
+	Method string
+	Path   string
+	// Header is the request header.
+	Header http.Header
+	// Filename is the file name of a multipart upload.
+	Filename string
+	// Body is the scanned content: the file part of a multipart upload or the raw body
+	// of a stream scan. It is nil when the content was not read, e.g. when it was too large.
+	Body []byte
+	// StatusCode is the status of the response, or 0 if no response was sent because
+	// the connection was dropped or the client gave up.
+	StatusCode int
+}
+
+// Failure is a scripted failure for the next matching request, set with FailNext.
+type Failure struct {
+	// Path limits the failure to one endpoint, e.g. PathScan. Empty matches any endpoint.
+	Path string
+	// StatusCode is the response status (default: 500).
+	StatusCode int
+	// Message is the error message in the JSON response body.
+	Message string
+	// Delay delays the failure response, e.g. to trigger client timeouts.
+	Delay time.Duration
+	// Drop closes the connection without a response, causing a connection error. Note
+	// that net/http retries a dropped GET once by itself when the connection was reused.
+	Drop bool
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithMaxSize sets the largest scanned content accepted; larger uploads are rejected
+// with 413 Request Entity Too Large. The default is no limit.
+func WithMaxSize(n int64) Option {
+	return func(s *Server) {
+		if n > 0 {
+			s.maxSize = n
+		}
+	}
+}
+
+// WithVersion sets the version reported by /api/version (default: "test").
+func WithVersion(version string) Option {
+	return func(s *Server) {
+		if version != "" {
+			s.version = version
+		}
+	}
+}
+
+// WithSignature reports content containing pattern as infected with signature, in
+// addition to EICAR.
+func WithSignature(pattern, signature string) Option {
+	return func(s *Server) {
+		if pattern != "" {
+			s.signatures = append(s.signatures, signatureRule{pattern: []byte(pattern), name: signature})
+		}
+	}
+}
+
+// signatureRule detects content containing pattern.
+type signatureRule struct {
+	pattern []byte
+	name    string
+}
+
+// Server is a fake ClamAV REST API server. It is safe for concurrent use.
+type Server struct {
+	// URL is the base URL of the server, for clamav.NewClient.
+	URL string
+
+	srv        *httptest.Server
+	maxSize    int64
+	version    string
+	signatures []signatureRule
+
+	mu       sync.Mutex
+	healthy  bool
+	failures []Failure
+	requests []Request
+}
+
+// NewServer starts a fake server. The caller should call Close when finished.
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		version:    defaultVersion,
+		signatures: []signatureRule{{pattern: []byte(EICAR), name: EICARSignature}},
+		healthy:    true,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(PathHealthCheck, s.handle(http.MethodGet, s.healthCheck))
+	mux.HandleFunc(PathVersion, s.handle(http.MethodGet, s.versionInfo))
+	mux.HandleFunc(PathScan, s.handle(http.MethodPost, s.scan))
+	mux.HandleFunc(PathStreamScan, s.handle(http.MethodPost, s.streamScan))
+	s.srv = httptest.NewServer(mux)
+	s.URL = s.srv.URL
+	return s
+}
+
+// Close shuts the server down.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Client returns a REST client for the server.
+func (s *Server) Client(opts ...clamav.ClientOption) *clamav.Client {
+	client, err := clamav.NewClient(s.URL, opts...)
+	if err != nil {
+		// NewClient only fails for an invalid base URL, and s.URL is valid.
+		panic(fmt.Sprintf("clamavtest: %v", err))
+	}
+	return client
+}
+
+// SetHealthy sets whether /api/health-check reports the service as healthy.
+func (s *Server) SetHealthy(healthy bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.healthy = healthy
+}
+
+// FailNext makes the next request matching f.Path fail as described by f. Failures
+// are queued, so calling FailNext n times fails the next n matching requests.
+func (s *Server) FailNext(f Failure) {
+	if f.StatusCode == 0 {
+		f.StatusCode = http.StatusInternalServerError
+	}
+	if f.Message == "" {
+		f.Message = http.StatusText(f.StatusCode)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, f)
+}
+
+// Requests returns the requests received so far, in order.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Reset clears the recorded requests and pending failures, and makes the service healthy.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests, s.failures, s.healthy = nil, nil, true
+}
+
+// handler serves one endpoint and fills in the recorded request.
+type handler func(w http.ResponseWriter, r *http.Request, rec *Request)
+
+// handle wraps an endpoint handler with method checking, scripted failures and recording.
+func (s *Server) handle(method string, h handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rec := &Request{Method: r.Method, Path: r.URL.Path, Header: r.Header.Clone()}
+		rw := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			rec.StatusCode = rw.status
+			s.mu.Lock()
+			s.requests = append(s.requests, *rec)
+			s.mu.Unlock()
+		}()
+
+		if f, ok := s.nextFailure(r.URL.Path); ok {
+			s.fail(rw, r, f)
+			return
+		}
+		if r.Method != method {
+			writeJSON(rw, http.StatusMethodNotAllowed, map[string]string{"message": "method not allowed"})
+			return
+		}
+		h(rw, r, rec)
+	}
+}
+
+// nextFailure removes and returns the first scripted failure matching path.
+func (s *Server) nextFailure(path string) (Failure, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, f := range s.failures {
+		if f.Path == "" || f.Path == path {
+			s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			return f, true
+		}
+	}
+	return Failure{}, false
+}
+
+func (s *Server) fail(w *statusRecorder, r *http.Request, f Failure) {
+	if f.Delay > 0 {
+		select {
+		case <-time.After(f.Delay):
+		case <-r.Context().Done():
+			w.status = 0
+			return
+		}
+	}
+	if f.Drop {
+		if hj, ok := w.ResponseWriter.(http.Hijacker); ok {
+			if conn, _, err := hj.Hijack(); err == nil {
+				_ = conn.Close()
+				w.status = 0
+				return
+			}
+		}
+	}
+	writeJSON(w, f.StatusCode, map[string]string{"message": f.Message})
+}
+
+func (s *Server) healthCheck(w http.ResponseWriter, _ *http.Request, _ *Request) {
+	s.mu.Lock()
+	healthy := s.healthy
+	s.mu.Unlock()
+
+	if !healthy {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"message": "clamd is not responding"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"message": "ok"})
+}
+
+func (s *Server) versionInfo(w http.ResponseWriter, _ *http.Request, _ *Request) {
+	writeJSON(w, http.StatusOK, clamav.VersionResult{Version: s.version, Commit: "clamavtest", Build: "clamavtest"})
+}
+
+// scan serves multipart uploads with a single "file" part.
+func (s *Server) scan(w http.ResponseWriter, r *http.Request, rec *Request) {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": "Provide a single file"})
+		return
+	}
+
+	mr := multipart.NewReader(r.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"message": "Provide a single file"})
+			return
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+		rec.Filename = part.FileName()
+		s.scanContent(w, part, rec)
+		return
+	}
+}
+
+// streamScan serves raw bodies, with or without a Content-Length.
+func (s *Server) streamScan(w http.ResponseWriter, r *http.Request, rec *Request) {
+	if s.maxSize > 0 && r.ContentLength > s.maxSize {
+		s.tooLarge(w)
+		return
+	}
+	s.scanContent(w, r.Body, rec)
+}
+
+// scanContent reads the content to scan, enforcing the size limit, and responds
+// with the verdict.
+func (s *Server) scanContent(w http.ResponseWriter, r io.Reader, rec *Request) {
+	if s.maxSize > 0 {
+		r = io.LimitReader(r, s.maxSize+1)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": "failed to read body"})
+		return
+	}
+	if s.maxSize > 0 && int64(len(data)) > s.maxSize {
+		s.tooLarge(w)
+		return
+	}
+	rec.Body = data
+	if len(data) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": "empty file"})
+		return
+	}
+
+	result := clamav.ScanResult{Status: clamav.StatusOK, ScanTime: scanTime}
+	for _, sig := range s.signatures {
+		if bytes.Contains(data, sig.pattern) {
+			result.Status, result.Message = clamav.StatusFound, sig.name
+			break
+		}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) tooLarge(w http.ResponseWriter) {
+	msg := fmt.Sprintf("file size exceeds the maximum of %d bytes", s.maxSize)
+	writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"message": msg})
+}
+
+// statusRecorder records the response status.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,197 @@
+package clamavtest
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	clamav "github.com/DevHatRo/clamav-api-sdk-go"
+)
+
+func newTestServer(t *testing.T, opts ...Option) (*Server, *clamav.Client) {
+	t.Helper()
+	srv := NewServer(opts...)
+	t.Cleanup(srv.Close)
+	return srv, srv.Client()
+}
+
+// --- Server tests ---
+
+func TestHealthAndVersion(t *testing.T) {
+	srv, client := newTestServer(t, WithVersion("1.2.3"))
+	ctx := context.Background()
+
+	health, err := client.HealthCheck(ctx)
+	if err != nil || !health.Healthy {
+		t.Errorf("HealthCheck = %+v, %v", health, err)
+	}
+
+	srv.SetHealthy(false)
+	health, err = client.HealthCheck(ctx)
+	if err != nil || health.Healthy {
+		t.Errorf("HealthCheck = %+v, %v, want unhealthy", health, err)
+	}
+
+	version, err := client.Version(ctx)
+	if err != nil || version.Version != "1.2.3" {
+		t.Errorf("Version = %+v, %v", version, err)
+	}
+}
+
+func TestScan(t *testing.T) {
+	srv, client := newTestServer(t, WithSignature("MALWARE", "Test.Malware"))
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		stream  bool
+		content string
+		status  clamav.ScanStatus
+		virus   string
+	}{
+		{"multipart clean", false, "hello", "OK", ""},
+		{"multipart EICAR", false, "x" + EICAR, "FOUND", EICARSignature},
+		{"stream clean", true, "hello", "OK", ""},
+		{"stream custom signature", true, "MALWARE", "FOUND", "Test.Malware"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv.Reset()
+			data := []byte(tt.content)
+			var result *clamav.ScanResult
+			var err error
+			path := PathScan
+			if tt.stream {
+				path = PathStreamScan
+				result, err = client.StreamScan(ctx, bytes.NewReader(data), int64(len(data)))
+			} else {
+				result, err = client.ScanFile(ctx, data, "a.txt")
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Status != tt.status || result.Message != tt.virus {
+				t.Errorf("result = %+v, want %s %q", result, tt.status, tt.virus)
+			}
+
+			reqs := srv.Requests()
+			if len(reqs) != 1 {
+				t.Fatalf("got %d requests, want 1", len(reqs))
+			}
+			if r := reqs[0]; r.Method != http.MethodPost || r.Path != path || string(r.Body) != tt.content || r.StatusCode != http.StatusOK {
+				t.Errorf("request = %+v", r)
+			}
+		})
+	}
+
+	t.Run("filename recorded", func(t *testing.T) {
+		srv.Reset()
+		if _, err := client.ScanFile(ctx, []byte("hello"), "report.pdf"); err != nil {
+			t.Fatal(err)
+		}
+		if got := srv.Requests()[0].Filename; got != "report.pdf" {
+			t.Errorf("Filename = %q, want %q", got, "report.pdf")
+		}
+	})
+
+	t.Run("unknown size stream", func(t *testing.T) {
+		srv.Reset()
+		chunked := srv.Client(clamav.WithChunkedStreamScan(true))
+		result, err := chunked.StreamScanReader(ctx, strings.NewReader(EICAR), "eicar.com", clamav.UnknownSize)
+		if err != nil || !result.IsInfected() {
+			t.Errorf("result = %+v, %v", result, err)
+		}
+	})
+}
+
+func TestMaxSize(t *testing.T) {
+	srv, client := newTestServer(t, WithMaxSize(10))
+	ctx := context.Background()
+	big := bytes.Repeat([]byte("x"), 100)
+
+	if _, err := client.ScanFile(ctx, big, "big.bin"); !clamav.IsValidationError(err) {
+		t.Errorf("multipart: expected validation error, got: %v", err)
+	}
+	if _, err := client.StreamScan(ctx, bytes.NewReader(big), int64(len(big))); !clamav.IsValidationError(err) {
+		t.Errorf("stream: expected validation error, got: %v", err)
+	}
+	chunked := srv.Client(clamav.WithChunkedStreamScan(true))
+	if _, err := chunked.StreamScanReader(ctx, bytes.NewReader(big), "big.bin", clamav.UnknownSize); !clamav.IsValidationError(err) {
+		t.Errorf("chunked stream: expected validation error, got: %v", err)
+	}
+	for _, r := range srv.Requests() {
+		if r.StatusCode != http.StatusRequestEntityTooLarge || r.Body != nil {
+			t.Errorf("%s: status = %d, body recorded = %v", r.Path, r.StatusCode, r.Body != nil)
+		}
+	}
+
+	if result, err := client.ScanFile(ctx, []byte("small"), "small.txt"); err != nil || !result.IsClean() {
+		t.Errorf("result = %+v, %v", result, err)
+	}
+}
+
+func TestFailNext(t *testing.T) {
+	srv, client := newTestServer(t)
+	ctx := context.Background()
+
+	t.Run("status", func(t *testing.T) {
+		srv.FailNext(Failure{Path: PathScan, StatusCode: http.StatusBadGateway, Message: "clamd down"})
+
+		// Other endpoints are not affected.
+		if _, err := client.Version(ctx); err != nil {
+			t.Fatalf("Version: %v", err)
+		}
+		_, err := client.ScanFile(ctx, []byte("hello"), "a.txt")
+		if !clamav.IsServiceError(err) || !strings.Contains(err.Error(), "clamd down") {
+			t.Errorf("expected service error, got: %v", err)
+		}
+		// Only the next request fails.
+		if _, err := client.ScanFile(ctx, []byte("hello"), "a.txt"); err != nil {
+			t.Errorf("unexpected error after the scripted failure: %v", err)
+		}
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		srv.FailNext(Failure{Drop: true})
+		if _, err := client.ScanFile(ctx, []byte("hello"), "a.txt"); !clamav.IsConnectionError(err) {
+			t.Errorf("expected connection error, got: %v", err)
+		}
+	})
+
+	t.Run("delay", func(t *testing.T) {
+		srv.Reset()
+		srv.FailNext(Failure{Delay: time.Second})
+		slow := srv.Client(clamav.WithTimeout(50 * time.Millisecond))
+		if _, err := slow.ScanFile(ctx, []byte("hello"), "a.txt"); !clamav.IsTimeoutError(err) {
+			t.Errorf("expected timeout error, got: %v", err)
+		}
+	})
+
+	t.Run("retried by the client", func(t *testing.T) {
+		srv.Reset()
+		srv.FailNext(Failure{StatusCode: http.StatusServiceUnavailable})
+		retrying := srv.Client(clamav.WithRetryPolicy(clamav.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}))
+		if _, err := retrying.ScanFile(ctx, []byte("hello"), "a.txt"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		reqs := srv.Requests()
+		if len(reqs) != 2 || reqs[0].StatusCode != http.StatusServiceUnavailable || reqs[1].StatusCode != http.StatusOK {
+			t.Errorf("requests = %+v", reqs)
+		}
+	})
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	srv, _ := newTestServer(t)
+	resp, err := http.Post(srv.URL+PathVersion, "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusMethodNotAllowed)
+	}
+}
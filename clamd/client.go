@@ -0,0 +1,290 @@
+// Package clamd provides a client that talks the clamd socket protocol directly, for
+// deployments that run clamd without the ClamAV REST or gRPC API.
+//
+// The client connects over TCP or a Unix socket and uses the PING, VERSION, STATS and
+// INSTREAM commands. It returns the same clamav.ScanResult and *clamav.Error types as
+// the REST and gRPC clients and implements clamav.Scanner, so code written against
+// either of them works unchanged:
+//
+//	client, err := clamd.NewClient("unix:///run/clamav/clamd.ctl")
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	defer client.Close()
+//
+//	result, err := client.ScanFile(ctx, data, "upload.bin")
+//
+// Like the rest of the root module, this package only depends on the standard library.
+package clamd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	clamav "github.com/DevHatRo/clamav-api-sdk-go"
+	"github.com/DevHatRo/clamav-api-sdk-go/internal/readerutil"
+)
+
+// Client is a clamd protocol client. By default it opens one connection per command;
+// with WithSessions, commands are multiplexed over a pool of long-lived sessions.
+// It is safe for concurrent use.
+type Client struct {
+	network   string
+	address   string
+	timeout   time.Duration
+	chunkSize int
+	dialer    *net.Dialer
+	retry     *clamav.RetryPolicy
+	sessions  int
+	keepAlive time.Duration
+	pool      *sessionPool
+}
+
+var _ clamav.Scanner = (*Client)(nil)
+
+// NewClient creates a client for the clamd at address, which is either a TCP address
+// ("localhost:3310" or "tcp://localhost:3310") or a Unix socket path
+// ("/run/clamav/clamd.ctl" or "unix:///run/clamav/clamd.ctl").
+// No connection is made until the first call.
+func NewClient(address string, opts ...ClientOption) (*Client, error) {
+	network, addr, err := parseAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		network:   network,
+		address:   addr,
+		timeout:   defaultTimeout,
+		chunkSize: defaultChunkSize,
+		dialer:    &net.Dialer{},
+		keepAlive: defaultKeepAlive,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.sessions > 0 {
+		c.pool = newSessionPool(c, c.sessions, c.keepAlive)
+	}
+	return c, nil
+}
+
+// parseAddress splits a clamd address into a network and an address for net.Dial.
+func parseAddress(address string) (string, string, error) {
+	switch {
+	case strings.HasPrefix(address, "unix://"):
+		address = strings.TrimPrefix(address, "unix://")
+		if address == "" {
+			break
+		}
+		return "unix", address, nil
+	case strings.HasPrefix(address, "tcp://"):
+		address = strings.TrimPrefix(address, "tcp://")
+	case strings.HasPrefix(address, "/"):
+		return "unix", address, nil
+	}
+
+	if _, _, err := net.SplitHostPort(address); err != nil || address == "" {
+		return "", "", clamav.NewValidationError(fmt.Sprintf("invalid clamd address %q", address), err)
+	}
+	return "tcp", address, nil
+}
+
+// Close ends the sessions of a client created with WithSessions; requests still in
+// flight fail with a connection error. Without sessions, Close is a no-op.
+func (c *Client) Close() error {
+	if c.pool != nil {
+		c.pool.close()
+	}
+	return nil
+}
+
+// HealthCheck sends PING and reports clamd as healthy if it answers PONG.
+func (c *Client) HealthCheck(ctx context.Context) (*clamav.HealthCheckResult, error) {
+	reply, err := c.command(ctx, "PING")
+	if err != nil {
+		return nil, err
+	}
+	return &clamav.HealthCheckResult{Healthy: reply == "PONG", Message: reply}, nil
+}
+
+// Version sends VERSION and returns the ClamAV engine version in Version and the
+// signature database version and date in Build, e.g. "1.4.1" and
+// "27480/Tue Nov 26 09:34:56 2024".
+func (c *Client) Version(ctx context.Context) (*clamav.VersionResult, error) {
+	reply, err := c.command(ctx, "VERSION")
+	if err != nil {
+		return nil, err
+	}
+	v, err := ParseVersion(reply)
+	if err != nil {
+		return nil, err
+	}
+	return v.versionResult(), nil
+}
+
+// ScanFile scans file data with INSTREAM.
+func (c *Client) ScanFile(ctx context.Context, data []byte, filename string) (*clamav.ScanResult, error) {
+	if len(data) == 0 {
+		return nil, clamav.NewValidationError("file data is required", nil)
+	}
+	return c.ScanReader(ctx, bytes.NewReader(data), filename)
+}
+
+// ScanFilePath reads a file from disk and scans it with INSTREAM.
+func (c *Client) ScanFilePath(ctx context.Context, filePath string) (*clamav.ScanResult, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, clamav.NewValidationError(fmt.Sprintf("failed to open file: %s", filePath), err)
+	}
+	defer func() { _ = f.Close() }()
+
+	return c.ScanReader(ctx, f, filepath.Base(filePath))
+}
+
+// ScanReader streams r to clamd with INSTREAM without buffering it in memory.
+// With a retry policy, the reader is rewound between attempts if it implements
+// io.Seeker; other readers are sent exactly once.
+func (c *Client) ScanReader(ctx context.Context, r io.Reader, filename string) (*clamav.ScanResult, error) {
+	ctx, cancel := c.contextWithTimeout(ctx)
+	defer cancel()
+
+	policy := c.retry
+	rewind, ok := readerutil.Rewinder(r)
+	if !ok && policy != nil {
+		single := *policy
+		single.MaxAttempts = 1
+		policy = &single
+	}
+
+	var result *clamav.ScanResult
+	attempt := 0
+	err := policy.Do(ctx, func(ctx context.Context) error {
+		attempt++
+		if attempt > 1 {
+			if err := rewind(); err != nil {
+				return clamav.NewValidationError("failed to rewind reader", err)
+			}
+		}
+		start := time.Now()
+		reply, err := c.roundTrip(ctx, "INSTREAM", func(w io.Writer) error {
+			return writeStream(w, r, c.chunkSize)
+		})
+		if err != nil {
+			return err
+		}
+		result, err = parseScanReply(reply, filename)
+		if result != nil {
+			result.ScanTime = time.Since(start).Seconds()
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// StreamScanReader is ScanReader; INSTREAM does not need the size in advance, so
+// size is ignored.
+func (c *Client) StreamScanReader(ctx context.Context, r io.Reader, filename string, _ int64) (*clamav.ScanResult, error) {
+	return c.ScanReader(ctx, r, filename)
+}
+
+// command sends a command without payload and returns its reply.
+func (c *Client) command(ctx context.Context, cmd string) (string, error) {
+	ctx, cancel := c.contextWithTimeout(ctx)
+	defer cancel()
+
+	var reply string
+	err := c.retry.Do(ctx, func(ctx context.Context) error {
+		var err error
+		reply, err = c.roundTrip(ctx, cmd, nil)
+		return err
+	})
+	return reply, err
+}
+
+// roundTrip sends cmd followed by the payload written by send (if any) on a session,
+// or on a new connection without sessions, and returns the reply.
+func (c *Client) roundTrip(ctx context.Context, cmd string, send func(io.Writer) error) (string, error) {
+	if c.pool != nil {
+		return c.pool.do(ctx, cmd, send)
+	}
+	return c.exchange(ctx, cmd, send)
+}
+
+// exchange opens a connection, sends cmd followed by the payload written by send (if
+// any), and reads the reply.
+func (c *Client) exchange(ctx context.Context, cmd string, send func(io.Writer) error) (string, error) {
+	conn, err := c.dialer.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return "", c.transportError(ctx, "failed to connect to clamd", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() {
+		// Unblock pending reads and writes when ctx is canceled.
+		_ = conn.SetDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+
+	writeErr := writeCommand(conn, cmd)
+	if writeErr == nil && send != nil {
+		writeErr = send(conn)
+	}
+	var rerr *readError
+	if errors.As(writeErr, &rerr) {
+		return "", clamav.NewValidationError("failed to read data", rerr.err)
+	}
+
+	// clamd may reply and close the connection before the whole payload was sent,
+	// e.g. when a stream exceeds StreamMaxLength, so the reply is read even if the
+	// write failed.
+	reply, err := readReply(bufio.NewReader(conn))
+	if err != nil {
+		if writeErr != nil {
+			err = writeErr
+		}
+		if err == io.EOF {
+			return "", clamav.NewConnectionError("clamd closed the connection without a reply", err)
+		}
+		return "", c.transportError(ctx, "clamd request failed", err)
+	}
+	return reply, nil
+}
+
+// transportError maps a network error to an SDK error type.
+func (c *Client) transportError(ctx context.Context, msg string, err error) error {
+	switch ctxErr := ctx.Err(); {
+	case errors.Is(ctxErr, context.Canceled):
+		return clamav.NewTimeoutError("request canceled", err)
+	case errors.Is(ctxErr, context.DeadlineExceeded):
+		return clamav.NewTimeoutError("request timed out", err)
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return clamav.NewTimeoutError("request timed out", err)
+	}
+	return clamav.NewConnectionError(msg, err)
+}
+
+// contextWithTimeout applies the default timeout if the context has no deadline.
+func (c *Client) contextWithTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.timeout)
+}
@@ -0,0 +1,287 @@
+package clamd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	clamav "github.com/DevHatRo/clamav-api-sdk-go"
+	"github.com/DevHatRo/clamav-api-sdk-go/clamavtest"
+	"github.com/DevHatRo/clamav-api-sdk-go/internal/fakeclamd"
+)
+
+func newFakeClamd(t *testing.T) *fakeclamd.Server {
+	t.Helper()
+	srv, err := fakeclamd.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestClient(t *testing.T, srv *fakeclamd.Server, opts ...ClientOption) *Client {
+	t.Helper()
+	address := srv.Addr
+	if srv.Network == "unix" {
+		address = "unix://" + srv.Addr
+	}
+	client, err := NewClient(address, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+// --- NewClient tests ---
+
+func TestNewClient(t *testing.T) {
+	tests := []struct {
+		address string
+		network string
+		addr    string
+		valid   bool
+	}{
+		{"localhost:3310", "tcp", "localhost:3310", true},
+		{"tcp://127.0.0.1:3310", "tcp", "127.0.0.1:3310", true},
+		{"[::1]:3310", "tcp", "[::1]:3310", true},
+		{"/run/clamav/clamd.ctl", "unix", "/run/clamav/clamd.ctl", true},
+		{"unix:///run/clamav/clamd.ctl", "unix", "/run/clamav/clamd.ctl", true},
+		{"", "", "", false},
+		{"localhost", "", "", false},
+		{"unix://", "", "", false},
+		{"tcp://", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			c, err := NewClient(tt.address)
+			if !tt.valid {
+				if !clamav.IsValidationError(err) {
+					t.Errorf("expected validation error, got: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if c.network != tt.network || c.address != tt.addr {
+				t.Errorf("network, address = %q, %q, want %q, %q", c.network, c.address, tt.network, tt.addr)
+			}
+		})
+	}
+}
+
+func TestClientOptions(t *testing.T) {
+	c, err := NewClient("localhost:3310", WithTimeout(5*time.Second), WithChunkSize(1024), WithDialer(&net.Dialer{KeepAlive: time.Minute}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.timeout != 5*time.Second || c.chunkSize != 1024 || c.dialer.KeepAlive != time.Minute {
+		t.Errorf("options not applied: %+v", c)
+	}
+
+	c, _ = NewClient("localhost:3310", WithTimeout(-1), WithChunkSize(0), WithDialer(nil))
+	if c.timeout != defaultTimeout || c.chunkSize != defaultChunkSize || c.dialer == nil {
+		t.Errorf("invalid options should be ignored: %+v", c)
+	}
+}
+
+// --- Command tests ---
+
+func TestHealthCheckAndVersion(t *testing.T) {
+	srv := newFakeClamd(t)
+	client := newTestClient(t, srv)
+	ctx := context.Background()
+
+	health, err := client.HealthCheck(ctx)
+	if err != nil || !health.Healthy || health.Message != "PONG" {
+		t.Errorf("HealthCheck = %+v, %v", health, err)
+	}
+
+	version, err := client.Version(ctx)
+	if err != nil || version.Version != "1.4.1" || !strings.HasPrefix(version.Build, "27480/") {
+		t.Errorf("Version = %+v, %v", version, err)
+	}
+
+	if got := srv.Commands(); strings.Join(got, ",") != "PING,VERSION" {
+		t.Errorf("commands = %q", got)
+	}
+}
+
+func TestScan(t *testing.T) {
+	srv := newFakeClamd(t)
+	client := newTestClient(t, srv, WithChunkSize(16))
+	ctx := context.Background()
+	eicar := []byte("x" + clamavtest.EICAR)
+
+	t.Run("clean", func(t *testing.T) {
+		result, err := client.ScanFile(ctx, []byte("hello"), "a.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.IsClean() || result.Filename != "a.txt" || result.ScanTime <= 0 {
+			t.Errorf("result = %+v", result)
+		}
+	})
+
+	t.Run("infected", func(t *testing.T) {
+		result, err := client.ScanFile(ctx, eicar, "eicar.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.IsInfected() || result.Message != clamavtest.EICARSignature {
+			t.Errorf("result = %+v", result)
+		}
+		if chunks := srv.StreamChunks(); chunks[len(chunks)-1] != 5 {
+			t.Errorf("chunks = %v, want 5 for the last stream", chunks)
+		}
+	})
+
+	t.Run("reader", func(t *testing.T) {
+		result, err := client.StreamScanReader(ctx, io.MultiReader(strings.NewReader("x"), bytes.NewReader(eicar)), "r.bin", clamav.UnknownSize)
+		if err != nil || !result.IsInfected() {
+			t.Errorf("result = %+v, %v", result, err)
+		}
+	})
+
+	t.Run("file path", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "eicar.com")
+		if err := os.WriteFile(path, eicar, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		result, err := client.ScanFilePath(ctx, path)
+		if err != nil || !result.IsInfected() || result.Filename != "eicar.com" {
+			t.Errorf("result = %+v, %v", result, err)
+		}
+
+		if _, err := client.ScanFilePath(ctx, filepath.Join(t.TempDir(), "missing")); !clamav.IsValidationError(err) {
+			t.Errorf("expected validation error, got: %v", err)
+		}
+	})
+
+	t.Run("empty data", func(t *testing.T) {
+		if _, err := client.ScanFile(ctx, nil, "a.txt"); !clamav.IsValidationError(err) {
+			t.Errorf("expected validation error, got: %v", err)
+		}
+	})
+
+	t.Run("scan error", func(t *testing.T) {
+		srv.SetReply(func([]byte) string { return "stream: Can't allocate memory ERROR" })
+		defer srv.SetReply(nil)
+		result, err := client.ScanFile(ctx, []byte("hello"), "a.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.IsError() || !clamav.IsScanError(result.Err()) || result.Message != "Can't allocate memory" {
+			t.Errorf("result = %+v", result)
+		}
+	})
+
+	t.Run("size limit", func(t *testing.T) {
+		srv.SetStreamMaxLength(1024)
+		defer srv.SetStreamMaxLength(0)
+		big := bytes.Repeat([]byte("x"), 4<<20)
+		if _, err := client.ScanFile(ctx, big, "big.bin"); !clamav.IsValidationError(err) {
+			t.Errorf("expected validation error, got: %v", err)
+		}
+	})
+
+	t.Run("reader error", func(t *testing.T) {
+		_, err := client.ScanReader(ctx, io.MultiReader(strings.NewReader("abc"), errReader{io.ErrClosedPipe}), "a.txt")
+		if !clamav.IsValidationError(err) {
+			t.Errorf("expected validation error, got: %v", err)
+		}
+	})
+}
+
+func TestUnixSocket(t *testing.T) {
+	srv, err := fakeclamd.Listen("unix", filepath.Join(t.TempDir(), "clamd.sock"))
+	if err != nil {
+		t.Skipf("unix sockets not available: %v", err)
+	}
+	t.Cleanup(srv.Close)
+	client := newTestClient(t, srv)
+
+	result, err := client.ScanFile(context.Background(), []byte(clamavtest.EICAR), "eicar.com")
+	if err != nil || !result.IsInfected() {
+		t.Errorf("result = %+v, %v", result, err)
+	}
+}
+
+// --- Error tests ---
+
+func TestErrors(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("connection refused", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := ln.Addr().String()
+		_ = ln.Close()
+
+		client, _ := NewClient(addr)
+		if _, err := client.HealthCheck(ctx); !clamav.IsConnectionError(err) {
+			t.Errorf("expected connection error, got: %v", err)
+		}
+	})
+
+	t.Run("dropped connection", func(t *testing.T) {
+		srv := newFakeClamd(t)
+		client := newTestClient(t, srv)
+		srv.DropNext(1)
+		if _, err := client.ScanFile(ctx, []byte("hello"), "a.txt"); !clamav.IsConnectionError(err) {
+			t.Errorf("expected connection error, got: %v", err)
+		}
+	})
+
+	t.Run("retried", func(t *testing.T) {
+		srv := newFakeClamd(t)
+		client := newTestClient(t, srv, WithRetryPolicy(clamav.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}))
+		srv.DropNext(1)
+		result, err := client.ScanFile(ctx, []byte(clamavtest.EICAR), "eicar.com")
+		if err != nil || !result.IsInfected() {
+			t.Errorf("result = %+v, %v", result, err)
+		}
+		if n := srv.Connections(); n != 2 {
+			t.Errorf("connections = %d, want 2", n)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		srv := newFakeClamd(t)
+		srv.SetDelay(time.Second)
+		client := newTestClient(t, srv, WithTimeout(50*time.Millisecond))
+		if _, err := client.ScanFile(ctx, []byte("hello"), "a.txt"); !clamav.IsTimeoutError(err) {
+			t.Errorf("expected timeout error, got: %v", err)
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		srv := newFakeClamd(t)
+		srv.SetDelay(time.Second)
+		client := newTestClient(t, srv)
+		ctx, cancel := context.WithCancel(ctx)
+		time.AfterFunc(50*time.Millisecond, cancel)
+		if _, err := client.HealthCheck(ctx); !clamav.IsTimeoutError(err) {
+			t.Errorf("expected timeout error, got: %v", err)
+		}
+	})
+
+	t.Run("unexpected reply", func(t *testing.T) {
+		srv := newFakeClamd(t)
+		srv.SetVersion("nonsense")
+		client := newTestClient(t, srv)
+		if _, err := client.Version(ctx); !clamav.IsServiceError(err) {
+			t.Errorf("expected service error, got: %v", err)
+		}
+	})
+}
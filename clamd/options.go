@@ -0,0 +1,78 @@
+package clamd
+
+import (
+	"net"
+	"time"
+
+	clamav "github.com/DevHatRo/clamav-api-sdk-go"
+)
+
+const (
+	defaultTimeout   = 30 * time.Second
+	defaultChunkSize = 64 * 1024 // 64KB
+)
+
+// ClientOption configures the clamd client.
+type ClientOption func(*Client)
+
+// WithTimeout sets the default timeout for all operations, including dialing
+// (default: 30s). It applies when the context passed to a method has no deadline.
+// Non-positive durations are ignored (no-op).
+func WithTimeout(d time.Duration) ClientOption {
+	return func(c *Client) {
+		if d > 0 {
+			c.timeout = d
+		}
+	}
+}
+
+// WithChunkSize sets the size of the chunks sent with INSTREAM (default: 64KB).
+// It must not exceed the StreamMaxLength of clamd. Non-positive values are ignored (no-op).
+func WithChunkSize(size int) ClientOption {
+	return func(c *Client) {
+		if size > 0 {
+			c.chunkSize = size
+		}
+	}
+}
+
+// WithDialer sets the dialer used to connect to clamd, e.g. to set a local address or
+// keep-alive. A nil dialer is ignored (no-op).
+func WithDialer(d *net.Dialer) ClientOption {
+	return func(c *Client) {
+		if d != nil {
+			c.dialer = d
+		}
+	}
+}
+
+// WithRetryPolicy enables automatic retries of transient failures such as refused
+// connections. Readers are only retried if they implement io.Seeker.
+func WithRetryPolicy(policy clamav.RetryPolicy) ClientOption {
+	return func(c *Client) {
+		c.retry = &policy
+	}
+}
+
+// WithSessions enables session mode: commands are multiplexed over up to n long-lived
+// IDSESSION connections instead of opening a connection per command, which is much
+// faster for many small files. Sessions are dialed on first use, replaced when they
+// break and ended by Close. Non-positive values are ignored (no-op).
+func WithSessions(n int) ClientOption {
+	return func(c *Client) {
+		if n > 0 {
+			c.sessions = n
+		}
+	}
+}
+
+// WithKeepAlive sets how long a session may stay idle before it is sent a PING
+// (default: 10s). Keep it below the clamd IdleTimeout, or clamd closes idle sessions.
+// Non-positive durations are ignored (no-op).
+func WithKeepAlive(d time.Duration) ClientOption {
+	return func(c *Client) {
+		if d > 0 {
+			c.keepAlive = d
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package clamd
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+
+	clamav "github.com/DevHatRo/clamav-api-sdk-go"
+)
+
+// maxReplySize bounds the length of a reply, so a misbehaving peer cannot make the
+// client buffer unbounded data.
+const maxReplySize = 1 << 20
+
+// replySizeLimit is the reply of clamd to a stream longer than its StreamMaxLength.
+const replySizeLimit = "INSTREAM size limit exceeded. ERROR"
+
+// writeCommand sends cmd in the null-terminated "z" format, so that replies are
+// null-terminated too.
+func writeCommand(w io.Writer, cmd string) error {
+	_, err := io.WriteString(w, "z"+cmd+"\x00")
+	return err
+}
+
+// readReply reads one null-terminated reply.
+func readReply(r *bufio.Reader) (string, error) {
+	var sb strings.Builder
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			if err == io.EOF && sb.Len() > 0 {
+				// Replies to unprefixed commands end with a newline and the connection.
+				return strings.TrimSpace(sb.String()), nil
+			}
+			return "", err
+		}
+		if b == 0 {
+			return strings.TrimSpace(sb.String()), nil
+		}
+		if sb.Len() >= maxReplySize {
+			return "", fmt.Errorf("reply longer than %d bytes", maxReplySize)
+		}
+		sb.WriteByte(b)
+	}
+}
+
+// readError is an error reading the content to scan, as opposed to writing it to clamd.
+type readError struct {
+	err error
+}
+
+func (e *readError) Error() string { return e.err.Error() }
+
+func (e *readError) Unwrap() error { return e.err }
+
+// writeStream sends the content of r as INSTREAM chunks: each chunk is prefixed with
+// its length as a 4-byte big-endian integer, and a zero length ends the stream.
+func writeStream(w io.Writer, r io.Reader, chunkSize int) error {
+	buf := make([]byte, 4+chunkSize)
+	for {
+		n, err := io.ReadFull(r, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf, uint32(n))
+			if _, werr := w.Write(buf[:4+n]); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return &readError{err: err}
+		}
+	}
+	_, err := w.Write([]byte{0, 0, 0, 0})
+	return err
+}
+
+// parseScanReply converts an INSTREAM reply such as "stream: OK" or
+// "stream: Eicar-Signature FOUND" to a scan result. A stream larger than the clamd
+// StreamMaxLength is reported as a validation error, like the 413 of the REST API.
+func parseScanReply(reply, filename string) (*clamav.ScanResult, error) {
+	if reply == replySizeLimit {
+		return nil, clamav.NewValidationError("file exceeds the clamd StreamMaxLength", nil)
+	}
+
+	body := strings.TrimPrefix(reply, "stream: ")
+	result := &clamav.ScanResult{Filename: filename}
+	switch {
+	case body == "OK":
+		result.Status = clamav.StatusOK
+	case strings.HasSuffix(body, " FOUND"):
+		result.Status, result.Message = clamav.StatusFound, strings.TrimSuffix(body, " FOUND")
+	case strings.HasSuffix(body, " ERROR"):
+		result.Status, result.Message = clamav.StatusError, strings.TrimSuffix(body, " ERROR")
+	default:
+		return nil, clamav.NewServiceError(fmt.Sprintf("unexpected clamd reply %q", reply), 0, nil)
+	}
+	return result, nil
+}
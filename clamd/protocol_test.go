@@ -0,0 +1,109 @@
+package clamd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	clamav "github.com/DevHatRo/clamav-api-sdk-go"
+)
+
+// --- Protocol tests ---
+
+func TestWriteStream(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeStream(&buf, strings.NewReader("hello world"), 4); err != nil {
+		t.Fatal(err)
+	}
+
+	var chunks []string
+	for {
+		var size uint32
+		if err := binary.Read(&buf, binary.BigEndian, &size); err != nil {
+			t.Fatalf("reading length: %v", err)
+		}
+		if size == 0 {
+			break
+		}
+		chunks = append(chunks, string(buf.Next(int(size))))
+	}
+	if got := strings.Join(chunks, "|"); got != "hell|o wo|rld" {
+		t.Errorf("chunks = %q, want %q", got, "hell|o wo|rld")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("%d bytes after the terminator", buf.Len())
+	}
+
+	t.Run("read error", func(t *testing.T) {
+		readErr := errors.New("disk gone")
+		err := writeStream(io.Discard, io.MultiReader(strings.NewReader("abc"), errReader{readErr}), 2)
+		var rerr *readError
+		if !errors.As(err, &rerr) || !errors.Is(err, readErr) {
+			t.Errorf("err = %v, want a readError wrapping the reader's error", err)
+		}
+	})
+}
+
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
+
+func TestReadReply(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"null-terminated", "PONG\x00", "PONG", false},
+		{"newline before null", "stream: OK\n\x00", "stream: OK", false},
+		{"closed after newline", "PONG\n", "PONG", false},
+		{"empty", "", "", true},
+		{"too long", strings.Repeat("x", maxReplySize+1), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readReply(bufio.NewReader(strings.NewReader(tt.input)))
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("readReply = %q, %v, want %q (error: %v)", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseScanReply(t *testing.T) {
+	tests := []struct {
+		reply   string
+		status  clamav.ScanStatus
+		message string
+		wantErr func(error) bool
+	}{
+		{"stream: OK", clamav.StatusOK, "", nil},
+		{"stream: Win.Test.EICAR_HDB-1 FOUND", clamav.StatusFound, "Win.Test.EICAR_HDB-1", nil},
+		{"stream: Heuristics.Limits.Exceeded.MaxFileSize FOUND", clamav.StatusFound, "Heuristics.Limits.Exceeded.MaxFileSize", nil},
+		{"stream: Can't allocate memory ERROR", clamav.StatusError, "Can't allocate memory", nil},
+		{"INSTREAM size limit exceeded. ERROR", "", "", clamav.IsValidationError},
+		{"UNKNOWN COMMAND", "", "", clamav.IsServiceError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.reply, func(t *testing.T) {
+			result, err := parseScanReply(tt.reply, "a.txt")
+			if tt.wantErr != nil {
+				if !tt.wantErr(err) {
+					t.Errorf("err = %v, want a typed error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Status != tt.status || result.Message != tt.message || result.Filename != "a.txt" {
+				t.Errorf("result = %+v, want %s %q", result, tt.status, tt.message)
+			}
+		})
+	}
+}
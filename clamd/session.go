@@ -0,0 +1,400 @@
+package clamd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	clamav "github.com/DevHatRo/clamav-api-sdk-go"
+)
+
+const (
+	defaultKeepAlive = 10 * time.Second
+	// endTimeout bounds sending END to each session on Close.
+	endTimeout = time.Second
+)
+
+var (
+	// errSessionClosed fails requests in flight on a session that was closed by Close.
+	errSessionClosed = errors.New("clamd session closed")
+	// errNotSent is returned for a command not sent because its session had broken.
+	errNotSent = errors.New("clamd session broke before the command was sent")
+)
+
+// sessionPool multiplexes commands over a fixed number of IDSESSION connections.
+// Broken sessions are replaced when their slot is next used.
+type sessionPool struct {
+	c         *Client
+	keepAlive time.Duration
+	slots     []*slot
+
+	mu     sync.Mutex
+	next   int
+	closed bool
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// slot holds one session, dialed on first use and after it broke.
+type slot struct {
+	mu sync.Mutex
+	s  *session
+}
+
+func newSessionPool(c *Client, size int, keepAlive time.Duration) *sessionPool {
+	p := &sessionPool{
+		c:         c,
+		keepAlive: keepAlive,
+		slots:     make([]*slot, size),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	for i := range p.slots {
+		p.slots[i] = &slot{}
+	}
+	go p.keepAliveLoop()
+	return p
+}
+
+// do runs cmd on the next session in round-robin order. A command that could not be
+// sent because the session had broken, e.g. clamd closed it, is sent again on a new one.
+func (p *sessionPool) do(ctx context.Context, cmd string, send func(io.Writer) error) (string, error) {
+	for attempt := 0; ; attempt++ {
+		s, err := p.get(ctx)
+		if err != nil {
+			return "", err
+		}
+		reply, err := s.do(ctx, cmd, send)
+		if errors.Is(err, errNotSent) && attempt < len(p.slots) {
+			continue
+		}
+		return reply, err
+	}
+}
+
+// get returns a live session, dialing a new one if the chosen slot is empty or broken.
+func (p *sessionPool) get(ctx context.Context) (*session, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, clamav.NewConnectionError("client is closed", errSessionClosed)
+	}
+	sl := p.slots[p.next%len(p.slots)]
+	p.next++
+	p.mu.Unlock()
+
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	if sl.s != nil && sl.s.alive() {
+		return sl.s, nil
+	}
+	s, err := p.c.dialSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Close may have run while dialing; it must not miss the new session.
+	p.mu.Lock()
+	closed := p.closed
+	p.mu.Unlock()
+	if closed {
+		s.close(errSessionClosed)
+		return nil, clamav.NewConnectionError("client is closed", errSessionClosed)
+	}
+	sl.s = s
+	return s, nil
+}
+
+// keepAliveLoop pings sessions that have been idle for keepAlive, so that clamd does
+// not close them for exceeding its IdleTimeout and dead peers are detected early.
+func (p *sessionPool) keepAliveLoop() {
+	defer close(p.done)
+	ticker := time.NewTicker(p.keepAlive / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+		for _, sl := range p.slots {
+			sl.mu.Lock()
+			s := sl.s
+			sl.mu.Unlock()
+			if s == nil || !s.alive() || s.idleFor() < p.keepAlive {
+				continue
+			}
+			go func() {
+				ctx, cancel := context.WithTimeout(context.Background(), p.keepAlive)
+				defer cancel()
+				if reply, err := s.do(ctx, "PING", nil); err != nil || reply != "PONG" {
+					s.close(fmt.Errorf("keepalive failed: %q, %v", reply, err))
+				}
+			}()
+		}
+	}
+}
+
+// close ends all sessions.
+func (p *sessionPool) close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	p.mu.Unlock()
+
+	close(p.stop)
+	<-p.done
+	for _, sl := range p.slots {
+		sl.mu.Lock()
+		if sl.s != nil {
+			sl.s.end()
+		}
+		sl.mu.Unlock()
+	}
+}
+
+// session is one IDSESSION connection. Commands are written one at a time, and
+// replies, which clamd may send out of order, are matched to requests by their ID.
+type session struct {
+	conn net.Conn
+	// wsem serializes writers; it is a channel so that waiting respects contexts.
+	wsem chan struct{}
+
+	mu       sync.Mutex
+	nextID   int
+	pending  map[int]chan sessionReply
+	err      error // set once the session is broken; no new commands are accepted
+	lastUsed time.Time
+}
+
+type sessionReply struct {
+	text string
+	err  error
+}
+
+// dialSession connects to clamd and starts a session.
+func (c *Client) dialSession(ctx context.Context) (*session, error) {
+	ctx, cancel := c.contextWithTimeout(ctx)
+	defer cancel()
+
+	conn, err := c.dialer.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return nil, c.transportError(ctx, "failed to connect to clamd", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetWriteDeadline(deadline)
+	}
+	if err := writeCommand(conn, "IDSESSION"); err != nil {
+		_ = conn.Close()
+		return nil, c.transportError(ctx, "failed to start clamd session", err)
+	}
+	_ = conn.SetWriteDeadline(time.Time{})
+
+	s := &session{
+		conn:     conn,
+		wsem:     make(chan struct{}, 1),
+		pending:  make(map[int]chan sessionReply),
+		lastUsed: time.Now(),
+	}
+	go s.readLoop()
+	return s, nil
+}
+
+// do sends cmd followed by the payload written by send (if any) and waits for its reply.
+func (s *session) do(ctx context.Context, cmd string, send func(io.Writer) error) (string, error) {
+	select {
+	case s.wsem <- struct{}{}:
+	case <-ctx.Done():
+		return "", contextError(ctx)
+	}
+
+	s.mu.Lock()
+	if s.err != nil {
+		s.mu.Unlock()
+		<-s.wsem
+		return "", clamav.NewConnectionError("clamd session is broken", errNotSent)
+	}
+	s.nextID++
+	id := s.nextID
+	ch := make(chan sessionReply, 1)
+	s.pending[id] = ch
+	s.lastUsed = time.Now()
+	s.mu.Unlock()
+	defer s.release(id)
+
+	err := s.write(ctx, cmd, send)
+	<-s.wsem
+	var rerr *readError
+	if errors.As(err, &rerr) {
+		// clamd is still waiting for the rest of the stream, so the session is unusable.
+		s.close(err)
+		return "", clamav.NewValidationError("failed to read data", rerr.err)
+	}
+	if err != nil {
+		// Stop sending on this session but keep reading: clamd may have replied before
+		// closing, e.g. when a stream exceeds StreamMaxLength.
+		s.fail(err)
+	}
+
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			if err == nil {
+				err = r.err
+			}
+			return "", sessionError(ctx, err)
+		}
+		return r.text, nil
+	case <-ctx.Done():
+		return "", contextError(ctx)
+	}
+}
+
+// write sends one command, honoring ctx.
+func (s *session) write(ctx context.Context, cmd string, send func(io.Writer) error) error {
+	// Every writer sets its own deadline, so a past one never outlives its request.
+	deadline, _ := ctx.Deadline()
+	_ = s.conn.SetWriteDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() {
+		_ = s.conn.SetWriteDeadline(time.Unix(1, 0))
+	})
+
+	err := writeCommand(s.conn, cmd)
+	if err == nil && send != nil {
+		err = send(s.conn)
+	}
+	if !stop() && err == nil {
+		// ctx ended during the write, so the command may have been cut short.
+		err = contextError(ctx)
+	}
+	return err
+}
+
+// readLoop delivers replies to the requests waiting for them until the connection fails.
+func (s *session) readLoop() {
+	r := bufio.NewReader(s.conn)
+	for {
+		reply, err := readReply(r)
+		if err != nil {
+			s.close(err)
+			return
+		}
+		idText, text, ok := strings.Cut(reply, ": ")
+		id, convErr := strconv.Atoi(idText)
+		if !ok || convErr != nil {
+			s.close(fmt.Errorf("unexpected clamd session reply %q", reply))
+			return
+		}
+
+		if text == replySizeLimit {
+			// clamd ends the session after rejecting a stream.
+			s.fail(errors.New(text))
+		}
+
+		s.mu.Lock()
+		ch := s.pending[id]
+		delete(s.pending, id)
+		s.mu.Unlock()
+		if ch != nil {
+			ch <- sessionReply{text: text}
+		}
+	}
+}
+
+// release forgets request id, e.g. after its caller gave up, and closes the
+// connection of a broken session once no request waits for it.
+func (s *session) release(id int) {
+	s.mu.Lock()
+	delete(s.pending, id)
+	drained := s.err != nil && len(s.pending) == 0
+	s.mu.Unlock()
+	if drained {
+		_ = s.conn.Close()
+	}
+}
+
+// fail marks the session as broken without interrupting the requests in flight.
+func (s *session) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// close marks the session as broken, fails the requests in flight and closes the connection.
+func (s *session) close(err error) {
+	s.mu.Lock()
+	if s.err == nil {
+		s.err = err
+	}
+	pending := s.pending
+	s.pending = make(map[int]chan sessionReply)
+	s.mu.Unlock()
+
+	for _, ch := range pending {
+		ch <- sessionReply{err: err}
+	}
+	_ = s.conn.Close()
+}
+
+// end sends END and closes the session.
+func (s *session) end() {
+	select {
+	case s.wsem <- struct{}{}:
+		if s.alive() {
+			_ = s.conn.SetWriteDeadline(time.Now().Add(endTimeout))
+			_ = writeCommand(s.conn, "END")
+		}
+		<-s.wsem
+	case <-time.After(endTimeout):
+	}
+	s.close(errSessionClosed)
+}
+
+// alive reports whether the session accepts new commands.
+func (s *session) alive() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err == nil
+}
+
+// idleFor returns the time since the last command was sent.
+func (s *session) idleFor() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return time.Since(s.lastUsed)
+}
+
+// contextError returns a timeout error for a done context.
+func contextError(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return clamav.NewTimeoutError("request canceled", ctx.Err())
+	}
+	return clamav.NewTimeoutError("request timed out", ctx.Err())
+}
+
+// sessionError maps the error of a failed session request to an SDK error type.
+func sessionError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return contextError(ctx)
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return clamav.NewTimeoutError("request timed out", err)
+	}
+	if errors.Is(err, io.EOF) {
+		return clamav.NewConnectionError("clamd closed the session without a reply", err)
+	}
+	return clamav.NewConnectionError("clamd session failed", err)
+}
@@ -0,0 +1,241 @@
+package clamd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	clamav "github.com/DevHatRo/clamav-api-sdk-go"
+	"github.com/DevHatRo/clamav-api-sdk-go/clamavtest"
+)
+
+// waitUntil polls cond until it holds or the test times out.
+func waitUntil(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// sessionBroken reports whether the session in the first slot of the pool has broken.
+func sessionBroken(c *Client) bool {
+	sl := c.pool.slots[0]
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	return sl.s == nil || !sl.s.alive()
+}
+
+// --- Session tests ---
+
+func TestSessions(t *testing.T) {
+	srv := newFakeClamd(t)
+	client := newTestClient(t, srv, WithSessions(2), WithChunkSize(32))
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+	for i := 0; i < 40; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			data := []byte(fmt.Sprintf("file %d", i))
+			if i%4 == 0 {
+				data = append(data, clamavtest.EICAR...)
+			}
+			result, err := client.ScanFile(ctx, data, fmt.Sprintf("f%d", i))
+			switch {
+			case err != nil:
+				errs <- err
+			case result.IsInfected() != (i%4 == 0) || result.Filename != fmt.Sprintf("f%d", i):
+				errs <- fmt.Errorf("file %d: result = %+v", i, result)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if health, err := client.HealthCheck(ctx); err != nil || !health.Healthy {
+		t.Errorf("HealthCheck = %+v, %v", health, err)
+	}
+	if v, err := client.Version(ctx); err != nil || v.Version != "1.4.1" {
+		t.Errorf("Version = %+v, %v", v, err)
+	}
+	if n := srv.Connections(); n != 2 {
+		t.Errorf("connections = %d, want 2", n)
+	}
+	if n := srv.Sessions(); n != 2 {
+		t.Errorf("sessions = %d, want 2", n)
+	}
+}
+
+func TestSessionOutOfOrderReplies(t *testing.T) {
+	srv := newFakeClamd(t)
+	srv.SetReply(func(data []byte) string {
+		if string(data) == "slow" {
+			time.Sleep(200 * time.Millisecond)
+			return "stream: Slow.Signature FOUND"
+		}
+		return "stream: OK"
+	})
+	client := newTestClient(t, srv, WithSessions(1))
+	ctx := context.Background()
+
+	slow := make(chan *clamav.ScanResult, 1)
+	go func() {
+		result, err := client.ScanFile(ctx, []byte("slow"), "slow.bin")
+		if err != nil {
+			t.Errorf("slow scan: %v", err)
+		}
+		slow <- result
+	}()
+	waitUntil(t, func() bool { return len(srv.StreamChunks()) == 1 })
+
+	fast, err := client.ScanFile(ctx, []byte("fast"), "fast.bin")
+	if err != nil || !fast.IsClean() {
+		t.Fatalf("fast = %+v, %v", fast, err)
+	}
+	select {
+	case <-slow:
+		t.Error("the slow scan finished before the fast one")
+	default:
+	}
+	if r := <-slow; r == nil || r.Message != "Slow.Signature" {
+		t.Errorf("slow = %+v", r)
+	}
+	if n := srv.Connections(); n != 1 {
+		t.Errorf("connections = %d, want 1", n)
+	}
+}
+
+func TestSessionReconnect(t *testing.T) {
+	srv := newFakeClamd(t)
+	client := newTestClient(t, srv, WithSessions(1))
+	ctx := context.Background()
+
+	t.Run("closed by clamd while idle", func(t *testing.T) {
+		if _, err := client.ScanFile(ctx, []byte("hello"), "a.txt"); err != nil {
+			t.Fatal(err)
+		}
+		srv.CloseConnections()
+		waitUntil(t, func() bool { return sessionBroken(client) })
+
+		if _, err := client.ScanFile(ctx, []byte("hello"), "a.txt"); err != nil {
+			t.Fatalf("unexpected error after reconnect: %v", err)
+		}
+		if n := srv.Sessions(); n != 2 {
+			t.Errorf("sessions = %d, want 2", n)
+		}
+	})
+
+	t.Run("broken during a request", func(t *testing.T) {
+		srv.DropNext(1)
+		if _, err := client.ScanFile(ctx, []byte("hello"), "a.txt"); !clamav.IsConnectionError(err) {
+			t.Errorf("expected connection error, got: %v", err)
+		}
+		if _, err := client.ScanFile(ctx, []byte("hello"), "a.txt"); err != nil {
+			t.Errorf("unexpected error after reconnect: %v", err)
+		}
+	})
+
+	t.Run("size limit ends the session", func(t *testing.T) {
+		srv.SetStreamMaxLength(1024)
+		defer srv.SetStreamMaxLength(0)
+		big := bytes.Repeat([]byte("x"), 1<<20)
+		if _, err := client.ScanFile(ctx, big, "big.bin"); !clamav.IsValidationError(err) {
+			t.Errorf("expected validation error, got: %v", err)
+		}
+		srv.SetStreamMaxLength(0)
+		if _, err := client.ScanFile(ctx, []byte("hello"), "a.txt"); err != nil {
+			t.Errorf("unexpected error after the size limit: %v", err)
+		}
+	})
+
+	t.Run("reader error", func(t *testing.T) {
+		_, err := client.ScanReader(ctx, io.MultiReader(strings.NewReader("abc"), errReader{io.ErrClosedPipe}), "a.txt")
+		if !clamav.IsValidationError(err) {
+			t.Errorf("expected validation error, got: %v", err)
+		}
+		if _, err := client.ScanFile(ctx, []byte("hello"), "a.txt"); err != nil {
+			t.Errorf("unexpected error after the reader error: %v", err)
+		}
+	})
+}
+
+func TestSessionTimeout(t *testing.T) {
+	srv := newFakeClamd(t)
+	client := newTestClient(t, srv, WithSessions(1))
+
+	if _, err := client.HealthCheck(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	srv.SetDelay(300 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.ScanFile(ctx, []byte("hello"), "a.txt"); !clamav.IsTimeoutError(err) {
+		t.Errorf("expected timeout error, got: %v", err)
+	}
+
+	// The late reply is discarded and the session stays usable.
+	srv.SetDelay(0)
+	result, err := client.ScanFile(context.Background(), []byte(clamavtest.EICAR), "eicar.com")
+	if err != nil || !result.IsInfected() {
+		t.Errorf("result = %+v, %v", result, err)
+	}
+	if n := srv.Sessions(); n != 1 {
+		t.Errorf("sessions = %d, want 1", n)
+	}
+}
+
+func TestSessionKeepAlive(t *testing.T) {
+	srv := newFakeClamd(t)
+	client := newTestClient(t, srv, WithSessions(1), WithKeepAlive(20*time.Millisecond))
+
+	if _, err := client.ScanFile(context.Background(), []byte("hello"), "a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, func() bool {
+		for _, cmd := range srv.Commands() {
+			if cmd == "PING" {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func TestSessionClose(t *testing.T) {
+	srv := newFakeClamd(t)
+	client, err := NewClient(srv.Addr, WithSessions(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ScanFile(context.Background(), []byte("hello"), "a.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, func() bool {
+		cmds := srv.Commands()
+		return len(cmds) > 0 && cmds[len(cmds)-1] == "END"
+	})
+	if _, err := client.ScanFile(context.Background(), []byte("hello"), "a.txt"); !clamav.IsConnectionError(err) {
+		t.Errorf("expected connection error after Close, got: %v", err)
+	}
+	if err := client.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+}
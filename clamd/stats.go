@@ -0,0 +1,311 @@
+package clamd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	clamav "github.com/DevHatRo/clamav-api-sdk-go"
+)
+
+// VersionInfo is the parsed reply to VERSION or VERSIONCOMMANDS.
+type VersionInfo struct {
+	// Engine is the ClamAV engine version, e.g. "1.4.1".
+	Engine string
+	// Database is the signature database version, or 0 if clamd did not report it.
+	Database int
+	// DatabaseDate is the build date of the signature database, or the zero time if
+	// clamd did not report it. clamd prints it in its local time zone without an
+	// offset, so it is interpreted as UTC.
+	DatabaseDate time.Time
+	// Commands lists the commands clamd supports. Only VERSIONCOMMANDS reports them.
+	Commands []string
+}
+
+// Supports reports whether clamd listed cmd in its reply to VERSIONCOMMANDS.
+func (v *VersionInfo) Supports(cmd string) bool {
+	for _, c := range v.Commands {
+		if c == cmd {
+			return true
+		}
+	}
+	return false
+}
+
+// Stats is the state of clamd as reported by STATS, along with its version.
+type Stats struct {
+	Version VersionInfo
+	Pools   []PoolStats
+	Memory  MemoryStats
+}
+
+// QueueLength returns the number of requests queued in all thread pools.
+func (s *Stats) QueueLength() int {
+	n := 0
+	for _, p := range s.Pools {
+		n += p.Queue
+	}
+	return n
+}
+
+// PoolStats is the state of one clamd thread pool.
+type PoolStats struct {
+	// State is "VALID", "INVALID" or "EXIT".
+	State string
+	// Primary reports whether this is the pool serving new requests; the others are
+	// draining after a database reload.
+	Primary     bool
+	LiveThreads int
+	IdleThreads int
+	MaxThreads  int
+	IdleTimeout time.Duration
+	// Queue is the number of requests waiting for a thread.
+	Queue int
+}
+
+// BusyThreads returns the number of threads handling a request.
+func (p PoolStats) BusyThreads() int {
+	return p.LiveThreads - p.IdleThreads
+}
+
+// MemoryStats is the memory usage of clamd, in bytes. Heap, Mmap, Used, Free and
+// Releasable are -1 if clamd was built without mallinfo and reports them as N/A.
+type MemoryStats struct {
+	Heap       int64
+	Mmap       int64
+	Used       int64
+	Free       int64
+	Releasable int64
+	// Pools is the number of memory pools; PoolsUsed and PoolsTotal are their usage
+	// and size, which include the signature database.
+	Pools      int
+	PoolsUsed  int64
+	PoolsTotal int64
+}
+
+// Stats sends VERSION and STATS and returns the signature database version, the
+// thread pool and queue state, and the memory usage of clamd, e.g. to feed dashboards
+// or autoscaling.
+func (c *Client) Stats(ctx context.Context) (*Stats, error) {
+	ctx, cancel := c.contextWithTimeout(ctx)
+	defer cancel()
+
+	reply, err := c.command(ctx, "VERSION")
+	if err != nil {
+		return nil, err
+	}
+	version, err := ParseVersion(reply)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err = c.command(ctx, "STATS")
+	if err != nil {
+		return nil, err
+	}
+	stats, err := ParseStats(reply)
+	if err != nil {
+		return nil, err
+	}
+	stats.Version = *version
+	return stats, nil
+}
+
+// VersionCommands sends VERSIONCOMMANDS and returns the version of clamd along with
+// the commands it supports.
+func (c *Client) VersionCommands(ctx context.Context) (*VersionInfo, error) {
+	reply, err := c.command(ctx, "VERSIONCOMMANDS")
+	if err != nil {
+		return nil, err
+	}
+	return ParseVersionCommands(reply)
+}
+
+// ParseVersion parses a VERSION reply such as
+// "ClamAV 1.4.1/27480/Tue Nov 26 09:34:56 2024". The database version and date are
+// missing from the reply when clamd has not loaded a database.
+func ParseVersion(reply string) (*VersionInfo, error) {
+	rest, ok := strings.CutPrefix(reply, "ClamAV ")
+	if !ok {
+		return nil, unexpectedReply("VERSION", reply)
+	}
+	parts := strings.SplitN(rest, "/", 3)
+	v := &VersionInfo{Engine: parts[0]}
+	if len(parts) > 1 {
+		db, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, unexpectedReply("VERSION", reply)
+		}
+		v.Database = db
+	}
+	if len(parts) > 2 {
+		date, err := time.Parse(time.ANSIC, strings.TrimSpace(parts[2]))
+		if err != nil {
+			return nil, unexpectedReply("VERSION", reply)
+		}
+		v.DatabaseDate = date
+	}
+	return v, nil
+}
+
+// versionResult converts v to the VersionResult returned by Client.Version, with the
+// database version and date in Build as clamd reports them.
+func (v *VersionInfo) versionResult() *clamav.VersionResult {
+	r := &clamav.VersionResult{Version: v.Engine}
+	if v.Database != 0 || !v.DatabaseDate.IsZero() {
+		r.Build = strconv.Itoa(v.Database)
+		if !v.DatabaseDate.IsZero() {
+			r.Build += "/" + v.DatabaseDate.Format(time.ANSIC)
+		}
+	}
+	return r
+}
+
+// ParseVersionCommands parses a VERSIONCOMMANDS reply such as
+// "ClamAV 1.4.1/27480/Tue Nov 26 09:34:56 2024| COMMANDS: SCAN PING ... INSTREAM".
+func ParseVersionCommands(reply string) (*VersionInfo, error) {
+	version, commands, ok := strings.Cut(reply, "| COMMANDS:")
+	if !ok {
+		return nil, unexpectedReply("VERSIONCOMMANDS", reply)
+	}
+	v, err := ParseVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	v.Commands = strings.Fields(commands)
+	return v, nil
+}
+
+// ParseStats parses a STATS reply. The Version of the result is left empty, as STATS
+// does not report it. Lines it does not know, such as the list of queued requests,
+// are ignored.
+func ParseStats(reply string) (*Stats, error) {
+	body, ok := strings.CutSuffix(strings.TrimSpace(reply), "END")
+	if !ok || !strings.HasPrefix(body, "POOLS:") {
+		return nil, unexpectedReply("STATS", reply)
+	}
+
+	stats := &Stats{}
+	var pool *PoolStats
+	for _, line := range strings.Split(body, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || strings.HasPrefix(line, "\t") {
+			continue
+		}
+		fields := strings.Fields(value)
+		var err error
+		switch key {
+		case "STATE":
+			stats.Pools = append(stats.Pools, PoolStats{})
+			pool = &stats.Pools[len(stats.Pools)-1]
+			if len(fields) > 0 {
+				pool.State = fields[0]
+			}
+			pool.Primary = len(fields) > 1 && fields[1] == "PRIMARY"
+		case "THREADS":
+			if pool == nil {
+				return nil, unexpectedReply("STATS", reply)
+			}
+			err = parseThreads(pool, fields)
+		case "QUEUE":
+			if pool == nil || len(fields) == 0 {
+				return nil, unexpectedReply("STATS", reply)
+			}
+			pool.Queue, err = strconv.Atoi(fields[0])
+		case "MEMSTATS":
+			err = parseMemStats(&stats.Memory, fields)
+		}
+		if err != nil {
+			return nil, clamav.NewServiceError(fmt.Sprintf("unexpected clamd STATS line %q", line), 0, err)
+		}
+	}
+	return stats, nil
+}
+
+// parseThreads parses the fields of a line such as
+// "THREADS: live 1  idle 0 max 12 idle-timeout 30".
+func parseThreads(pool *PoolStats, fields []string) error {
+	return parsePairs(fields, func(key, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "live":
+			pool.LiveThreads = n
+		case "idle":
+			pool.IdleThreads = n
+		case "max":
+			pool.MaxThreads = n
+		case "idle-timeout":
+			pool.IdleTimeout = time.Duration(n) * time.Second
+		}
+		return nil
+	})
+}
+
+// parseMemStats parses the fields of a line such as
+// "MEMSTATS: heap 9.082M mmap 0.000M used 6.902M free 2.184M releasable 0.129M
+// pools 1 pools_used 565.141M pools_total 565.185M".
+func parseMemStats(mem *MemoryStats, fields []string) error {
+	return parsePairs(fields, func(key, value string) error {
+		if key == "pools" {
+			n, err := strconv.Atoi(value)
+			mem.Pools = n
+			return err
+		}
+		size, err := parseMegabytes(value)
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "heap":
+			mem.Heap = size
+		case "mmap":
+			mem.Mmap = size
+		case "used":
+			mem.Used = size
+		case "free":
+			mem.Free = size
+		case "releasable":
+			mem.Releasable = size
+		case "pools_used":
+			mem.PoolsUsed = size
+		case "pools_total":
+			mem.PoolsTotal = size
+		}
+		return nil
+	})
+}
+
+// parsePairs calls fn for each key and value of fields such as "live 1 idle 0".
+func parsePairs(fields []string, fn func(key, value string) error) error {
+	if len(fields)%2 != 0 {
+		return fmt.Errorf("odd number of fields")
+	}
+	for i := 0; i < len(fields); i += 2 {
+		if err := fn(fields[i], fields[i+1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseMegabytes converts a size such as "9.082M" to bytes, and "N/A" to -1.
+func parseMegabytes(value string) (int64, error) {
+	if value == "N/A" {
+		return -1, nil
+	}
+	mb, err := strconv.ParseFloat(strings.TrimSuffix(value, "M"), 64)
+	if err != nil {
+		return 0, err
+	}
+	return int64(mb * (1 << 20)), nil
+}
+
+// unexpectedReply returns a service error for a reply that cannot be parsed.
+func unexpectedReply(cmd, reply string) error {
+	return clamav.NewServiceError(fmt.Sprintf("unexpected clamd %s reply %q", cmd, reply), 0, nil)
+}
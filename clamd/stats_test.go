@@ -0,0 +1,174 @@
+package clamd
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	clamav "github.com/DevHatRo/clamav-api-sdk-go"
+	"github.com/DevHatRo/clamav-api-sdk-go/internal/fakeclamd"
+)
+
+// --- Parser tests ---
+
+func TestParseVersionInfo(t *testing.T) {
+	v, err := ParseVersion("ClamAV 1.4.1/27480/Tue Nov 26 09:34:56 2024")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2024, time.November, 26, 9, 34, 56, 0, time.UTC)
+	if v.Engine != "1.4.1" || v.Database != 27480 || !v.DatabaseDate.Equal(want) || v.Commands != nil {
+		t.Errorf("version = %+v", v)
+	}
+
+	for reply, build := range map[string]string{
+		"ClamAV 1.4.1/27480/Tue Nov 26 09:34:56 2024": "27480/Tue Nov 26 09:34:56 2024",
+		"ClamAV 1.4.1/27480/Wed Mar  6 08:24:00 2024": "27480/Wed Mar  6 08:24:00 2024",
+		"ClamAV 1.4.1": "",
+	} {
+		v, err := ParseVersion(reply)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r := v.versionResult(); r.Version != "1.4.1" || r.Build != build {
+			t.Errorf("%q: versionResult = %+v, want Build %q", reply, r, build)
+		}
+	}
+	if v, err := ParseVersion("ClamAV 1.4.1/27480/Wed Mar  6 08:24:00 2024"); err != nil || v.DatabaseDate.Day() != 6 {
+		t.Errorf("space-padded day: %+v, %v", v, err)
+	}
+	if v, err := ParseVersion("ClamAV 1.4.1"); err != nil || v.Engine != "1.4.1" || v.Database != 0 || !v.DatabaseDate.IsZero() {
+		t.Errorf("without database: %+v, %v", v, err)
+	}
+	for _, reply := range []string{"UNKNOWN COMMAND", "ClamAV 1.4.1/daily/Tue Nov 26 09:34:56 2024", "ClamAV 1.4.1/27480/yesterday"} {
+		if _, err := ParseVersion(reply); !clamav.IsServiceError(err) {
+			t.Errorf("%q: expected service error, got: %v", reply, err)
+		}
+	}
+}
+
+func TestParseVersionCommands(t *testing.T) {
+	v, err := ParseVersionCommands(fakeclamd.DefaultVersion + "| COMMANDS: " + fakeclamd.DefaultCommands)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Engine != "1.4.1" || v.Database != 27480 || len(v.Commands) != 17 {
+		t.Errorf("version = %+v", v)
+	}
+	if !v.Supports("INSTREAM") || !v.Supports("STATS") || v.Supports("NOPE") {
+		t.Errorf("Supports mismatch for %v", v.Commands)
+	}
+
+	if _, err := ParseVersionCommands(fakeclamd.DefaultVersion); !clamav.IsServiceError(err) {
+		t.Errorf("expected service error, got: %v", err)
+	}
+}
+
+func TestParseStats(t *testing.T) {
+	t.Run("mallinfo", func(t *testing.T) {
+		stats, err := ParseStats(fakeclamd.DefaultStats)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(stats.Pools) != 1 {
+			t.Fatalf("pools = %+v", stats.Pools)
+		}
+		want := PoolStats{State: "VALID", Primary: true, LiveThreads: 1, MaxThreads: 12, IdleTimeout: 30 * time.Second}
+		if stats.Pools[0] != want {
+			t.Errorf("pool = %+v, want %+v", stats.Pools[0], want)
+		}
+		if n := stats.Pools[0].BusyThreads(); n != 1 {
+			t.Errorf("BusyThreads = %d, want 1", n)
+		}
+		mem, mb := stats.Memory, float64(1<<20)
+		if mem.Heap != int64(9.082*mb) || mem.Mmap != 0 || mem.Pools != 1 || mem.PoolsTotal != int64(565.185*mb) {
+			t.Errorf("memory = %+v", mem)
+		}
+	})
+
+	t.Run("busy pools without mallinfo", func(t *testing.T) {
+		reply := "POOLS: 2\n\n" +
+			"STATE: VALID PRIMARY\nTHREADS: live 12  idle 0 max 12 idle-timeout 30\nQUEUE: 3 items\n" +
+			"\tINSTREAM 0.500000 \n\tINSTREAM 0.250000 \n\tSCAN 0.100000 /tmp/a:b\n\n" +
+			"STATE: EXIT\nTHREADS: live 2  idle 1 max 12 idle-timeout 30\nQUEUE: 1 items\n\tSTATS 0.000054 \n\n" +
+			"MEMSTATS: heap N/A mmap N/A used N/A free N/A releasable N/A pools 2 pools_used 1306.837M pools_total 1306.882M\nEND"
+		stats, err := ParseStats(reply)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(stats.Pools) != 2 || stats.Pools[1].State != "EXIT" || stats.Pools[1].Primary {
+			t.Fatalf("pools = %+v", stats.Pools)
+		}
+		if n := stats.QueueLength(); n != 4 {
+			t.Errorf("QueueLength = %d, want 4", n)
+		}
+		if n := stats.Pools[0].BusyThreads(); n != 12 {
+			t.Errorf("BusyThreads = %d, want 12", n)
+		}
+		if stats.Memory.Heap != -1 || stats.Memory.Releasable != -1 || stats.Memory.Pools != 2 || stats.Memory.PoolsUsed <= 0 {
+			t.Errorf("memory = %+v", stats.Memory)
+		}
+	})
+
+	for _, reply := range []string{
+		"UNKNOWN COMMAND",
+		"POOLS: 1\n\nSTATE: VALID PRIMARY\nTHREADS: live 1  idle 0 max 12 idle-timeout 30\n",
+		"POOLS: 1\n\nTHREADS: live 1  idle 0 max 12 idle-timeout 30\nEND",
+		"POOLS: 1\n\nSTATE: VALID PRIMARY\nTHREADS: live one\nEND",
+		"POOLS: 1\n\nSTATE: VALID PRIMARY\nQUEUE: many items\nEND",
+		"POOLS: 1\n\nMEMSTATS: heap 9.082G\nEND",
+	} {
+		if _, err := ParseStats(reply); !clamav.IsServiceError(err) {
+			t.Errorf("%q: expected service error, got: %v", reply, err)
+		}
+	}
+}
+
+// --- Stats tests ---
+
+func TestStats(t *testing.T) {
+	modes := []struct {
+		name     string
+		sessions int
+	}{
+		{"connection per command", 0},
+		{"session", 1},
+	}
+	for _, mode := range modes {
+		t.Run(mode.name, func(t *testing.T) {
+			srv := newFakeClamd(t)
+			client := newTestClient(t, srv, WithSessions(mode.sessions))
+			ctx := context.Background()
+
+			stats, err := client.Stats(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats.Version.Engine != "1.4.1" || stats.Version.Database != 27480 || stats.Version.DatabaseDate.Year() != 2024 {
+				t.Errorf("version = %+v", stats.Version)
+			}
+			if len(stats.Pools) != 1 || stats.QueueLength() != 0 || stats.Memory.Used <= 0 {
+				t.Errorf("stats = %+v", stats)
+			}
+
+			v, err := client.VersionCommands(ctx)
+			if err != nil || !v.Supports("IDSESSION") {
+				t.Errorf("VersionCommands = %+v, %v", v, err)
+			}
+
+			if got := strings.Join(srv.Commands(), ","); !strings.HasSuffix(got, "VERSION,STATS,VERSIONCOMMANDS") {
+				t.Errorf("commands = %q", got)
+			}
+		})
+	}
+
+	t.Run("unexpected reply", func(t *testing.T) {
+		srv := newFakeClamd(t)
+		srv.SetStats("UNKNOWN COMMAND")
+		client := newTestClient(t, srv)
+		if _, err := client.Stats(context.Background()); !clamav.IsServiceError(err) {
+			t.Errorf("expected service error, got: %v", err)
+		}
+	})
+}
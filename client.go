@@ -7,13 +7,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"mime/multipart"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
-	"strings"
 	"time"
 )
 
@@ -24,33 +22,43 @@ const (
 	pathVersion     = "/api/version"
 	pathScan        = "/api/scan"
 	pathStreamScan  = "/api/stream-scan"
+
+	// maxErrorBodySize caps how much of an error response is buffered when deciding whether to retry.
+	maxErrorBodySize = 64 * 1024
 )
 
 // Client is the REST client for the ClamAV API.
 // It is safe for concurrent use from multiple goroutines.
 type Client struct {
-	baseURL    string
-	httpClient *http.Client
-	timeout    time.Duration
-	headers    map[string]string
+	baseURL           string
+	httpClient        *http.Client
+	timeout           time.Duration
+	headers           map[string]string
+	retry             *RetryPolicy
+	pool              *endpointPool
+	balancer          BalancingStrategy
+	healthInterval    time.Duration
+	batchConcurrency  int
+	chunkedStreamScan bool
+	spillThreshold    int64
+	tempDir           string
 }
 
 // NewClient creates a REST client for the ClamAV API.
 // baseURL is the server base URL, e.g. "http://localhost:6000".
+// To balance across several replicas, use NewClientWithEndpoints.
 func NewClient(baseURL string, opts ...ClientOption) (*Client, error) {
-	baseURL = strings.TrimRight(baseURL, "/")
-
-	u, err := url.Parse(baseURL)
+	baseURL, _, err := parseBaseURL(baseURL)
 	if err != nil {
-		return nil, NewValidationError(fmt.Sprintf("invalid base URL: %s", baseURL), err)
-	}
-	if u.Scheme == "" || u.Host == "" {
-		return nil, NewValidationError(fmt.Sprintf("base URL must include scheme and host: %s", baseURL), nil)
+		return nil, err
 	}
 
 	c := &Client{
-		baseURL: baseURL,
-		timeout: defaultTimeout,
+		baseURL:          baseURL,
+		timeout:          defaultTimeout,
+		healthInterval:   defaultEndpointHealthInterval,
+		batchConcurrency: defaultBatchConcurrency,
+		spillThreshold:   defaultSpillThreshold,
 	}
 
 	for _, opt := range opts {
@@ -66,8 +74,12 @@ func NewClient(baseURL string, opts ...ClientOption) (*Client, error) {
 	return c, nil
 }
 
-// Close releases any resources held by the client.
+// Close releases any resources held by the client, including the background
+// endpoint health probes of a multi-endpoint client.
 func (c *Client) Close() error {
+	if c.pool != nil {
+		c.pool.close()
+	}
 	c.httpClient.CloseIdleConnections()
 	return nil
 }
@@ -85,6 +97,13 @@ func (c *Client) HealthCheck(ctx context.Context) (*HealthCheckResult, error) {
 	}
 	defer func() { _ = resp.Body.Close() }()
 
+	return decodeHealth(resp)
+}
+
+// decodeHealth decodes a health-check response. The service is healthy only with
+// status 200 and the message "ok", so that e.g. a proxy's own 200 page is not taken
+// for a live service.
+func decodeHealth(resp *http.Response) (*HealthCheckResult, error) {
 	var body struct {
 		Message string `json:"message"`
 	}
@@ -125,12 +144,12 @@ func (c *Client) Version(ctx context.Context) (*VersionResult, error) {
 
 // ScanFile scans file data provided as a byte slice via multipart upload.
 // filename is optional metadata sent with the multipart upload.
-// For large or unbounded payloads, use StreamScan to avoid buffering the entire body in memory.
 func (c *Client) ScanFile(ctx context.Context, data []byte, filename string) (*ScanResult, error) {
 	return c.ScanReader(ctx, bytes.NewReader(data), filename)
 }
 
 // ScanFilePath reads a file from disk and scans it via multipart upload.
+// The file is streamed, not loaded into memory.
 func (c *Client) ScanFilePath(ctx context.Context, filePath string) (*ScanResult, error) {
 	f, err := os.Open(filePath)
 	if err != nil {
@@ -142,52 +161,57 @@ func (c *Client) ScanFilePath(ctx context.Context, filePath string) (*ScanResult
 }
 
 // ScanReader scans data from an io.Reader via multipart upload.
-// The entire body is buffered in memory to build the multipart payload; for large files
-// or unbounded streams use StreamScan instead to avoid high memory use.
+// The multipart body is streamed through a pipe, so memory use is constant regardless
+// of the size of r. When the remaining length of r is known (bytes.Reader, strings.Reader,
+// bytes.Buffer or a regular *os.File) the request carries a precomputed Content-Length;
+// otherwise it uses chunked transfer encoding.
 func (c *Client) ScanReader(ctx context.Context, r io.Reader, filename string) (*ScanResult, error) {
 	if filename == "" {
 		filename = "file"
 	}
 
-	var buf bytes.Buffer
-	writer := multipart.NewWriter(&buf)
-
-	part, err := writer.CreateFormFile("file", filename)
+	body, err := newMultipartBody(r, filename)
 	if err != nil {
-		return nil, NewValidationError("failed to create multipart form", err)
-	}
-
-	if _, err := io.Copy(part, r); err != nil {
-		return nil, NewValidationError("failed to write file data", err)
+		return nil, err
 	}
+	// Wait for the writer goroutine so r is not read after ScanReader returns.
+	defer body.release()
 
-	if err := writer.Close(); err != nil {
-		return nil, NewValidationError("failed to close multipart writer", err)
+	req, err := c.newRequest(ctx, http.MethodPost, pathScan, body.open())
+	if err != nil {
+		return nil, err
 	}
+	req.Header.Set("Content-Type", body.contentType())
+	req.ContentLength = body.size
+	req.GetBody = body.getBody()
 
-	req, err := c.newRequest(ctx, http.MethodPost, pathScan, &buf)
+	result, err := c.doScan(req)
 	if err != nil {
+		if readErr := body.readError(); readErr != nil {
+			return nil, NewValidationError("failed to write file data", readErr)
+		}
 		return nil, err
 	}
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-
-	return c.doScan(req)
+	return result, nil
 }
 
 // StreamScan scans data from an io.Reader via the stream-scan endpoint.
 // size is the Content-Length to set (required, must be > 0).
-// For unknown sizes, buffer into bytes first and use ScanFile instead.
+// For readers of unknown size, use StreamScanReader with UnknownSize.
 func (c *Client) StreamScan(ctx context.Context, r io.Reader, size int64) (*ScanResult, error) {
 	if size <= 0 {
 		return nil, NewValidationError("size must be greater than 0", nil)
 	}
 
-	req, err := c.newRequest(ctx, http.MethodPost, pathStreamScan, r)
+	// The body is wrapped so the transport does not close the caller's reader,
+	// which would prevent rewinding it for a retry.
+	req, err := c.newRequest(ctx, http.MethodPost, pathStreamScan, io.NopCloser(r))
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/octet-stream")
 	req.ContentLength = size
+	req.GetBody = seekBody(r)
 
 	return c.doScan(req)
 }
@@ -208,6 +232,48 @@ func (c *Client) StreamScanFile(ctx context.Context, filePath string) (*ScanResu
 	return c.StreamScan(ctx, f, stat.Size())
 }
 
+// StreamScanReader scans data from an io.Reader via the stream-scan endpoint.
+// size is the length of r, or UnknownSize (any negative value) if it is not known.
+// filename is not sent to the stream-scan endpoint.
+//
+// Readers of unknown size are handled according to the client options: with
+// WithChunkedStreamScan they are sent with chunked transfer encoding, which the server
+// must accept. Otherwise they are read to the end first, kept in memory up to the
+// WithSpillThreshold limit (default: 8MB) and spilled to a temporary file beyond it,
+// and then sent with a proper Content-Length. Empty readers are sent as a multipart
+// upload because the stream-scan endpoint rejects empty bodies.
+func (c *Client) StreamScanReader(ctx context.Context, r io.Reader, filename string, size int64) (*ScanResult, error) {
+	if size > 0 {
+		return c.StreamScan(ctx, r, size)
+	}
+	if size == 0 {
+		return c.ScanReader(ctx, r, filename)
+	}
+
+	if c.chunkedStreamScan {
+		req, err := c.newRequest(ctx, http.MethodPost, pathStreamScan, io.NopCloser(r))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/octet-stream")
+		req.ContentLength = -1
+		req.GetBody = seekBody(r)
+
+		return c.doScan(req)
+	}
+
+	spooled, err := spool(r, c.spillThreshold, c.tempDir)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = spooled.Close() }()
+
+	if spooled.size == 0 {
+		return c.ScanReader(ctx, spooled, filename)
+	}
+	return c.StreamScan(ctx, spooled, spooled.size)
+}
+
 // newRequest creates an HTTP request with context, base URL, and default headers.
 func (c *Client) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
 	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
@@ -223,7 +289,75 @@ func (c *Client) newRequest(ctx context.Context, method, path string, body io.Re
 }
 
 // do executes an HTTP request and maps transport errors to SDK error types.
+// When a retry policy is configured, transient failures are retried as long as the
+// request body can be replayed through req.GetBody.
 func (c *Client) do(req *http.Request) (*http.Response, error) {
+	attempts := c.retry.attempts()
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		attempts = 1 // the body cannot be replayed
+	}
+	if attempts == 1 {
+		return c.doAttempt(req)
+	}
+
+	ctx := req.Context()
+	for attempt := 1; ; attempt++ {
+		body := trackBody(req)
+
+		resp, err := c.doAttempt(req)
+		if attempt >= attempts || ctx.Err() != nil {
+			return resp, err
+		}
+		if err != nil {
+			if !c.retry.shouldRetry(err) {
+				return nil, err
+			}
+		} else {
+			if !isRetryableStatus(resp.StatusCode) {
+				return resp, nil
+			}
+			// Leave the response for the caller to map unless the policy retries it.
+			if err = c.peekErrorResponse(resp); !c.retry.shouldRetry(err) {
+				return resp, nil
+			}
+			_ = resp.Body.Close()
+		}
+
+		if c.retry.wait(ctx, attempt) != nil {
+			return nil, err
+		}
+		if req, err = rewindRequest(ctx, req, body); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// doAttempt sends a single attempt, bounded by the retry policy's per-attempt timeout.
+// With multiple endpoints, a connection error fails over to the next healthy endpoint
+// when the request body can be replayed.
+func (c *Client) doAttempt(req *http.Request) (*http.Response, error) {
+	ctx, cancel := c.retry.attemptContext(req.Context())
+	req = req.WithContext(ctx)
+
+	var resp *http.Response
+	var err error
+	if c.pool == nil {
+		resp, err = c.send(req)
+	} else {
+		resp, err = c.sendBalanced(req)
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// The attempt context must outlive do so the caller can read the body.
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// send executes req with the HTTP client and maps transport errors.
+func (c *Client) send(req *http.Request) (*http.Response, error) {
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, c.classifyTransportError(err)
@@ -231,6 +365,42 @@ func (c *Client) do(req *http.Request) (*http.Response, error) {
 	return resp, nil
 }
 
+// sendBalanced sends req to an endpoint chosen by the pool, failing over on
+// connection errors while untried healthy endpoints remain.
+func (c *Client) sendBalanced(req *http.Request) (*http.Response, error) {
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	for tried := 1; ; tried++ {
+		body := trackBody(req)
+
+		resp, err := c.pool.send(c.pool.pick(), req, c.send)
+		if err == nil || !IsConnectionError(err) || !replayable ||
+			tried >= len(c.pool.endpoints) || c.pool.healthyCount() == 0 {
+			return resp, err
+		}
+
+		if req, err = rewindRequest(req.Context(), req, body); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// peekErrorResponse maps an error response without consuming it, so the
+// response can still be returned to the caller if it is not retried.
+// Only the error is returned when the body cannot be read.
+func (c *Client) peekErrorResponse(resp *http.Response) error {
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	_ = resp.Body.Close()
+	if err != nil {
+		return c.classifyTransportError(err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+
+	peek := *resp
+	peek.Body = io.NopCloser(bytes.NewReader(data))
+	return c.handleErrorResponse(&peek)
+}
+
 // doScan executes a scan request and parses the response.
 func (c *Client) doScan(req *http.Request) (*ScanResult, error) {
 	resp, err := c.do(req)
@@ -247,6 +417,9 @@ func (c *Client) doScan(req *http.Request) (*ScanResult, error) {
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, NewServiceError("failed to decode scan response", resp.StatusCode, err)
 	}
+	if !result.Status.Valid() {
+		return nil, NewServiceError(fmt.Sprintf("unexpected scan status %q", result.Status), resp.StatusCode, nil)
+	}
 
 	return &result, nil
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -285,6 +286,23 @@ func TestScanFile(t *testing.T) {
 		}
 	})
 
+	t.Run("unknown status", func(t *testing.T) {
+		srv := testutil.NewMockServer(map[string]http.HandlerFunc{
+			"/api/scan": testutil.JSONHandler(http.StatusOK, map[string]interface{}{
+				"status": "INFECTED", "message": "", "time": 0.001,
+			}),
+		})
+		defer srv.Close()
+
+		client := mustNewClient(t, srv.URL)
+		defer func() { _ = client.Close() }()
+
+		_, err := client.ScanFile(context.Background(), []byte("data"), "test.txt")
+		if !IsServiceError(err) || !strings.Contains(err.Error(), `"INFECTED"`) {
+			t.Errorf("expected service error for unknown status, got: %v", err)
+		}
+	})
+
 	t.Run("error 400", func(t *testing.T) {
 		srv := testutil.NewMockServer(map[string]http.HandlerFunc{
 			"/api/scan": testutil.JSONHandler(http.StatusBadRequest, map[string]string{
@@ -603,6 +621,62 @@ func TestStreamScanFile(t *testing.T) {
 	})
 }
 
+// --- StreamScanReader tests ---
+
+func TestStreamScanReader(t *testing.T) {
+	var hitPath, receivedFilename string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hitPath = r.URL.Path
+		testutil.ScanHandler(func(data []byte, filename string) (int, interface{}) {
+			receivedFilename = filename
+			return http.StatusOK, testutil.CleanScanResponse()
+		})(w, r)
+	}))
+	defer srv.Close()
+
+	client := mustNewClient(t, srv.URL)
+	defer func() { _ = client.Close() }()
+
+	t.Run("known size uses stream-scan", func(t *testing.T) {
+		data := []byte("known size")
+		result, err := client.StreamScanReader(context.Background(), bytes.NewReader(data), "known.txt", int64(len(data)))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !result.IsClean() {
+			t.Errorf("expected clean, got status %q", result.Status)
+		}
+		if hitPath != "/api/stream-scan" {
+			t.Errorf("path = %q, want %q", hitPath, "/api/stream-scan")
+		}
+	})
+
+	t.Run("unknown size uses stream-scan", func(t *testing.T) {
+		result, err := client.StreamScanReader(context.Background(), strings.NewReader("unknown size"), "unknown.txt", UnknownSize)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !result.IsClean() {
+			t.Errorf("expected clean, got status %q", result.Status)
+		}
+		if hitPath != "/api/stream-scan" {
+			t.Errorf("path = %q, want %q", hitPath, "/api/stream-scan")
+		}
+	})
+
+	t.Run("empty reader uses multipart", func(t *testing.T) {
+		if _, err := client.StreamScanReader(context.Background(), strings.NewReader(""), "empty.txt", UnknownSize); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if hitPath != "/api/scan" {
+			t.Errorf("path = %q, want %q", hitPath, "/api/scan")
+		}
+		if receivedFilename != "empty.txt" {
+			t.Errorf("filename = %q, want %q", receivedFilename, "empty.txt")
+		}
+	})
+}
+
 // --- Custom headers test ---
 
 func TestCustomHeaders(t *testing.T) {
@@ -677,6 +751,60 @@ func TestScanResultMethods(t *testing.T) {
 	if errResult.IsInfected() {
 		t.Error("IsInfected should be false for ERROR status")
 	}
+	if !errResult.IsError() {
+		t.Error("IsError should be true for ERROR status")
+	}
+	if clean.IsError() || infected.IsError() {
+		t.Error("IsError should be false for OK and FOUND statuses")
+	}
+}
+
+func TestScanResultErr(t *testing.T) {
+	transportErr := NewConnectionError("stream closed", nil)
+
+	tests := []struct {
+		name    string
+		result  *ScanResult
+		wantErr func(error) bool
+		wantMsg string
+	}{
+		{"clean", &ScanResult{Status: StatusOK}, nil, ""},
+		{"infected", &ScanResult{Status: StatusFound, Message: "Eicar-Test-Signature"}, nil, ""},
+		{"server error", &ScanResult{Status: StatusError, Message: "Can't open file"}, IsScanError, "Can't open file"},
+		{"server error without message", &ScanResult{Status: StatusError}, IsScanError, "scan failed"},
+		{"transport error", &ScanResult{Status: StatusError, Message: "stream closed", TransportErr: transportErr}, IsConnectionError, "stream closed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.result.Err()
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Errorf("Err() = %v, want nil", err)
+				}
+				return
+			}
+			if !tt.wantErr(err) || err.Error() != tt.wantMsg {
+				t.Errorf("Err() = %v, want %q of the expected type", err, tt.wantMsg)
+			}
+			var e *Error
+			if !errors.As(err, &e) {
+				t.Errorf("Err() = %T, want *Error", err)
+			}
+		})
+	}
+}
+
+func TestScanStatusValid(t *testing.T) {
+	for _, s := range []ScanStatus{StatusOK, StatusFound, StatusError} {
+		if !s.Valid() {
+			t.Errorf("%q should be valid", s)
+		}
+	}
+	for _, s := range []ScanStatus{"", "ok", "INFECTED"} {
+		if s.Valid() {
+			t.Errorf("%q should not be valid", s)
+		}
+	}
 }
 
 // --- Concurrent usage test ---
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	clamav "github.com/DevHatRo/clamav-api-sdk-go"
+	clamavgrpc "github.com/DevHatRo/clamav-api-sdk-go/grpc"
+)
+
+const (
+	defaultRESTURL  = "http://localhost:6000"
+	defaultGRPCAddr = "localhost:9000"
+)
+
+// config holds the connection and output flags shared by all commands.
+type config struct {
+	transport string
+	restURL   string
+	grpcAddr  string
+	timeout   time.Duration
+	format    string
+	formats   []string
+}
+
+// newFlagSet returns a flag set for the named command with the shared flags registered
+// on cfg. Defaults come from the environment. formats lists the accepted -format values,
+// the first being the default.
+func newFlagSet(name string, stderr io.Writer, cfg *config, formats ...string) *flag.FlagSet {
+	fs := flag.NewFlagSet("clamav-api "+name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	cfg.formats = formats
+	fs.StringVar(&cfg.transport, "transport", envOr("CLAMAV_TRANSPORT", "rest"), "transport: rest or grpc (env CLAMAV_TRANSPORT)")
+	fs.StringVar(&cfg.restURL, "url", envOr("CLAMAV_REST_URL", defaultRESTURL), "REST API base URL (env CLAMAV_REST_URL)")
+	fs.StringVar(&cfg.grpcAddr, "addr", envOr("CLAMAV_GRPC_ADDR", defaultGRPCAddr), "gRPC server address (env CLAMAV_GRPC_ADDR)")
+	fs.DurationVar(&cfg.timeout, "timeout", 0, "per-request timeout (default: client default)")
+	fs.StringVar(&cfg.format, "format", formats[0], "output format: "+strings.Join(formats, ", "))
+	return fs
+}
+
+// validate checks the flag values after parsing.
+func (c *config) validate() error {
+	if c.transport != "rest" && c.transport != "grpc" {
+		return fmt.Errorf("invalid transport %q: must be rest or grpc", c.transport)
+	}
+	for _, f := range c.formats {
+		if c.format == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid format %q: must be one of %s", c.format, strings.Join(c.formats, ", "))
+}
+
+// newScanner creates a client for the configured transport.
+func (c *config) newScanner() (clamav.Scanner, error) {
+	if c.transport == "grpc" {
+		return clamavgrpc.NewClient(c.grpcAddr, clamavgrpc.WithTimeout(c.timeout))
+	}
+	return clamav.NewClient(c.restURL, clamav.WithTimeout(c.timeout))
+}
+
+// envOr returns the value of the environment variable key, or def if it is unset or empty.
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// stringList is a flag that can be repeated or given as a comma-separated list.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			*l = append(*l, s)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+)
+
+// runHealth implements the health command. An unhealthy service exits with exitError,
+// like a service that cannot be reached.
+func runHealth(ctx context.Context, e *env, args []string) int {
+	var cfg config
+	fs := newFlagSet("health", e.stderr, &cfg, "text", "json")
+	if code, ok := parseFlags(fs, &cfg, e, args); !ok {
+		return code
+	}
+
+	scanner, err := cfg.newScanner()
+	if err != nil {
+		return e.fail(err)
+	}
+	defer func() { _ = scanner.Close() }()
+
+	health, err := scanner.HealthCheck(ctx)
+	if err != nil {
+		return e.fail(err)
+	}
+
+	if cfg.format == "json" {
+		_ = json.NewEncoder(e.stdout).Encode(struct {
+			Healthy bool   `json:"healthy"`
+			Message string `json:"message"`
+		}{health.Healthy, health.Message})
+	} else {
+		state := "healthy"
+		if !health.Healthy {
+			state = "unhealthy"
+		}
+		fmt.Fprintf(e.stdout, "%s: %s\n", state, health.Message)
+	}
+
+	if !health.Healthy {
+		return exitError
+	}
+	return exitOK
+}
+
+// runVersion implements the version command.
+func runVersion(ctx context.Context, e *env, args []string) int {
+	var cfg config
+	fs := newFlagSet("version", e.stderr, &cfg, "text", "json")
+	if code, ok := parseFlags(fs, &cfg, e, args); !ok {
+		return code
+	}
+
+	scanner, err := cfg.newScanner()
+	if err != nil {
+		return e.fail(err)
+	}
+	defer func() { _ = scanner.Close() }()
+
+	version, err := scanner.Version(ctx)
+	if err != nil {
+		return e.fail(err)
+	}
+
+	if cfg.format == "json" {
+		_ = json.NewEncoder(e.stdout).Encode(version)
+	} else {
+		fmt.Fprintf(e.stdout, "Version: %s\nCommit:  %s\nBuild:   %s\n", version.Version, version.Commit, version.Build)
+	}
+	return exitOK
+}
+
+// parseFlags parses args into fs and validates cfg. If the command must stop, it returns
+// the exit code and false.
+func parseFlags(fs *flag.FlagSet, cfg *config, e *env, args []string) (int, bool) {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK, false
+		}
+		return exitError, false
+	}
+	if err := cfg.validate(); err != nil {
+		return e.fail(err), false
+	}
+	return 0, true
+}
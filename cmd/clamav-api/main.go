@@ -0,0 +1,90 @@
+// Command clamav-api is a command-line client for the ClamAV API, built on the SDK.
+//
+// Usage:
+//
+//	clamav-api health  [flags]
+//	clamav-api version [flags]
+//	clamav-api scan    [flags] <file|dir|->...
+//	clamav-api watch   [flags] <dir>
+//
+// The transport and server address are taken from flags or from the CLAMAV_TRANSPORT,
+// CLAMAV_REST_URL and CLAMAV_GRPC_ADDR environment variables. Run a subcommand with -h
+// for its flags.
+//
+// Exit codes: 0 when everything is clean (or healthy), 1 when an infected file is found
+// (or the service is unhealthy), and 2 on errors. watch runs until interrupted and
+// exits with 0, or with 2 if watching fails.
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Exit codes.
+const (
+	exitOK       = 0
+	exitInfected = 1
+	exitError    = 2
+)
+
+const usage = `Usage: clamav-api <command> [flags] [args]
+
+Commands:
+  health    Check ClamAV service health
+  version   Print the ClamAV API server version
+  scan      Scan files, directories, or stdin ("-")
+  watch     Scan new and modified files of a directory as they appear
+
+Run "clamav-api <command> -h" for the flags of a command.
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// env holds the standard streams of a command.
+type env struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// run executes the command line args and returns the exit code.
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	e := &env{stdin: stdin, stdout: stdout, stderr: stderr}
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitError
+	}
+
+	switch args[0] {
+	case "health":
+		return runHealth(ctx, e, args[1:])
+	case "version":
+		return runVersion(ctx, e, args[1:])
+	case "scan":
+		return runScan(ctx, e, args[1:])
+	case "watch":
+		return runWatch(ctx, e, args[1:])
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	default:
+		fmt.Fprintf(stderr, "clamav-api: unknown command %q\n\n%s", args[0], usage)
+		return exitError
+	}
+}
+
+// fail prints err to stderr and returns exitError.
+func (e *env) fail(err error) int {
+	fmt.Fprintf(e.stderr, "clamav-api: %v\n", err)
+	return exitError
+}
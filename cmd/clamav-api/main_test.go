@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DevHatRo/clamav-api-sdk-go/internal/testutil"
+)
+
+// newMockAPI starts a mock ClamAV API that reports content containing "EICAR" as infected.
+func newMockAPI(t *testing.T) string {
+	t.Helper()
+	check := func(data []byte, _ string) (int, interface{}) {
+		if bytes.Contains(data, []byte("EICAR")) {
+			return http.StatusOK, testutil.InfectedScanResponse()
+		}
+		return http.StatusOK, testutil.CleanScanResponse()
+	}
+	srv := testutil.NewMockServer(map[string]http.HandlerFunc{
+		"/api/health-check": testutil.JSONHandler(http.StatusOK, map[string]string{"message": "ok"}),
+		"/api/version":      testutil.JSONHandler(http.StatusOK, map[string]string{"version": "1.2.3", "commit": "abc", "build": "now"}),
+		"/api/scan":         testutil.ScanHandler(check),
+		"/api/stream-scan":  testutil.ScanHandler(check),
+	})
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+// runCLI runs the command line and returns the exit code, stdout and stderr.
+func runCLI(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// scanTree creates a directory with a clean and an infected file.
+func scanTree(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "clean.txt"), []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "eicar.com"), []byte("EICAR"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// --- Command tests ---
+
+func TestHealthCommand(t *testing.T) {
+	url := newMockAPI(t)
+
+	code, out, _ := runCLI(t, "", "health", "-url", url)
+	if code != exitOK || out != "healthy: ok\n" {
+		t.Errorf("code = %d, out = %q", code, out)
+	}
+
+	code, out, _ = runCLI(t, "", "health", "-url", url, "-format", "json")
+	if code != exitOK || strings.TrimSpace(out) != `{"healthy":true,"message":"ok"}` {
+		t.Errorf("code = %d, out = %q", code, out)
+	}
+
+	unhealthy := testutil.NewMockServer(map[string]http.HandlerFunc{
+		"/api/health-check": testutil.JSONHandler(http.StatusServiceUnavailable, map[string]string{"message": "clamd not ready"}),
+	})
+	t.Cleanup(unhealthy.Close)
+	code, out, _ = runCLI(t, "", "health", "-url", unhealthy.URL)
+	if code != exitError || out != "unhealthy: clamd not ready\n" {
+		t.Errorf("unhealthy: code = %d, out = %q, want exit code %d", code, out, exitError)
+	}
+}
+
+func TestVersionCommand(t *testing.T) {
+	t.Setenv("CLAMAV_REST_URL", newMockAPI(t))
+
+	code, out, _ := runCLI(t, "", "version")
+	if code != exitOK || !strings.Contains(out, "Version: 1.2.3") {
+		t.Errorf("code = %d, out = %q", code, out)
+	}
+}
+
+func TestScanCommand(t *testing.T) {
+	url := newMockAPI(t)
+	dir := scanTree(t)
+	clean := filepath.Join(dir, "clean.txt")
+
+	t.Run("clean file", func(t *testing.T) {
+		code, out, _ := runCLI(t, "", "scan", "-url", url, clean)
+		if code != exitOK || !strings.Contains(out, clean+": clean") {
+			t.Errorf("code = %d, out = %q", code, out)
+		}
+	})
+
+	t.Run("directory with infected file", func(t *testing.T) {
+		code, out, _ := runCLI(t, "", "scan", "-url", url, dir)
+		if code != exitInfected {
+			t.Errorf("code = %d, want %d", code, exitInfected)
+		}
+		if !strings.Contains(out, filepath.Join(dir, "sub", "eicar.com")+": infected (Eicar-Test-Signature)") {
+			t.Errorf("out = %q", out)
+		}
+		if !strings.Contains(out, "2 scanned, 1 clean, 1 infected, 0 errors") {
+			t.Errorf("missing summary in %q", out)
+		}
+	})
+
+	t.Run("stdin", func(t *testing.T) {
+		code, out, _ := runCLI(t, "EICAR", "scan", "-url", url, "-format", "ndjson", "-")
+		if code != exitInfected {
+			t.Errorf("code = %d, want %d", code, exitInfected)
+		}
+		var rec record
+		if err := json.Unmarshal([]byte(out), &rec); err != nil {
+			t.Fatalf("invalid NDJSON %q: %v", out, err)
+		}
+		if rec.Path != "stdin" || rec.Status != "FOUND" || rec.Virus != "Eicar-Test-Signature" {
+			t.Errorf("record = %+v", rec)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		code, out, _ := runCLI(t, "", "scan", "-url", url, "-format", "json", "-exclude", "sub", dir)
+		if code != exitOK {
+			t.Errorf("code = %d, want %d", code, exitOK)
+		}
+		var doc struct {
+			Results []record      `json:"results"`
+			Summary summaryRecord `json:"summary"`
+		}
+		if err := json.Unmarshal([]byte(out), &doc); err != nil {
+			t.Fatalf("invalid JSON %q: %v", out, err)
+		}
+		if len(doc.Results) != 1 || doc.Summary.Scanned != 1 || doc.Summary.Clean != 1 {
+			t.Errorf("doc = %+v", doc)
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		code, out, _ := runCLI(t, "", "scan", "-url", url, filepath.Join(dir, "missing"))
+		if code != exitError || !strings.Contains(out, "error: failed to stat file") {
+			t.Errorf("code = %d, out = %q", code, out)
+		}
+	})
+
+	t.Run("unreachable server", func(t *testing.T) {
+		code, out, _ := runCLI(t, "", "scan", "-url", "http://127.0.0.1:1", clean)
+		if code != exitError || !strings.Contains(out, ": error: ") {
+			t.Errorf("code = %d, out = %q", code, out)
+		}
+	})
+
+	t.Run("no arguments", func(t *testing.T) {
+		code, _, stderr := runCLI(t, "", "scan", "-url", url)
+		if code != exitError || !strings.Contains(stderr, "Usage: clamav-api scan") {
+			t.Errorf("code = %d, stderr = %q", code, stderr)
+		}
+	})
+}
+
+func TestScanCommandClamscan(t *testing.T) {
+	url := newMockAPI(t)
+	dir := scanTree(t)
+	eicar := filepath.Join(dir, "sub", "eicar.com")
+
+	t.Run("output and summary", func(t *testing.T) {
+		code, out, _ := runCLI(t, "", "scan", "-url", url, "-format", "clamscan", dir)
+		if code != exitInfected {
+			t.Errorf("code = %d, want %d", code, exitInfected)
+		}
+		for _, want := range []string{
+			filepath.Join(dir, "clean.txt") + ": OK\n",
+			eicar + ": Eicar-Test-Signature FOUND\n",
+			"\n----------- SCAN SUMMARY -----------\nEngine version: 1.2.3\n",
+			"Scanned directories: 2\n",
+			"Scanned files: 2\n",
+			"Infected files: 1\n",
+			"Data scanned: 0.00 MB\n",
+			"Start Date: ",
+			"End Date:   ",
+		} {
+			if !strings.Contains(out, want) {
+				t.Errorf("output missing %q:\n%s", want, out)
+			}
+		}
+		if strings.Contains(out, "Total errors") {
+			t.Errorf("unexpected error count in:\n%s", out)
+		}
+	})
+
+	t.Run("infected only without summary", func(t *testing.T) {
+		code, out, _ := runCLI(t, "", "scan", "-url", url, "-format", "clamscan", "-i", "-no-summary", dir)
+		if code != exitInfected || out != eicar+": Eicar-Test-Signature FOUND\n" {
+			t.Errorf("code = %d, out = %q", code, out)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		missing := filepath.Join(dir, "missing")
+		code, out, _ := runCLI(t, "", "scan", "-url", url, "-format", "clamscan", missing, eicar)
+		if code != exitError {
+			t.Errorf("code = %d, want %d (errors take precedence)", code, exitError)
+		}
+		if !strings.Contains(out, missing+": failed to stat file") || !strings.Contains(out, " ERROR\n") {
+			t.Errorf("missing error line in:\n%s", out)
+		}
+		if !strings.Contains(out, "Scanned files: 1\n") || !strings.Contains(out, "Total errors: 1\n") {
+			t.Errorf("unexpected summary in:\n%s", out)
+		}
+	})
+}
+
+func TestScanCommandReports(t *testing.T) {
+	url := newMockAPI(t)
+	dir := scanTree(t)
+
+	t.Run("sarif", func(t *testing.T) {
+		code, out, _ := runCLI(t, "", "scan", "-url", url, "-format", "sarif", dir)
+		if code != exitInfected {
+			t.Errorf("code = %d, want %d", code, exitInfected)
+		}
+		var log struct {
+			Version string `json:"version"`
+			Runs    []struct {
+				Results []struct {
+					RuleID string `json:"ruleId"`
+				} `json:"results"`
+			} `json:"runs"`
+		}
+		if err := json.Unmarshal([]byte(out), &log); err != nil {
+			t.Fatalf("invalid SARIF %q: %v", out, err)
+		}
+		if log.Version != "2.1.0" || len(log.Runs) != 1 || len(log.Runs[0].Results) != 1 ||
+			log.Runs[0].Results[0].RuleID != "Eicar-Test-Signature" {
+			t.Errorf("log = %+v", log)
+		}
+	})
+
+	t.Run("junit", func(t *testing.T) {
+		code, out, _ := runCLI(t, "", "scan", "-url", url, "-format", "junit", dir)
+		if code != exitInfected {
+			t.Errorf("code = %d, want %d", code, exitInfected)
+		}
+		if !strings.Contains(out, `<testsuites name="clamav" tests="2" failures="1" errors="0"`) ||
+			!strings.Contains(out, `<failure message="Eicar-Test-Signature" type="FOUND">`) {
+			t.Errorf("unexpected JUnit output:\n%s", out)
+		}
+	})
+}
+
+// syncBuffer is a bytes.Buffer that can be read while a command writes to it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestWatchCommand(t *testing.T) {
+	url := newMockAPI(t)
+	dir := t.TempDir()
+	qdir := filepath.Join(t.TempDir(), "quarantine")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var stdout, stderr syncBuffer
+	done := make(chan int, 1)
+	go func() {
+		done <- run(ctx, []string{"watch", "-url", url, "-format", "ndjson", "-stable", "50ms", "-quarantine", qdir, dir},
+			strings.NewReader(""), &stdout, &stderr)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	eicar := filepath.Join(dir, "eicar.com")
+	if err := os.WriteFile(eicar, []byte("EICAR"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if !waitUntil(func() bool { return strings.Contains(stdout.String(), "FOUND") }) {
+		t.Fatalf("no result in %q (stderr %q)", stdout.String(), stderr.String())
+	}
+	cancel()
+	if code := <-done; code != exitOK {
+		t.Errorf("code = %d, stderr = %q", code, stderr.String())
+	}
+
+	var rec record
+	if err := json.Unmarshal([]byte(stdout.String()), &rec); err != nil {
+		t.Fatalf("invalid NDJSON %q: %v", stdout.String(), err)
+	}
+	if rec.Path != eicar || rec.Virus != "Eicar-Test-Signature" {
+		t.Errorf("record = %+v", rec)
+	}
+	if _, err := os.Stat(eicar); !os.IsNotExist(err) {
+		t.Errorf("infected file not quarantined: %v", err)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(qdir, "*.json")); len(matches) != 1 {
+		t.Errorf("got %d quarantine entries, want 1", len(matches))
+	}
+}
+
+// waitUntil polls cond for up to 5 seconds.
+func waitUntil(cond func() bool) bool {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if cond() {
+			return true
+		}
+	}
+	return false
+}
+
+func TestUsageErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"no command", nil, "Usage: clamav-api <command>"},
+		{"unknown command", []string{"frobnicate"}, `unknown command "frobnicate"`},
+		{"invalid transport", []string{"health", "-transport", "smtp"}, `invalid transport "smtp"`},
+		{"invalid format", []string{"version", "-format", "ndjson"}, `invalid format "ndjson"`},
+		{"watch without directory", []string{"watch"}, "Usage: clamav-api watch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, stderr := runCLI(t, "", tt.args...)
+			if code != exitError || !strings.Contains(stderr, tt.want) {
+				t.Errorf("code = %d, stderr = %q, want %q", code, stderr, tt.want)
+			}
+		})
+	}
+}
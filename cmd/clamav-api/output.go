@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	clamav "github.com/DevHatRo/clamav-api-sdk-go"
+	"github.com/DevHatRo/clamav-api-sdk-go/report"
+)
+
+// reporter writes scan results in one output format.
+type reporter interface {
+	// report writes or records one result.
+	report(r *clamav.FileResult)
+	// finish writes anything that follows the results, such as a summary.
+	finish(s *clamav.DirSummary) error
+}
+
+// outputOptions are the scan flags that affect the output.
+type outputOptions struct {
+	infectedOnly bool
+	noSummary    bool
+	// engineVersion is the version reported by the scanner, for the clamscan summary.
+	engineVersion string
+}
+
+// newReporter returns the reporter for a validated format name.
+func newReporter(format string, w io.Writer, opts outputOptions) reporter {
+	switch format {
+	case "clamscan":
+		return &clamscanReporter{w: w, opts: opts, start: time.Now()}
+	case "sarif":
+		return &fileReporter{w: w, write: report.WriteSARIF}
+	case "junit":
+		return &fileReporter{w: w, write: report.WriteJUnit}
+	case "json":
+		return &jsonReporter{w: w, results: []record{}}
+	case "ndjson":
+		return &ndjsonReporter{enc: json.NewEncoder(w)}
+	default:
+		return &textReporter{w: w, opts: opts}
+	}
+}
+
+// record is the JSON representation of one result.
+type record struct {
+	Path     string  `json:"path"`
+	Status   string  `json:"status"`
+	Virus    string  `json:"virus,omitempty"`
+	Error    string  `json:"error,omitempty"`
+	Size     int64   `json:"size"`
+	ScanTime float64 `json:"scan_time,omitempty"`
+}
+
+func newRecord(r *clamav.FileResult) record {
+	rec := record{Path: r.Path, Size: r.Size, Status: "ERROR", Error: errorMessage(r)}
+	if r.Err == nil {
+		rec.Status, rec.ScanTime = string(r.Result.Status), r.Result.ScanTime
+		if r.Result.IsInfected() {
+			rec.Virus = r.Result.Message
+		}
+	}
+	return rec
+}
+
+// summaryRecord is the JSON representation of the summary.
+type summaryRecord struct {
+	Scanned  int     `json:"scanned"`
+	Clean    int     `json:"clean"`
+	Infected int     `json:"infected"`
+	Errors   int     `json:"errors"`
+	Skipped  int     `json:"skipped"`
+	Bytes    int64   `json:"bytes"`
+	Duration float64 `json:"duration"`
+}
+
+func newSummaryRecord(s *clamav.DirSummary) summaryRecord {
+	return summaryRecord{
+		Scanned:  s.Scanned,
+		Clean:    s.Clean,
+		Infected: s.Infected,
+		Errors:   s.Errored,
+		Skipped:  s.Skipped,
+		Bytes:    s.Bytes,
+		Duration: s.Duration.Seconds(),
+	}
+}
+
+// textReporter writes one line per file and a summary line.
+type textReporter struct {
+	w    io.Writer
+	opts outputOptions
+}
+
+func (t *textReporter) report(r *clamav.FileResult) {
+	switch rec := newRecord(r); rec.Status {
+	case "OK":
+		if !t.opts.infectedOnly {
+			fmt.Fprintf(t.w, "%s: clean\n", rec.Path)
+		}
+	case "FOUND":
+		fmt.Fprintf(t.w, "%s: infected (%s)\n", rec.Path, rec.Virus)
+	default:
+		fmt.Fprintf(t.w, "%s: error: %s\n", rec.Path, rec.Error)
+	}
+}
+
+func (t *textReporter) finish(s *clamav.DirSummary) error {
+	if t.opts.noSummary {
+		return nil
+	}
+	_, err := fmt.Fprintf(t.w, "\n%d scanned, %d clean, %d infected, %d errors, %d skipped (%d bytes in %.3fs)\n",
+		s.Scanned, s.Clean, s.Infected, s.Errored, s.Skipped, s.Bytes, s.Duration.Seconds())
+	return err
+}
+
+// jsonReporter writes a single JSON document with all results and the summary.
+type jsonReporter struct {
+	w       io.Writer
+	results []record
+}
+
+func (j *jsonReporter) report(r *clamav.FileResult) {
+	j.results = append(j.results, newRecord(r))
+}
+
+func (j *jsonReporter) finish(s *clamav.DirSummary) error {
+	enc := json.NewEncoder(j.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Results []record      `json:"results"`
+		Summary summaryRecord `json:"summary"`
+	}{j.results, newSummaryRecord(s)})
+}
+
+// ndjsonReporter writes one JSON object per line as results arrive.
+type ndjsonReporter struct {
+	enc *json.Encoder
+}
+
+func (n *ndjsonReporter) report(r *clamav.FileResult) {
+	_ = n.enc.Encode(newRecord(r))
+}
+
+func (n *ndjsonReporter) finish(*clamav.DirSummary) error { return nil }
+
+// fileReporter collects the results and writes them with a report package writer.
+// Paths are made relative to the working directory.
+type fileReporter struct {
+	w       io.Writer
+	write   func(io.Writer, []*clamav.FileResult, report.Options) error
+	results []*clamav.FileResult
+}
+
+func (f *fileReporter) report(r *clamav.FileResult) {
+	f.results = append(f.results, r)
+}
+
+func (f *fileReporter) finish(*clamav.DirSummary) error {
+	wd, _ := os.Getwd()
+	return f.write(f.w, f.results, report.Options{BaseDir: wd})
+}
+
+// clamscanReporter reproduces the output of clamscan, so scripts that parse it keep working:
+// "path: OK", "path: Signature FOUND", "path: message ERROR", then the SCAN SUMMARY block.
+// The summary has no "Known viruses" line, as no endpoint reports the signature count.
+type clamscanReporter struct {
+	w     io.Writer
+	opts  outputOptions
+	start time.Time
+}
+
+func (c *clamscanReporter) report(r *clamav.FileResult) {
+	switch rec := newRecord(r); rec.Status {
+	case "OK":
+		if !c.opts.infectedOnly {
+			fmt.Fprintf(c.w, "%s: OK\n", rec.Path)
+		}
+	case "FOUND":
+		fmt.Fprintf(c.w, "%s: %s FOUND\n", rec.Path, rec.Virus)
+	default:
+		fmt.Fprintf(c.w, "%s: %s ERROR\n", rec.Path, rec.Error)
+	}
+}
+
+func (c *clamscanReporter) finish(s *clamav.DirSummary) error {
+	if c.opts.noSummary {
+		return nil
+	}
+
+	end := time.Now()
+	elapsed := end.Sub(c.start)
+	mb := float64(s.Bytes) / (1 << 20)
+	ratio := 0.0
+	if s.Bytes > 0 {
+		ratio = 1
+	}
+
+	fmt.Fprintf(c.w, "\n----------- SCAN SUMMARY -----------\n")
+	if c.opts.engineVersion != "" {
+		fmt.Fprintf(c.w, "Engine version: %s\n", c.opts.engineVersion)
+	}
+	fmt.Fprintf(c.w, "Scanned directories: %d\n", s.Directories)
+	fmt.Fprintf(c.w, "Scanned files: %d\n", s.Scanned-s.Errored)
+	fmt.Fprintf(c.w, "Infected files: %d\n", s.Infected)
+	if s.Errored > 0 {
+		fmt.Fprintf(c.w, "Total errors: %d\n", s.Errored)
+	}
+	fmt.Fprintf(c.w, "Data scanned: %.2f MB\n", mb)
+	fmt.Fprintf(c.w, "Data read: %.2f MB (ratio %.2f:1)\n", mb, ratio)
+	fmt.Fprintf(c.w, "Time: %.3f sec (%d m %d s)\n", elapsed.Seconds(), int(elapsed.Minutes()), int(elapsed.Seconds())%60)
+	fmt.Fprintf(c.w, "Start Date: %s\n", c.start.Format(clamscanDateFormat))
+	_, err := fmt.Fprintf(c.w, "End Date:   %s\n", end.Format(clamscanDateFormat))
+	return err
+}
+
+// clamscanDateFormat is the date layout of the clamscan summary.
+const clamscanDateFormat = "2006:01:02 15:04:05"
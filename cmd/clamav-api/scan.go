@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	clamav "github.com/DevHatRo/clamav-api-sdk-go"
+)
+
+// runScan implements the scan command.
+func runScan(ctx context.Context, e *env, args []string) int {
+	var cfg config
+	var dirOpts clamav.DirScanOptions
+	var include, exclude stringList
+
+	var outOpts outputOptions
+
+	fs := newFlagSet("scan", e.stderr, &cfg, "text", "json", "ndjson", "clamscan", "sarif", "junit")
+	fs.Var(&include, "include", "only scan files matching this glob in directories (repeatable)")
+	fs.Var(&exclude, "exclude", "skip files and directories matching this glob (repeatable)")
+	fs.Int64Var(&dirOpts.MaxFileSize, "max-size", 0, "skip files in directories larger than this many bytes (0: no limit)")
+	fs.IntVar(&dirOpts.Concurrency, "concurrency", 4, "number of files scanned at once in directories")
+	fs.BoolVar(&dirOpts.FollowSymlinks, "follow-symlinks", false, "scan files that symlinks in directories point to")
+	fs.BoolVar(&outOpts.infectedOnly, "infected", false, "only print infected files and errors")
+	fs.BoolVar(&outOpts.infectedOnly, "i", false, "shorthand for -infected")
+	fs.BoolVar(&outOpts.noSummary, "no-summary", false, "omit the summary (text and clamscan formats)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: clamav-api scan [flags] <file|dir|->...\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	if code, ok := parseFlags(fs, &cfg, e, args); !ok {
+		return code
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitError
+	}
+	dirOpts.Include, dirOpts.Exclude = include, exclude
+
+	scanner, err := cfg.newScanner()
+	if err != nil {
+		return e.fail(err)
+	}
+	defer func() { _ = scanner.Close() }()
+
+	if cfg.format == "clamscan" && !outOpts.noSummary {
+		if v, err := scanner.Version(ctx); err == nil {
+			outOpts.engineVersion = v.Version
+		}
+	}
+	out := newReporter(cfg.format, e.stdout, outOpts)
+	t := &tally{}
+	start := time.Now()
+	report := func(r *clamav.FileResult) {
+		t.add(r)
+		out.report(r)
+	}
+
+	var failed bool
+	for _, target := range fs.Args() {
+		if err := scanTarget(ctx, scanner, e, target, &dirOpts, t, report); err != nil {
+			fmt.Fprintf(e.stderr, "clamav-api: %v\n", err)
+			failed = true
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	t.Duration = time.Since(start)
+	if err := out.finish(&t.DirSummary); err != nil {
+		return e.fail(err)
+	}
+
+	switch {
+	case failed || t.Errored > 0:
+		return exitError
+	case t.Infected > 0:
+		return exitInfected
+	default:
+		return exitOK
+	}
+}
+
+// scanTarget scans one command-line argument: "-" for stdin, a directory, or a file.
+// Per-file failures are reported as results; the returned error is for failures that
+// stop the whole target, such as a canceled directory scan.
+func scanTarget(ctx context.Context, s clamav.Scanner, e *env, target string, opts *clamav.DirScanOptions, t *tally, report func(*clamav.FileResult)) error {
+	if target == "-" {
+		r := &clamav.FileResult{Path: "stdin"}
+		r.Result, r.Err = s.StreamScanReader(ctx, e.stdin, "stdin", clamav.UnknownSize)
+		report(r)
+		return nil
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		report(&clamav.FileResult{Path: target, Err: clamav.NewValidationError("failed to stat file", err)})
+		return nil
+	}
+
+	if info.IsDir() {
+		summary, err := clamav.ScanDir(ctx, s, target, opts, report)
+		if summary != nil {
+			t.Skipped += summary.Skipped
+			t.Directories += summary.Directories
+		}
+		return err
+	}
+
+	r := &clamav.FileResult{Path: target, Size: info.Size()}
+	if !info.Mode().IsRegular() {
+		r.Err = clamav.NewValidationError("not a regular file", nil)
+		report(r)
+		return nil
+	}
+
+	f, err := os.Open(target)
+	if err != nil {
+		r.Err = clamav.NewValidationError("failed to open file", err)
+		report(r)
+		return nil
+	}
+	defer func() { _ = f.Close() }()
+
+	r.Result, r.Err = s.StreamScanReader(ctx, f, filepath.Base(target), info.Size())
+	report(r)
+	return nil
+}
+
+// tally aggregates results across all targets.
+type tally struct {
+	clamav.DirSummary
+}
+
+// add counts one result.
+func (t *tally) add(r *clamav.FileResult) {
+	t.Scanned++
+	t.Bytes += r.Size
+	switch {
+	case r.Err != nil || r.Result.IsError():
+		t.Errored++
+	case r.Result.IsInfected():
+		t.Infected++
+	case r.Result.IsClean():
+		t.Clean++
+	}
+}
+
+// errorMessage returns the error text of a failed result, or "" if it did not fail.
+func errorMessage(r *clamav.FileResult) string {
+	if r.Err != nil {
+		return r.Err.Error()
+	}
+	if r.Result.IsError() {
+		return r.Result.Message
+	}
+	return ""
+}
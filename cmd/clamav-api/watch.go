@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	clamav "github.com/DevHatRo/clamav-api-sdk-go"
+	"github.com/DevHatRo/clamav-api-sdk-go/quarantine"
+	"github.com/DevHatRo/clamav-api-sdk-go/watch"
+)
+
+// runWatch implements the watch command.
+func runWatch(ctx context.Context, e *env, args []string) int {
+	var cfg config
+	var opts watch.Options
+	var include, exclude stringList
+	var outOpts outputOptions
+	var quarantineDir string
+
+	fs := newFlagSet("watch", e.stderr, &cfg, "text", "ndjson", "clamscan")
+	fs.BoolVar(&opts.Recursive, "recursive", false, "also watch subdirectories")
+	fs.Var(&include, "include", "only scan files matching this glob (repeatable)")
+	fs.Var(&exclude, "exclude", "skip files and directories matching this glob (repeatable)")
+	fs.DurationVar(&opts.StableFor, "stable", 2*time.Second, "scan files once unchanged for this long")
+	fs.BoolVar(&opts.Poll, "poll", false, "poll the directory instead of using inotify")
+	fs.DurationVar(&opts.PollInterval, "poll-interval", time.Second, "interval between directory walks when polling")
+	fs.BoolVar(&opts.ScanExisting, "scan-existing", false, "also scan the files present at startup")
+	fs.IntVar(&opts.Concurrency, "concurrency", 4, "number of files scanned at once")
+	fs.StringVar(&quarantineDir, "quarantine", "", "move infected files into this quarantine directory")
+	fs.BoolVar(&outOpts.infectedOnly, "infected", false, "only print infected files and errors")
+	fs.BoolVar(&outOpts.infectedOnly, "i", false, "shorthand for -infected")
+	fs.BoolVar(&outOpts.noSummary, "no-summary", false, "omit the summary on exit (text and clamscan formats)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: clamav-api watch [flags] <dir>\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	if code, ok := parseFlags(fs, &cfg, e, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitError
+	}
+	opts.Include, opts.Exclude = include, exclude
+
+	scanner, err := cfg.newScanner()
+	if err != nil {
+		return e.fail(err)
+	}
+	defer func() { _ = scanner.Close() }()
+
+	if quarantineDir != "" {
+		var qopts []quarantine.Option
+		if v, err := scanner.Version(ctx); err == nil {
+			qopts = append(qopts, quarantine.WithScannerVersion(v.Version))
+		}
+		store, err := quarantine.Open(quarantineDir, qopts...)
+		if err != nil {
+			return e.fail(err)
+		}
+		opts.OnInfected = store.OnInfected
+	}
+
+	w, err := watch.New(scanner, fs.Arg(0), &opts)
+	if err != nil {
+		return e.fail(err)
+	}
+
+	out := newReporter(cfg.format, e.stdout, outOpts)
+	t := &tally{}
+	start := time.Now()
+	err = w.Run(ctx, func(r *clamav.FileResult) {
+		t.add(r)
+		out.report(r)
+		if r.HookErr != nil {
+			fmt.Fprintf(e.stderr, "clamav-api: %s: not quarantined: %v\n", r.Path, r.HookErr)
+		}
+	})
+
+	t.Duration = time.Since(start)
+	if finishErr := out.finish(&t.DirSummary); finishErr != nil && err == nil {
+		err = finishErr
+	}
+	if err != nil {
+		return e.fail(err)
+	}
+	return exitOK
+}
@@ -0,0 +1,319 @@
+package clamav
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DevHatRo/clamav-api-sdk-go/internal/pathmatch"
+)
+
+const defaultDirScanConcurrency = 4
+
+// DirScanOptions configures ScanDir and ScanFS. The zero value scans every regular
+// file, skips symlinks, and runs 4 scans at a time.
+type DirScanOptions struct {
+	// Include, if non-empty, limits scanning to files matching at least one pattern.
+	// Patterns use path.Match syntax and are matched against both the slash-separated
+	// path relative to the root and the base name, so "*.pdf" matches at any depth.
+	Include []string
+	// Exclude skips files and directories matching any pattern (same syntax as Include).
+	// An excluded directory is not descended into.
+	Exclude []string
+	// MaxFileSize skips files larger than this many bytes. Zero means no limit.
+	MaxFileSize int64
+	// FollowSymlinks scans regular files that symlinks point to. Symlinks to
+	// directories are never followed, which rules out cycles. ScanDir skips symlinks
+	// that resolve outside dir; with ScanFS, where links lead is up to fsys (os.DirFS
+	// follows them anywhere).
+	FollowSymlinks bool
+	// Concurrency is the number of files scanned at once (default: 4).
+	Concurrency int
+	// OnInfected, if set, is called for each infected file once it has been scanned and
+	// closed, before the result is delivered. It runs on the scanning goroutines, so it
+	// must be safe for concurrent use. Its error is recorded in FileResult.HookErr.
+	// See the quarantine package for a hook that moves infected files aside.
+	OnInfected func(ctx context.Context, r *FileResult) error
+}
+
+// FileResult is the outcome of scanning one file of a directory scan.
+type FileResult struct {
+	// Path is the file path: joined with the root directory for ScanDir,
+	// or the slash-separated fs.FS path for ScanFS.
+	Path string
+	// Size is the file size in bytes.
+	Size int64
+	// Result is the scan result, or nil if Err is set.
+	Result *ScanResult
+	// Err is set when the file could not be read or scanned. It is always a *Error.
+	Err error
+	// HookErr is the error returned by DirScanOptions.OnInfected, if any.
+	HookErr error
+}
+
+// DirSummary aggregates the outcome of a directory scan.
+type DirSummary struct {
+	// Scanned is the number of files sent to the scanner (or failed while opening).
+	Scanned int
+	// Clean is the number of files with Status "OK".
+	Clean int
+	// Infected is the number of files with Status "FOUND".
+	Infected int
+	// Errored is the number of files with an error or Status "ERROR".
+	Errored int
+	// Skipped is the number of entries skipped by filters, size limit, or file type.
+	Skipped int
+	// Directories is the number of directories walked, including the root.
+	Directories int
+	// Bytes is the total size of the scanned files.
+	Bytes int64
+	// Duration is the wall-clock time of the whole scan.
+	Duration time.Duration
+}
+
+// ScanDir recursively scans the regular files under dir with s. See ScanFS.
+func ScanDir(ctx context.Context, s Scanner, dir string, opts *DirScanOptions, fn func(*FileResult)) (*DirSummary, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, NewValidationError(fmt.Sprintf("failed to stat directory: %s", dir), err)
+	}
+	if !info.IsDir() {
+		return nil, NewValidationError(fmt.Sprintf("not a directory: %s", dir), nil)
+	}
+
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, NewValidationError(fmt.Sprintf("failed to resolve directory: %s", dir), err)
+	}
+	displayPath := func(name string) string {
+		return filepath.Join(dir, filepath.FromSlash(name))
+	}
+	return scanFS(ctx, s, os.DirFS(dir), opts, displayPath, func(name string) bool {
+		return withinRoot(root, displayPath(name))
+	}, fn)
+}
+
+// withinRoot reports whether the symlink at p resolves inside root. Links that cannot
+// be resolved are reported as inside, for the scan to report the error.
+func withinRoot(root, p string) bool {
+	target, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return true
+	}
+	rel, err := filepath.Rel(root, target)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// ScanFS recursively scans the regular files of fsys with s, using StreamScanReader.
+//
+// Files are filtered by opts (which may be nil) and scanned with bounded concurrency.
+// Devices, pipes, sockets and other special files are always skipped. fn, if non-nil,
+// receives one FileResult per scanned file as soon as it completes; it is called from
+// a single goroutine. Walk errors below the root, such as unreadable directories, are
+// reported through fn as well; if the root itself cannot be listed, the summary is
+// returned with a validation error.
+//
+// The returned summary covers every file processed. If ctx is canceled, the scan stops
+// early and the partial summary is returned with a timeout error.
+func ScanFS(ctx context.Context, s Scanner, fsys fs.FS, opts *DirScanOptions, fn func(*FileResult)) (*DirSummary, error) {
+	return scanFS(ctx, s, fsys, opts, func(name string) string { return name }, nil, fn)
+}
+
+// dirScanJob is a file selected for scanning.
+type dirScanJob struct {
+	name string
+	size int64
+	err  error
+}
+
+// followLink, if non-nil, reports whether the symlink at name may be followed.
+func scanFS(ctx context.Context, s Scanner, fsys fs.FS, opts *DirScanOptions, displayPath func(string) string, followLink func(string) bool, fn func(*FileResult)) (*DirSummary, error) {
+	if opts == nil {
+		opts = &DirScanOptions{}
+	}
+	if pattern, err := pathmatch.Validate(append(append([]string(nil), opts.Include...), opts.Exclude...)); err != nil {
+		return nil, NewValidationError(fmt.Sprintf("invalid pattern: %q", pattern), err)
+	}
+	if _, err := fs.Stat(fsys, "."); err != nil {
+		return nil, NewValidationError("failed to read root directory", err)
+	}
+
+	workers := opts.Concurrency
+	if workers <= 0 {
+		workers = defaultDirScanConcurrency
+	}
+
+	start := time.Now()
+	summary := &DirSummary{}
+	var skipped, dirs int
+	var rootErr error
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan dirScanJob)
+	go func() {
+		defer close(jobs)
+		// Only an error listing the root is returned by the callback; the others are
+		// reported per entry.
+		rootErr = fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+			if ctx.Err() != nil {
+				return fs.SkipAll
+			}
+			if err != nil {
+				if name == "." {
+					return err
+				}
+				return sendJob(ctx, jobs, dirScanJob{name: name, err: err})
+			}
+
+			job, ok, walkErr := selectEntry(fsys, name, d, opts, followLink)
+			if !ok {
+				switch {
+				case !d.IsDir():
+					skipped++
+				case walkErr == nil:
+					dirs++
+				}
+				return walkErr
+			}
+			return sendJob(ctx, jobs, job)
+		})
+	}()
+
+	results := make(chan *FileResult)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				res := scanEntry(ctx, s, fsys, job, displayPath)
+				if opts.OnInfected != nil && res.Result != nil && res.Result.IsInfected() {
+					res.HookErr = opts.OnInfected(ctx, res)
+				}
+				results <- res
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for res := range results {
+		summary.Scanned++
+		summary.Bytes += res.Size
+		switch {
+		case res.Err != nil || res.Result.IsError():
+			summary.Errored++
+		case res.Result.IsInfected():
+			summary.Infected++
+		case res.Result.IsClean():
+			summary.Clean++
+		}
+		if fn != nil {
+			fn(res)
+		}
+	}
+
+	// The walker has exited once jobs is closed and drained, so the counts and rootErr
+	// are final.
+	summary.Skipped = skipped
+	summary.Directories = dirs
+	summary.Duration = time.Since(start)
+
+	if err := ctx.Err(); err != nil {
+		return summary, NewTimeoutError("directory scan canceled", err)
+	}
+	if rootErr != nil {
+		return summary, NewValidationError("failed to read root directory", rootErr)
+	}
+	return summary, nil
+}
+
+// sendJob hands a job to the workers unless ctx is done.
+func sendJob(ctx context.Context, jobs chan<- dirScanJob, job dirScanJob) error {
+	select {
+	case jobs <- job:
+		return nil
+	case <-ctx.Done():
+		return fs.SkipAll
+	}
+}
+
+// selectEntry applies the filters to a walked entry. It reports whether the entry is
+// a file to scan, and returns fs.SkipDir for excluded directories.
+func selectEntry(fsys fs.FS, name string, d fs.DirEntry, opts *DirScanOptions, followLink func(string) bool) (dirScanJob, bool, error) {
+	if name != "." && pathmatch.Any(opts.Exclude, name) {
+		if d.IsDir() {
+			return dirScanJob{}, false, fs.SkipDir
+		}
+		return dirScanJob{}, false, nil
+	}
+	if d.IsDir() {
+		return dirScanJob{}, false, nil
+	}
+	if len(opts.Include) > 0 && !pathmatch.Any(opts.Include, name) {
+		return dirScanJob{}, false, nil
+	}
+
+	var info fs.FileInfo
+	var err error
+	switch {
+	case d.Type()&fs.ModeSymlink != 0:
+		if !opts.FollowSymlinks || (followLink != nil && !followLink(name)) {
+			return dirScanJob{}, false, nil
+		}
+		info, err = fs.Stat(fsys, name) // follows the link
+	default:
+		info, err = d.Info()
+	}
+	if err != nil {
+		return dirScanJob{name: name, err: err}, true, nil
+	}
+
+	if !info.Mode().IsRegular() {
+		return dirScanJob{}, false, nil
+	}
+	if opts.MaxFileSize > 0 && info.Size() > opts.MaxFileSize {
+		return dirScanJob{}, false, nil
+	}
+
+	return dirScanJob{name: name, size: info.Size()}, true, nil
+}
+
+// scanEntry opens and scans one selected file.
+func scanEntry(ctx context.Context, s Scanner, fsys fs.FS, job dirScanJob, displayPath func(string) string) *FileResult {
+	res := &FileResult{Path: displayPath(job.name), Size: job.size}
+	if job.err != nil {
+		res.Err = NewValidationError(fmt.Sprintf("failed to read: %s", res.Path), job.err)
+		return res
+	}
+
+	f, err := fsys.Open(job.name)
+	if err != nil {
+		res.Err = NewValidationError(fmt.Sprintf("failed to open file: %s", res.Path), err)
+		return res
+	}
+	defer func() { _ = f.Close() }()
+
+	result, err := s.StreamScanReader(ctx, f, path.Base(job.name), job.size)
+	if err != nil {
+		if errors.Is(err, context.Canceled) && ctx.Err() != nil {
+			res.Err = NewTimeoutError("directory scan canceled", err)
+		} else {
+			res.Err = asError(err)
+		}
+		return res
+	}
+	res.Result = result
+	return res
+}
@@ -0,0 +1,290 @@
+package clamav
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+)
+
+// --- Directory scan tests ---
+
+func infectedStub() *stubScanner {
+	return &stubScanner{scanFunc: func(data []byte, filename string) (*ScanResult, error) {
+		if strings.Contains(string(data), "EICAR") {
+			return &ScanResult{Status: "FOUND", Message: "Eicar-Test-Signature", Filename: filename}, nil
+		}
+		return &ScanResult{Status: "OK", Filename: filename}, nil
+	}}
+}
+
+func collectPaths(results *[]*FileResult) func(*FileResult) {
+	return func(r *FileResult) { *results = append(*results, r) }
+}
+
+func sortedPaths(results []*FileResult) []string {
+	paths := make([]string, len(results))
+	for i, r := range results {
+		paths[i] = r.Path
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// unreadableFS is a file system whose directories cannot be listed.
+type unreadableFS struct {
+	fstest.MapFS
+}
+
+func (unreadableFS) ReadDir(string) ([]fs.DirEntry, error) {
+	return nil, fs.ErrPermission
+}
+
+func TestScanFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt":             {Data: []byte("clean")},
+		"docs/b.pdf":        {Data: []byte("EICAR")},
+		"docs/c.txt":        {Data: []byte("clean too")},
+		"node_modules/x.js": {Data: []byte("clean")},
+		"big.bin":           {Data: make([]byte, 100)},
+		"pipe":              {Mode: fs.ModeNamedPipe},
+	}
+
+	t.Run("summary and results", func(t *testing.T) {
+		var results []*FileResult
+		summary, err := ScanFS(context.Background(), infectedStub(), fsys, nil, collectPaths(&results))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if summary.Scanned != 5 || summary.Clean != 4 || summary.Infected != 1 || summary.Errored != 0 {
+			t.Errorf("summary = %+v", summary)
+		}
+		if summary.Skipped != 1 {
+			t.Errorf("Skipped = %d, want 1 (named pipe)", summary.Skipped)
+		}
+		if summary.Bytes != 5+5+9+5+100 {
+			t.Errorf("Bytes = %d, want %d", summary.Bytes, 5+5+9+5+100)
+		}
+		if len(results) != 5 {
+			t.Errorf("got %d results, want 5", len(results))
+		}
+	})
+
+	t.Run("include and exclude", func(t *testing.T) {
+		var results []*FileResult
+		opts := &DirScanOptions{Include: []string{"*.txt", "*.js"}, Exclude: []string{"node_modules"}}
+		summary, err := ScanFS(context.Background(), infectedStub(), fsys, opts, collectPaths(&results))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if summary.Directories != 2 {
+			t.Errorf("Directories = %d, want 2 (excluded directories are not walked)", summary.Directories)
+		}
+		got := strings.Join(sortedPaths(results), ",")
+		if got != "a.txt,docs/c.txt" {
+			t.Errorf("scanned %q, want %q", got, "a.txt,docs/c.txt")
+		}
+	})
+
+	t.Run("max file size", func(t *testing.T) {
+		summary, err := ScanFS(context.Background(), infectedStub(), fsys, &DirScanOptions{MaxFileSize: 10}, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if summary.Scanned != 4 || summary.Skipped != 2 {
+			t.Errorf("summary = %+v, want 4 scanned and 2 skipped", summary)
+		}
+	})
+
+	t.Run("scan errors", func(t *testing.T) {
+		stub := &stubScanner{scanFunc: func([]byte, string) (*ScanResult, error) {
+			return nil, NewConnectionError("connection failed", nil)
+		}}
+		var results []*FileResult
+		summary, err := ScanFS(context.Background(), stub, fsys, nil, collectPaths(&results))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if summary.Errored != 5 {
+			t.Errorf("Errored = %d, want 5", summary.Errored)
+		}
+		for _, r := range results {
+			if !IsConnectionError(r.Err) {
+				t.Errorf("%s: expected connection error, got: %v", r.Path, r.Err)
+			}
+		}
+	})
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		_, err := ScanFS(context.Background(), infectedStub(), fsys, &DirScanOptions{Include: []string{"["}}, nil)
+		if !IsValidationError(err) {
+			t.Errorf("expected validation error, got: %v", err)
+		}
+	})
+
+	t.Run("unreadable root", func(t *testing.T) {
+		summary, err := ScanFS(context.Background(), infectedStub(), unreadableFS{fsys}, nil, nil)
+		if !IsValidationError(err) || !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("expected validation error wrapping fs.ErrPermission, got: %v", err)
+		}
+		if summary == nil || summary.Scanned != 0 || summary.Directories != 1 {
+			t.Errorf("summary = %+v, want the partial summary", summary)
+		}
+	})
+
+	t.Run("bounded concurrency", func(t *testing.T) {
+		var inFlight, peak int32
+		stub := &stubScanner{scanFunc: func(_ []byte, filename string) (*ScanResult, error) {
+			n := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			return &ScanResult{Status: "OK", Filename: filename}, nil
+		}}
+		many := fstest.MapFS{}
+		for i := 0; i < 20; i++ {
+			many[string(rune('a'+i))+".txt"] = &fstest.MapFile{Data: []byte("x")}
+		}
+		summary, err := ScanFS(context.Background(), stub, many, &DirScanOptions{Concurrency: 2}, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if summary.Scanned != 20 {
+			t.Errorf("Scanned = %d, want 20", summary.Scanned)
+		}
+		if peak > 2 {
+			t.Errorf("peak concurrency = %d, want <= 2", peak)
+		}
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := ScanFS(ctx, infectedStub(), fsys, nil, nil)
+		if !IsTimeoutError(err) {
+			t.Errorf("expected timeout error, got: %v", err)
+		}
+	})
+}
+
+func TestScanDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	writeFile("clean.txt", "clean")
+	target := writeFile("sub/eicar.com", "EICAR")
+	if err := os.Symlink(target, filepath.Join(dir, "link.com")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	if err := os.Symlink(dir, filepath.Join(dir, "sub", "loop")); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("skips symlinks by default", func(t *testing.T) {
+		var results []*FileResult
+		summary, err := ScanDir(context.Background(), infectedStub(), dir, nil, collectPaths(&results))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []string{filepath.Join(dir, "clean.txt"), filepath.Join(dir, "sub", "eicar.com")}
+		if got := sortedPaths(results); strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("scanned %v, want %v", got, want)
+		}
+		if summary.Infected != 1 || summary.Skipped != 2 || summary.Directories != 2 {
+			t.Errorf("summary = %+v", summary)
+		}
+	})
+
+	t.Run("follows file symlinks only", func(t *testing.T) {
+		summary, err := ScanDir(context.Background(), infectedStub(), dir, &DirScanOptions{FollowSymlinks: true}, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if summary.Scanned != 3 || summary.Infected != 2 || summary.Skipped != 1 {
+			t.Errorf("summary = %+v, want 3 scanned, 2 infected, 1 skipped", summary)
+		}
+	})
+
+	t.Run("skips symlinks leaving the root", func(t *testing.T) {
+		outside := filepath.Join(t.TempDir(), "outside.com")
+		if err := os.WriteFile(outside, []byte("EICAR"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		root := t.TempDir()
+		if err := os.Symlink(outside, filepath.Join(root, "escape.com")); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(filepath.Join("..", filepath.Base(filepath.Dir(outside)), "outside.com"), filepath.Join(root, "relative.com")); err != nil {
+			t.Fatal(err)
+		}
+
+		summary, err := ScanDir(context.Background(), infectedStub(), root, &DirScanOptions{FollowSymlinks: true}, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if summary.Scanned != 0 || summary.Skipped != 2 {
+			t.Errorf("summary = %+v, want both links skipped", summary)
+		}
+	})
+
+	t.Run("infected hook", func(t *testing.T) {
+		var hooked int32
+		hookErr := NewValidationError("hook failed", nil)
+		opts := &DirScanOptions{OnInfected: func(_ context.Context, r *FileResult) error {
+			atomic.AddInt32(&hooked, 1)
+			if r.Path != target || !r.Result.IsInfected() {
+				t.Errorf("hook called with %+v", r)
+			}
+			return hookErr
+		}}
+		var results []*FileResult
+		summary, err := ScanDir(context.Background(), infectedStub(), dir, opts, collectPaths(&results))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if hooked != 1 || summary.Infected != 1 {
+			t.Errorf("hook calls = %d, summary = %+v", hooked, summary)
+		}
+		for _, r := range results {
+			var want error
+			if r.Path == target {
+				want = hookErr
+			}
+			if r.HookErr != want {
+				t.Errorf("%s: HookErr = %v, want %v", r.Path, r.HookErr, want)
+			}
+		}
+	})
+
+	t.Run("missing directory", func(t *testing.T) {
+		_, err := ScanDir(context.Background(), infectedStub(), filepath.Join(dir, "missing"), nil, nil)
+		if !IsValidationError(err) {
+			t.Errorf("expected validation error, got: %v", err)
+		}
+	})
+
+	t.Run("not a directory", func(t *testing.T) {
+		_, err := ScanDir(context.Background(), infectedStub(), filepath.Join(dir, "clean.txt"), nil, nil)
+		if !IsValidationError(err) {
+			t.Errorf("expected validation error, got: %v", err)
+		}
+	})
+}
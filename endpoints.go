@@ -0,0 +1,316 @@
+package clamav
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultEndpointHealthInterval = 10 * time.Second
+
+	// ewmaDecay weights the newest latency sample in the moving average.
+	ewmaDecay = 0.3
+)
+
+// BalancingStrategy selects how a multi-endpoint client spreads requests.
+type BalancingStrategy int
+
+const (
+	// RoundRobin sends requests to healthy endpoints in turn.
+	RoundRobin BalancingStrategy = iota
+	// LeastInFlight sends each request to the healthy endpoint with the fewest requests in flight.
+	LeastInFlight
+	// EWMALatency prefers the healthy endpoint with the lowest exponentially weighted
+	// moving average latency, scaled by its number of requests in flight.
+	EWMALatency
+)
+
+// EndpointStatus is a snapshot of one endpoint of a multi-endpoint client.
+type EndpointStatus struct {
+	// BaseURL is the endpoint base URL.
+	BaseURL string
+	// Healthy is false while the endpoint is ejected from the rotation.
+	Healthy bool
+	// InFlight is the number of requests currently sent to the endpoint.
+	InFlight int64
+	// Latency is the moving average request latency (zero before the first response).
+	Latency time.Duration
+}
+
+// NewClientWithEndpoints creates a REST client that balances requests across several
+// replicas of the ClamAV API, e.g. "http://clamav-1:6000", "http://clamav-2:6000".
+//
+// Endpoints that return connection errors are ejected and the request fails over to the
+// next healthy endpoint when its body can be replayed. Every endpoint is probed with a
+// health check in the background (see WithEndpointHealthInterval): unhealthy endpoints
+// are ejected and ejected endpoints are re-admitted once healthy again.
+// Call Close to stop the background probes.
+func NewClientWithEndpoints(baseURLs []string, opts ...ClientOption) (*Client, error) {
+	if len(baseURLs) == 0 {
+		return nil, NewValidationError("at least one base URL is required", nil)
+	}
+
+	endpoints := make([]*endpoint, 0, len(baseURLs))
+	for _, raw := range baseURLs {
+		baseURL, u, err := parseBaseURL(raw)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, &endpoint{baseURL: baseURL, url: u, healthy: true})
+	}
+
+	c, err := NewClient(baseURLs[0], opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(endpoints) == 1 {
+		return c, nil
+	}
+
+	c.pool = newEndpointPool(endpoints, c.balancer)
+	go c.pool.monitor(c.healthInterval, c.probeEndpoint)
+
+	return c, nil
+}
+
+// Endpoints returns the status of every endpoint. A single-endpoint client
+// reports its base URL as always healthy.
+func (c *Client) Endpoints() []EndpointStatus {
+	if c.pool == nil {
+		return []EndpointStatus{{BaseURL: c.baseURL, Healthy: true}}
+	}
+
+	statuses := make([]EndpointStatus, len(c.pool.endpoints))
+	for i, e := range c.pool.endpoints {
+		statuses[i] = e.status()
+	}
+	return statuses
+}
+
+// probeEndpoint reports whether the health check of e succeeds.
+func (c *Client) probeEndpoint(ctx context.Context, e *endpoint) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.baseURL+pathHealthCheck, http.NoBody)
+	if err != nil {
+		return false
+	}
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	health, err := decodeHealth(resp)
+	return err == nil && health.Healthy
+}
+
+// parseBaseURL normalizes and validates a base URL.
+func parseBaseURL(raw string) (string, *url.URL, error) {
+	baseURL := strings.TrimRight(raw, "/")
+
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", nil, NewValidationError(fmt.Sprintf("invalid base URL: %s", baseURL), err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", nil, NewValidationError(fmt.Sprintf("base URL must include scheme and host: %s", baseURL), nil)
+	}
+
+	return baseURL, u, nil
+}
+
+// endpoint is one replica of the ClamAV API.
+type endpoint struct {
+	baseURL  string
+	url      *url.URL
+	inFlight atomic.Int64
+
+	mu      sync.Mutex
+	healthy bool
+	latency float64 // EWMA in nanoseconds; 0 until the first sample
+}
+
+// status returns a snapshot of the endpoint.
+func (e *endpoint) status() EndpointStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return EndpointStatus{
+		BaseURL:  e.baseURL,
+		Healthy:  e.healthy,
+		InFlight: e.inFlight.Load(),
+		Latency:  time.Duration(e.latency),
+	}
+}
+
+// isHealthy reports whether the endpoint is in the rotation.
+func (e *endpoint) isHealthy() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.healthy
+}
+
+// setHealthy ejects or re-admits the endpoint.
+func (e *endpoint) setHealthy(healthy bool) {
+	e.mu.Lock()
+	e.healthy = healthy
+	e.mu.Unlock()
+}
+
+// observe folds a latency sample into the moving average.
+func (e *endpoint) observe(d time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.latency == 0 {
+		e.latency = float64(d)
+		return
+	}
+	e.latency = ewmaDecay*float64(d) + (1-ewmaDecay)*e.latency
+}
+
+// score is the EWMA cost of sending one more request to the endpoint.
+func (e *endpoint) score() float64 {
+	e.mu.Lock()
+	latency := e.latency
+	e.mu.Unlock()
+	return latency * float64(e.inFlight.Load()+1)
+}
+
+// endpointPool balances requests across endpoints.
+type endpointPool struct {
+	endpoints []*endpoint
+	strategy  BalancingStrategy
+	next      atomic.Uint64
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func newEndpointPool(endpoints []*endpoint, strategy BalancingStrategy) *endpointPool {
+	return &endpointPool{
+		endpoints: endpoints,
+		strategy:  strategy,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// pick selects the endpoint for the next request. If every endpoint is ejected,
+// all of them are considered so requests still have a chance to succeed.
+func (p *endpointPool) pick() *endpoint {
+	candidates := make([]*endpoint, 0, len(p.endpoints))
+	for _, e := range p.endpoints {
+		if e.isHealthy() {
+			candidates = append(candidates, e)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = p.endpoints
+	}
+
+	// Rotating the starting point spreads ties across endpoints.
+	start := int(p.next.Add(1)-1) % len(candidates)
+	best := candidates[start]
+
+	switch p.strategy {
+	case LeastInFlight:
+		for i := 1; i < len(candidates); i++ {
+			e := candidates[(start+i)%len(candidates)]
+			if e.inFlight.Load() < best.inFlight.Load() {
+				best = e
+			}
+		}
+	case EWMALatency:
+		bestScore := best.score()
+		for i := 1; i < len(candidates); i++ {
+			e := candidates[(start+i)%len(candidates)]
+			if s := e.score(); s < bestScore {
+				best, bestScore = e, s
+			}
+		}
+	}
+
+	return best
+}
+
+// healthyCount returns the number of endpoints in the rotation.
+func (p *endpointPool) healthyCount() int {
+	n := 0
+	for _, e := range p.endpoints {
+		if e.isHealthy() {
+			n++
+		}
+	}
+	return n
+}
+
+// send issues req to e, rewriting its URL from the primary endpoint to e, and
+// records latency, in-flight count and ejection on connection errors.
+func (p *endpointPool) send(e *endpoint, req *http.Request, do func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	u := *e.url
+	u.Path = e.url.Path + strings.TrimPrefix(req.URL.Path, p.endpoints[0].url.Path)
+	u.RawQuery = req.URL.RawQuery
+	target := req.Clone(req.Context())
+	target.URL = &u
+	target.Host = ""
+
+	e.inFlight.Add(1)
+	start := time.Now()
+	resp, err := do(target)
+	e.inFlight.Add(-1)
+
+	if err != nil {
+		if IsConnectionError(err) {
+			e.setHealthy(false)
+		}
+		return nil, err
+	}
+	e.observe(time.Since(start))
+	return resp, nil
+}
+
+// monitor probes every endpoint at the given interval until close is called.
+func (p *endpointPool) monitor(interval time.Duration, probe func(context.Context, *endpoint) bool) {
+	defer close(p.done)
+	if interval <= 0 {
+		interval = defaultEndpointHealthInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		var wg sync.WaitGroup
+		for _, e := range p.endpoints {
+			wg.Add(1)
+			go func(e *endpoint) {
+				defer wg.Done()
+				e.setHealthy(probe(ctx, e))
+			}(e)
+		}
+		wg.Wait()
+		cancel()
+	}
+}
+
+// close stops the background health probes and waits for them to exit.
+func (p *endpointPool) close() {
+	p.stopOnce.Do(func() { close(p.stop) })
+	<-p.done
+}
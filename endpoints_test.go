@@ -0,0 +1,293 @@
+package clamav
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DevHatRo/clamav-api-sdk-go/internal/testutil"
+)
+
+// countingServer starts a mock server that counts scan requests and answers health
+// checks with the status returned by health.
+func countingServer(t *testing.T, calls *int32, health func() int) *httptest.Server {
+	t.Helper()
+	srv := testutil.NewMockServer(map[string]http.HandlerFunc{
+		"/api/health-check": func(w http.ResponseWriter, r *http.Request) {
+			testutil.JSONHandler(health(), map[string]string{"message": "ok"})(w, r)
+		},
+		"/api/scan": testutil.ScanHandler(func(data []byte, filename string) (int, interface{}) {
+			atomic.AddInt32(calls, 1)
+			return http.StatusOK, testutil.CleanScanResponse()
+		}),
+	})
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// closedURL returns the URL of a server that is no longer listening.
+func closedURL() string {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	return srv.URL
+}
+
+func healthyStatus() int { return http.StatusOK }
+
+func TestNewClientWithEndpoints(t *testing.T) {
+	t.Run("no URLs", func(t *testing.T) {
+		_, err := NewClientWithEndpoints(nil)
+		if !IsValidationError(err) {
+			t.Errorf("expected validation error, got: %v", err)
+		}
+	})
+
+	t.Run("invalid URL", func(t *testing.T) {
+		_, err := NewClientWithEndpoints([]string{"http://localhost:6000", "localhost:6001"})
+		if !IsValidationError(err) {
+			t.Errorf("expected validation error, got: %v", err)
+		}
+	})
+
+	t.Run("single URL has no pool", func(t *testing.T) {
+		client, err := NewClientWithEndpoints([]string{"http://localhost:6000/"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer func() { _ = client.Close() }()
+		if client.pool != nil {
+			t.Error("pool should be nil for a single endpoint")
+		}
+		if eps := client.Endpoints(); len(eps) != 1 || eps[0].BaseURL != "http://localhost:6000" {
+			t.Errorf("Endpoints() = %+v", eps)
+		}
+	})
+}
+
+func TestEndpointRoundRobin(t *testing.T) {
+	var calls [3]int32
+	urls := make([]string, 3)
+	for i := range urls {
+		urls[i] = countingServer(t, &calls[i], healthyStatus).URL
+	}
+
+	client, err := NewClientWithEndpoints(urls)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	for i := 0; i < 9; i++ {
+		if _, err := client.ScanFile(context.Background(), []byte("data"), "f.txt"); err != nil {
+			t.Fatalf("scan %d: %v", i, err)
+		}
+	}
+	for i := range calls {
+		if calls[i] != 3 {
+			t.Errorf("endpoint %d calls = %d, want 3", i, calls[i])
+		}
+	}
+}
+
+func TestEndpointPathPrefix(t *testing.T) {
+	var gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		testutil.JSONHandler(http.StatusOK, map[string]string{"version": "1.0.0"})(w, r)
+	}))
+	defer srv.Close()
+
+	client, err := NewClientWithEndpoints([]string{closedURL() + "/primary", srv.URL + "/clamav"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	if _, err := client.Version(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotPath != "/clamav/api/version" {
+		t.Errorf("path = %q, want %q", gotPath, "/clamav/api/version")
+	}
+}
+
+func TestEndpointFailover(t *testing.T) {
+	var calls int32
+	live := countingServer(t, &calls, healthyStatus)
+	dead := closedURL()
+
+	client, err := NewClientWithEndpoints([]string{dead, live.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	for i := 0; i < 4; i++ {
+		if _, err := client.ScanFile(context.Background(), []byte("data"), "f.txt"); err != nil {
+			t.Fatalf("scan %d: %v", i, err)
+		}
+	}
+	if calls != 4 {
+		t.Errorf("live calls = %d, want 4", calls)
+	}
+
+	eps := client.Endpoints()
+	if eps[0].Healthy {
+		t.Error("dead endpoint should be ejected")
+	}
+	if !eps[1].Healthy {
+		t.Error("live endpoint should be healthy")
+	}
+}
+
+func TestEndpointRecovery(t *testing.T) {
+	var healthy atomic.Bool
+	var calls [2]int32
+	flaky := countingServer(t, &calls[0], func() int {
+		if healthy.Load() {
+			return http.StatusOK
+		}
+		return http.StatusBadGateway
+	})
+	stable := countingServer(t, &calls[1], healthyStatus)
+
+	client, err := NewClientWithEndpoints([]string{flaky.URL, stable.URL},
+		WithEndpointHealthInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	waitFor(t, func() bool { return !client.Endpoints()[0].Healthy })
+
+	healthy.Store(true)
+	waitFor(t, func() bool { return client.Endpoints()[0].Healthy })
+}
+
+func TestEndpointProbe(t *testing.T) {
+	proxyPage := testutil.NewMockServer(map[string]http.HandlerFunc{
+		"/api/health-check": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte("<html>Service unavailable</html>"))
+		},
+	})
+	defer proxyPage.Close()
+	unhealthy := testutil.NewMockServer(map[string]http.HandlerFunc{
+		"/api/health-check": testutil.JSONHandler(http.StatusOK, map[string]string{"message": "clamd unreachable"}),
+	})
+	defer unhealthy.Close()
+	live := countingServer(t, new(int32), healthyStatus)
+
+	client, err := NewClientWithEndpoints([]string{proxyPage.URL, unhealthy.URL, live.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	for i, want := range []bool{false, false, true} {
+		if got := client.probeEndpoint(context.Background(), client.pool.endpoints[i]); got != want {
+			t.Errorf("probe %s = %v, want %v", client.pool.endpoints[i].baseURL, got, want)
+		}
+	}
+}
+
+func TestEndpointPick(t *testing.T) {
+	newPool := func(strategy BalancingStrategy) *endpointPool {
+		return newEndpointPool([]*endpoint{
+			{baseURL: "a", healthy: true},
+			{baseURL: "b", healthy: true},
+			{baseURL: "c", healthy: true},
+		}, strategy)
+	}
+
+	t.Run("least in flight", func(t *testing.T) {
+		p := newPool(LeastInFlight)
+		p.endpoints[0].inFlight.Store(3)
+		p.endpoints[1].inFlight.Store(1)
+		p.endpoints[2].inFlight.Store(2)
+		for i := 0; i < 3; i++ {
+			if e := p.pick(); e.baseURL != "b" {
+				t.Errorf("pick() = %q, want %q", e.baseURL, "b")
+			}
+		}
+	})
+
+	t.Run("ewma latency", func(t *testing.T) {
+		p := newPool(EWMALatency)
+		p.endpoints[0].observe(30 * time.Millisecond)
+		p.endpoints[1].observe(20 * time.Millisecond)
+		p.endpoints[2].observe(5 * time.Millisecond)
+		if e := p.pick(); e.baseURL != "c" {
+			t.Errorf("pick() = %q, want %q", e.baseURL, "c")
+		}
+
+		// Load on the fastest endpoint shifts traffic to the next best one.
+		p.endpoints[2].inFlight.Store(10)
+		if e := p.pick(); e.baseURL != "b" {
+			t.Errorf("pick() = %q, want %q", e.baseURL, "b")
+		}
+	})
+
+	t.Run("skips ejected endpoints", func(t *testing.T) {
+		p := newPool(RoundRobin)
+		p.endpoints[0].setHealthy(false)
+		p.endpoints[2].setHealthy(false)
+		for i := 0; i < 3; i++ {
+			if e := p.pick(); e.baseURL != "b" {
+				t.Errorf("pick() = %q, want %q", e.baseURL, "b")
+			}
+		}
+	})
+
+	t.Run("all ejected falls back to all", func(t *testing.T) {
+		p := newPool(RoundRobin)
+		seen := map[string]bool{}
+		for _, e := range p.endpoints {
+			e.setHealthy(false)
+		}
+		for i := 0; i < 3; i++ {
+			seen[p.pick().baseURL] = true
+		}
+		if len(seen) != 3 {
+			t.Errorf("picked %v, want all endpoints", seen)
+		}
+	})
+}
+
+func TestEndpointEWMAObserve(t *testing.T) {
+	e := &endpoint{}
+	e.observe(100 * time.Millisecond)
+	e.observe(200 * time.Millisecond)
+	got := e.status().Latency
+	if got != 130*time.Millisecond {
+		t.Errorf("Latency = %v, want %v", got, 130*time.Millisecond)
+	}
+}
+
+// waitFor polls cond until it is true or fails the test after a second.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within 1s")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestBalancingOptions(t *testing.T) {
+	c := &Client{}
+	WithBalancer(EWMALatency)(c)
+	WithEndpointHealthInterval(time.Second)(c)
+	WithEndpointHealthInterval(-1)(c)
+	if c.balancer != EWMALatency {
+		t.Errorf("balancer = %v, want EWMALatency", c.balancer)
+	}
+	if c.healthInterval != time.Second {
+		t.Errorf("healthInterval = %v, want 1s", c.healthInterval)
+	}
+}
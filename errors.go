@@ -11,6 +11,12 @@ const (
 	CodeTimeout    = "timeout"
 	CodeValidation = "validation_error"
 	CodeService    = "service_error"
+	// CodeCircuitOpen is returned by CircuitBreaker while it rejects calls.
+	CodeCircuitOpen = "circuit_open"
+	// CodeInfected is returned by ScanningReader when the content is infected.
+	CodeInfected = "infected"
+	// CodeScan is returned by ScanResult.Err when the server reported a scan error.
+	CodeScan = "scan_error"
 )
 
 // Error is the base error type for all SDK errors.
@@ -75,6 +81,30 @@ func NewServiceError(msg string, statusCode int, cause error) *Error {
 	}
 }
 
+// NewCircuitOpenError creates an error indicating a call was rejected by an open circuit breaker.
+func NewCircuitOpenError(msg string) *Error {
+	return &Error{
+		Code:    CodeCircuitOpen,
+		Message: msg,
+	}
+}
+
+// NewInfectedError creates an error indicating the scanned content is infected.
+func NewInfectedError(msg string) *Error {
+	return &Error{
+		Code:    CodeInfected,
+		Message: msg,
+	}
+}
+
+// NewScanError creates an error indicating the server could not scan a file.
+func NewScanError(msg string) *Error {
+	return &Error{
+		Code:    CodeScan,
+		Message: msg,
+	}
+}
+
 // IsConnectionError reports whether err is or wraps a connection error.
 func IsConnectionError(err error) bool {
 	var e *Error
@@ -110,3 +140,30 @@ func IsServiceError(err error) bool {
 	}
 	return false
 }
+
+// IsCircuitOpenError reports whether err is or wraps a circuit-open error.
+func IsCircuitOpenError(err error) bool {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code == CodeCircuitOpen
+	}
+	return false
+}
+
+// IsInfectedError reports whether err is or wraps an infected error.
+func IsInfectedError(err error) bool {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code == CodeInfected
+	}
+	return false
+}
+
+// IsScanError reports whether err is or wraps a scan error.
+func IsScanError(err error) bool {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code == CodeScan
+	}
+	return false
+}
@@ -153,3 +153,42 @@ func TestIsServiceError(t *testing.T) {
 		t.Error("IsServiceError should return false for validation errors")
 	}
 }
+
+func TestIsCircuitOpenError(t *testing.T) {
+	err := NewCircuitOpenError("circuit breaker is open")
+	if err.Code != CodeCircuitOpen {
+		t.Errorf("Code = %q, want %q", err.Code, CodeCircuitOpen)
+	}
+	if !IsCircuitOpenError(fmt.Errorf("wrapped: %w", err)) {
+		t.Error("IsCircuitOpenError should work through wrapping")
+	}
+	if IsCircuitOpenError(NewServiceError("svc", 503, nil)) {
+		t.Error("IsCircuitOpenError should return false for service errors")
+	}
+}
+
+func TestIsInfectedError(t *testing.T) {
+	err := NewInfectedError("file is infected: Eicar-Test-Signature")
+	if err.Code != CodeInfected {
+		t.Errorf("Code = %q, want %q", err.Code, CodeInfected)
+	}
+	if !IsInfectedError(fmt.Errorf("wrapped: %w", err)) {
+		t.Error("IsInfectedError should work through wrapping")
+	}
+	if IsInfectedError(NewValidationError("bad", nil)) {
+		t.Error("IsInfectedError should return false for validation errors")
+	}
+}
+
+func TestIsScanError(t *testing.T) {
+	err := NewScanError("Can't open file")
+	if err.Code != CodeScan {
+		t.Errorf("Code = %q, want %q", err.Code, CodeScan)
+	}
+	if !IsScanError(fmt.Errorf("wrapped: %w", err)) {
+		t.Error("IsScanError should work through wrapping")
+	}
+	if IsScanError(NewServiceError("svc", 502, nil)) {
+		t.Error("IsScanError should return false for service errors")
+	}
+}
@@ -2,6 +2,7 @@ package grpc
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -9,6 +10,7 @@ import (
 
 	clamav "github.com/DevHatRo/clamav-api-sdk-go"
 	pb "github.com/DevHatRo/clamav-api-sdk-go/grpc/proto"
+	"github.com/DevHatRo/clamav-api-sdk-go/internal/readerutil"
 	grpclib "google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
@@ -17,6 +19,7 @@ import (
 
 // Client is the gRPC client for the ClamAV API.
 // It is safe for concurrent use from multiple goroutines.
+// Client implements clamav.Scanner.
 type Client struct {
 	conn              *grpclib.ClientConn
 	scanner           pb.ClamAVScannerClient
@@ -25,8 +28,11 @@ type Client struct {
 	maxMessageSize    int
 	dialOpts          []grpclib.DialOption
 	hasTransportCreds bool
+	retry             *clamav.RetryPolicy
 }
 
+var _ clamav.Scanner = (*Client)(nil)
+
 // NewClient creates a gRPC client for the ClamAV API.
 // target is the gRPC server address, e.g. "localhost:9000".
 // By default, the connection uses insecure credentials. Use WithDialOptions
@@ -78,9 +84,14 @@ func (c *Client) HealthCheck(ctx context.Context) (*clamav.HealthCheckResult, er
 	ctx, cancel := c.contextWithTimeout(ctx)
 	defer cancel()
 
-	resp, err := c.scanner.HealthCheck(ctx, &pb.HealthCheckRequest{})
+	var resp *pb.HealthCheckResponse
+	err := c.retry.Do(ctx, func(ctx context.Context) error {
+		var err error
+		resp, err = c.scanner.HealthCheck(ctx, &pb.HealthCheckRequest{})
+		return mapGRPCError(err)
+	})
 	if err != nil {
-		return nil, mapGRPCError(err)
+		return nil, err
 	}
 
 	return &clamav.HealthCheckResult{
@@ -89,6 +100,13 @@ func (c *Client) HealthCheck(ctx context.Context) (*clamav.HealthCheckResult, er
 	}, nil
 }
 
+// Version is not available over gRPC: the ClamAV API proto has no version RPC.
+// It always returns a service error with StatusCode 501 and exists so that
+// Client satisfies clamav.Scanner; use the REST client to query the server version.
+func (c *Client) Version(_ context.Context) (*clamav.VersionResult, error) {
+	return nil, clamav.NewServiceError("version is not supported by the gRPC API", grpcCodeToHTTP(codes.Unimplemented), nil)
+}
+
 // ScanFile scans file data with a unary RPC call.
 func (c *Client) ScanFile(ctx context.Context, data []byte, filename string) (*clamav.ScanResult, error) {
 	if len(data) == 0 {
@@ -97,15 +115,20 @@ func (c *Client) ScanFile(ctx context.Context, data []byte, filename string) (*c
 	ctx, cancel := c.contextWithTimeout(ctx)
 	defer cancel()
 
-	resp, err := c.scanner.ScanFile(ctx, &pb.ScanFileRequest{
-		Data:     data,
-		Filename: filename,
+	var resp *pb.ScanResponse
+	err := c.retry.Do(ctx, func(ctx context.Context) error {
+		var err error
+		resp, err = c.scanner.ScanFile(ctx, &pb.ScanFileRequest{
+			Data:     data,
+			Filename: filename,
+		})
+		return mapGRPCError(err)
 	})
 	if err != nil {
-		return nil, mapGRPCError(err)
+		return nil, err
 	}
 
-	return mapScanResponse(resp), nil
+	return mapScanResponse(resp)
 }
 
 // ScanFilePath reads a file from disk and scans with a unary RPC.
@@ -123,6 +146,17 @@ func (c *Client) ScanStream(ctx context.Context, data []byte, filename string) (
 	ctx, cancel := c.contextWithTimeout(ctx)
 	defer cancel()
 
+	var result *clamav.ScanResult
+	err := c.retry.Do(ctx, func(ctx context.Context) error {
+		var err error
+		result, err = c.scanStream(ctx, data, filename)
+		return err
+	})
+	return result, err
+}
+
+// scanStream performs a single ScanStream attempt with in-memory data.
+func (c *Client) scanStream(ctx context.Context, data []byte, filename string) (*clamav.ScanResult, error) {
 	stream, err := c.scanner.ScanStream(ctx)
 	if err != nil {
 		return nil, mapGRPCError(err)
@@ -137,15 +171,43 @@ func (c *Client) ScanStream(ctx context.Context, data []byte, filename string) (
 		return nil, mapGRPCError(err)
 	}
 
-	return mapScanResponse(resp), nil
+	return mapScanResponse(resp)
 }
 
 // ScanStreamReader scans an io.Reader via client streaming RPC.
 // Streams chunks without buffering the entire content in memory.
+// With a retry policy, the reader is rewound between attempts if it implements
+// io.Seeker; other readers are sent exactly once.
 func (c *Client) ScanStreamReader(ctx context.Context, r io.Reader, filename string) (*clamav.ScanResult, error) {
 	ctx, cancel := c.contextWithTimeout(ctx)
 	defer cancel()
 
+	policy := c.retry
+	rewind, ok := readerutil.Rewinder(r)
+	if !ok && policy != nil {
+		single := *policy
+		single.MaxAttempts = 1
+		policy = &single
+	}
+
+	var result *clamav.ScanResult
+	attempt := 0
+	err := policy.Do(ctx, func(ctx context.Context) error {
+		attempt++
+		if attempt > 1 {
+			if err := rewind(); err != nil {
+				return clamav.NewValidationError("failed to rewind reader", err)
+			}
+		}
+		var err error
+		result, err = c.scanStreamReader(ctx, r, filename)
+		return err
+	})
+	return result, err
+}
+
+// scanStreamReader performs a single ScanStream attempt reading from r.
+func (c *Client) scanStreamReader(ctx context.Context, r io.Reader, filename string) (*clamav.ScanResult, error) {
 	stream, err := c.scanner.ScanStream(ctx)
 	if err != nil {
 		return nil, mapGRPCError(err)
@@ -194,7 +256,19 @@ func (c *Client) ScanStreamReader(ctx context.Context, r io.Reader, filename str
 		return nil, mapGRPCError(err)
 	}
 
-	return mapScanResponse(resp), nil
+	return mapScanResponse(resp)
+}
+
+// ScanReader scans an io.Reader via client streaming RPC.
+// It is equivalent to ScanStreamReader and is provided to satisfy clamav.Scanner.
+func (c *Client) ScanReader(ctx context.Context, r io.Reader, filename string) (*clamav.ScanResult, error) {
+	return c.ScanStreamReader(ctx, r, filename)
+}
+
+// StreamScanReader scans an io.Reader via client streaming RPC.
+// size is ignored because the data is always chunked; it may be clamav.UnknownSize.
+func (c *Client) StreamScanReader(ctx context.Context, r io.Reader, filename string, _ int64) (*clamav.ScanResult, error) {
+	return c.ScanStreamReader(ctx, r, filename)
 }
 
 // ScanStreamFile reads a file from disk and scans via client streaming RPC.
@@ -209,9 +283,12 @@ func (c *Client) ScanStreamFile(ctx context.Context, filePath string) (*clamav.S
 }
 
 // ScanMultiple scans multiple files using bidirectional streaming.
-// Results are sent to the returned channel as they arrive.
+// Results are sent to the returned channel as they arrive. The retry policy does not
+// apply because results of a partially completed stream cannot be replayed.
 // The channel is closed when all results have been received.
-// Errors for individual files appear in ScanResult with Status "ERROR".
+// Errors for individual files appear in ScanResult with Status StatusError: files the
+// server failed to scan have a nil TransportErr, while files that could not be sent or
+// whose result was not received carry the transport error in TransportErr.
 // If the consumer stops reading from the channel, goroutines exit on ctx.Done() so resources are not leaked.
 func (c *Client) ScanMultiple(ctx context.Context, files []clamav.FileInput) (<-chan *clamav.ScanResult, error) {
 	ctx, cancel := c.contextWithTimeout(ctx)
@@ -252,9 +329,10 @@ func (c *Client) ScanMultiple(ctx context.Context, files []clamav.FileInput) (<-
 			}
 			if err := c.sendChunks(stream, file.Data, file.Filename); err != nil {
 				if !sendResult(&clamav.ScanResult{
-					Status:   "ERROR",
-					Message:  err.Error(),
-					Filename: file.Filename,
+					Status:       clamav.StatusError,
+					Message:      err.Error(),
+					Filename:     file.Filename,
+					TransportErr: err,
 				}) {
 					return
 				}
@@ -277,13 +355,25 @@ func (c *Client) ScanMultiple(ctx context.Context, files []clamav.FileInput) (<-
 				if ok && st.Code() == codes.Canceled {
 					return
 				}
-				sendResult(&clamav.ScanResult{
-					Status:  "ERROR",
-					Message: err.Error(),
-				})
+				// Not sendResult: the error is often ctx expiring, which would race the
+				// send. The buffer always has room for this final result.
+				results <- &clamav.ScanResult{
+					Status:       clamav.StatusError,
+					Message:      err.Error(),
+					TransportErr: mapGRPCError(err),
+				}
 				return
 			}
-			if !sendResult(mapScanResponse(resp)) {
+			result, err := mapScanResponse(resp)
+			if err != nil {
+				result = &clamav.ScanResult{
+					Status:       clamav.StatusError,
+					Message:      err.Error(),
+					Filename:     resp.Filename,
+					TransportErr: err,
+				}
+			}
+			if !sendResult(result) {
 				return
 			}
 		}
@@ -314,10 +404,10 @@ type chunkSender interface {
 
 func (c *Client) sendChunks(stream chunkSender, data []byte, filename string) error {
 	if len(data) == 0 {
-		return stream.Send(&pb.ScanStreamRequest{
+		return mapGRPCError(stream.Send(&pb.ScanStreamRequest{
 			Filename: filename,
 			IsLast:   true,
-		})
+		}))
 	}
 
 	for i := 0; i < len(data); i += c.chunkSize {
@@ -351,13 +441,18 @@ func (c *Client) contextWithTimeout(ctx context.Context) (context.Context, conte
 }
 
 // mapScanResponse converts a proto ScanResponse to a clamav.ScanResult.
-func mapScanResponse(resp *pb.ScanResponse) *clamav.ScanResult {
+// It returns a service error if the response has an unknown status.
+func mapScanResponse(resp *pb.ScanResponse) (*clamav.ScanResult, error) {
+	st := clamav.ScanStatus(resp.Status)
+	if !st.Valid() {
+		return nil, clamav.NewServiceError(fmt.Sprintf("unexpected scan status %q", resp.Status), 0, nil)
+	}
 	return &clamav.ScanResult{
-		Status:   resp.Status,
+		Status:   st,
 		Message:  resp.Message,
 		ScanTime: resp.ScanTime,
 		Filename: resp.Filename,
-	}
+	}, nil
 }
 
 // mapGRPCError converts a gRPC error to an SDK error type.
@@ -3,6 +3,7 @@ package grpc
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
 	"net"
 	"os"
@@ -141,6 +142,13 @@ func (s *mockClamAVServer) ScanMultiple(stream pb.ClamAVScanner_ScanMultipleServ
 	}
 }
 
+// failingSender is a chunkSender whose stream is broken.
+type failingSender struct{}
+
+func (failingSender) Send(*pb.ScanStreamRequest) error {
+	return io.ErrClosedPipe
+}
+
 // --- Test environment helpers ---
 
 type testEnv struct {
@@ -253,6 +261,20 @@ func TestScanFile(t *testing.T) {
 		}
 	})
 
+	t.Run("unknown status", func(t *testing.T) {
+		env := newTestEnv(t, &mockClamAVServer{
+			scanFunc: func(data []byte, filename string) (*pb.ScanResponse, error) {
+				return &pb.ScanResponse{Status: "MAYBE", Filename: filename}, nil
+			},
+		})
+		defer env.close()
+
+		_, err := env.client.ScanFile(context.Background(), []byte("data"), "a.txt")
+		if !clamav.IsServiceError(err) || !strings.Contains(err.Error(), `"MAYBE"`) {
+			t.Errorf("expected service error for unknown status, got: %v", err)
+		}
+	})
+
 	t.Run("infected file", func(t *testing.T) {
 		env := newTestEnv(t, &mockClamAVServer{
 			scanFunc: func(data []byte, filename string) (*pb.ScanResponse, error) {
@@ -579,6 +601,101 @@ func TestScanMultiple(t *testing.T) {
 		}
 	})
 
+	t.Run("server and transport errors", func(t *testing.T) {
+		env := newTestEnv(t, &mockClamAVServer{
+			scanFunc: func(data []byte, filename string) (*pb.ScanResponse, error) {
+				switch string(data) {
+				case "unreadable":
+					return nil, errors.New("Can't open file")
+				case "odd":
+					return &pb.ScanResponse{Status: "MAYBE", Filename: filename}, nil
+				}
+				return &pb.ScanResponse{Status: "OK", Filename: filename}, nil
+			},
+		})
+		defer env.close()
+
+		files := []clamav.FileInput{
+			{Data: []byte("unreadable"), Filename: "a.txt"},
+			{Data: []byte("odd"), Filename: "b.txt"},
+		}
+		results, err := env.client.ScanMultiple(context.Background(), files)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		got := map[string]*clamav.ScanResult{}
+		for result := range results {
+			got[result.Filename] = result
+		}
+		if r := got["a.txt"]; r == nil || !r.IsError() || r.TransportErr != nil || !clamav.IsScanError(r.Err()) {
+			t.Errorf("a.txt = %+v, want a server-side scan error", r)
+		}
+		if r := got["b.txt"]; r == nil || !r.IsError() || !clamav.IsServiceError(r.TransportErr) || r.Err() != r.TransportErr {
+			t.Errorf("b.txt = %+v, want a client-side error for the unknown status", r)
+		}
+	})
+
+	t.Run("stream failure", func(t *testing.T) {
+		env := newTestEnv(t, &mockClamAVServer{
+			scanFunc: func(data []byte, filename string) (*pb.ScanResponse, error) {
+				time.Sleep(200 * time.Millisecond)
+				return &pb.ScanResponse{Status: "OK", Filename: filename}, nil
+			},
+		})
+		defer env.close()
+		env.client.timeout = 50 * time.Millisecond
+
+		results, err := env.client.ScanMultiple(context.Background(), []clamav.FileInput{
+			{Data: []byte("data"), Filename: "a.txt"},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		r := <-results
+		if r == nil || !r.IsError() || !clamav.IsTimeoutError(r.TransportErr) || !clamav.IsTimeoutError(r.Err()) {
+			t.Errorf("result = %+v, want a transport timeout", r)
+		}
+	})
+
+	t.Run("final stream error is always delivered", func(t *testing.T) {
+		env := newTestEnv(t, &mockClamAVServer{
+			scanFunc: func(data []byte, filename string) (*pb.ScanResponse, error) {
+				time.Sleep(50 * time.Millisecond)
+				return &pb.ScanResponse{Status: "OK", Filename: filename}, nil
+			},
+		})
+		defer env.close()
+		env.client.timeout = 5 * time.Millisecond
+
+		// The stream error coincides with the deadline, so repeat to catch a race.
+		for i := 0; i < 20; i++ {
+			results, err := env.client.ScanMultiple(context.Background(), []clamav.FileInput{
+				{Data: []byte("data"), Filename: "a.txt"},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got []*clamav.ScanResult
+			for r := range results {
+				got = append(got, r)
+			}
+			if len(got) == 0 || !got[len(got)-1].IsError() || got[len(got)-1].TransportErr == nil {
+				t.Fatalf("run %d: results = %+v, want a final transport error", i, got)
+			}
+		}
+	})
+
+	t.Run("send errors are typed", func(t *testing.T) {
+		c := &Client{chunkSize: 2}
+		for _, data := range [][]byte{nil, []byte("data")} {
+			err := c.sendChunks(failingSender{}, data, "a.txt")
+			if !clamav.IsConnectionError(err) {
+				t.Errorf("sendChunks(%q) = %v, want a connection error", data, err)
+			}
+		}
+	})
+
 	t.Run("empty file list", func(t *testing.T) {
 		env := newTestEnv(t, &mockClamAVServer{})
 		defer env.close()
@@ -757,6 +874,122 @@ func TestClientOptions(t *testing.T) {
 	})
 }
 
+// --- Retry tests ---
+
+func TestRetryPolicy(t *testing.T) {
+	policy := clamav.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	// unavailableFirst fails the first n scans with codes.Unavailable and records every payload.
+	unavailableFirst := func(n int, payloads *[]string) func([]byte, string) (*pb.ScanResponse, error) {
+		calls := 0
+		return func(data []byte, filename string) (*pb.ScanResponse, error) {
+			calls++
+			*payloads = append(*payloads, string(data))
+			if calls <= n {
+				return nil, status.Error(codes.Unavailable, "clamd restarting")
+			}
+			return &pb.ScanResponse{Status: "OK", Filename: filename}, nil
+		}
+	}
+
+	t.Run("ScanFile retries unavailable", func(t *testing.T) {
+		var payloads []string
+		env := newTestEnv(t, &mockClamAVServer{scanFunc: unavailableFirst(2, &payloads)})
+		defer env.close()
+		env.client.retry = &policy
+
+		result, err := env.client.ScanFile(context.Background(), []byte("data"), "retry.txt")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !result.IsClean() {
+			t.Errorf("expected clean, got status %q", result.Status)
+		}
+		if len(payloads) != 3 {
+			t.Errorf("attempts = %d, want 3", len(payloads))
+		}
+	})
+
+	t.Run("ScanStreamReader rewinds seekable reader", func(t *testing.T) {
+		var payloads []string
+		env := newTestEnv(t, &mockClamAVServer{scanFunc: unavailableFirst(1, &payloads)})
+		defer env.close()
+		env.client.retry = &policy
+		env.client.chunkSize = 4
+
+		_, err := env.client.ScanStreamReader(context.Background(), strings.NewReader("rewindable"), "seek.txt")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(payloads) != 2 || payloads[1] != "rewindable" {
+			t.Errorf("payloads = %q, want two full copies", payloads)
+		}
+	})
+
+	t.Run("ScanStreamReader does not retry non-seekable reader", func(t *testing.T) {
+		var payloads []string
+		env := newTestEnv(t, &mockClamAVServer{scanFunc: unavailableFirst(1, &payloads)})
+		defer env.close()
+		env.client.retry = &policy
+
+		_, err := env.client.ScanStreamReader(context.Background(), io.MultiReader(strings.NewReader("once")), "once.txt")
+		if !clamav.IsConnectionError(err) {
+			t.Errorf("expected connection error, got: %v", err)
+		}
+		if len(payloads) != 1 {
+			t.Errorf("attempts = %d, want 1", len(payloads))
+		}
+	})
+
+	t.Run("option sets policy", func(t *testing.T) {
+		c := &Client{}
+		WithRetryPolicy(policy)(c)
+		if c.retry == nil || c.retry.MaxAttempts != 3 {
+			t.Errorf("retry = %+v, want MaxAttempts 3", c.retry)
+		}
+	})
+}
+
+// --- Scanner interface tests ---
+
+func TestScannerInterface(t *testing.T) {
+	env := newTestEnv(t, &mockClamAVServer{})
+	defer env.close()
+
+	var s clamav.Scanner = env.client
+
+	t.Run("ScanReader", func(t *testing.T) {
+		result, err := s.ScanReader(context.Background(), strings.NewReader("reader data"), "reader.txt")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.Filename != "reader.txt" {
+			t.Errorf("Filename = %q, want %q", result.Filename, "reader.txt")
+		}
+	})
+
+	t.Run("StreamScanReader unknown size", func(t *testing.T) {
+		result, err := s.StreamScanReader(context.Background(), strings.NewReader("stream data"), "stream.txt", clamav.UnknownSize)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !result.IsClean() {
+			t.Errorf("expected clean, got status %q", result.Status)
+		}
+	})
+
+	t.Run("Version unsupported", func(t *testing.T) {
+		_, err := s.Version(context.Background())
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		var sdkErr *clamav.Error
+		if !errors.As(err, &sdkErr) || sdkErr.StatusCode != 501 {
+			t.Errorf("expected service error with status 501, got: %v", err)
+		}
+	})
+}
+
 // --- Close tests ---
 
 func TestClose(t *testing.T) {
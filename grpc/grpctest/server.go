@@ -0,0 +1,349 @@
+// Package grpctest provides an in-memory fake of the ClamAV gRPC API for tests of code
+// that uses the gRPC client, without Docker or network ports.
+//
+// The fake implements pb.ClamAVScannerServer over a bufconn listener. It reassembles
+// chunked streams, reports content containing the EICAR test string as infected, and
+// can inject errors and latency:
+//
+//	srv := grpctest.NewServer()
+//	defer srv.Close()
+//
+//	client, err := srv.Client()
+//	...
+//	result, err := client.ScanStream(ctx, []byte(clamavtest.EICAR), "eicar.com")
+//	// result.Status == "FOUND"
+//
+//	srv.FailNext(grpctest.MethodScanFile, status.Error(codes.Unavailable, "clamd down"))
+package grpctest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/DevHatRo/clamav-api-sdk-go/clamavtest"
+	clamavgrpc "github.com/DevHatRo/clamav-api-sdk-go/grpc"
+	pb "github.com/DevHatRo/clamav-api-sdk-go/grpc/proto"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// Full method names, for FailNext and Request.Method.
+const (
+	MethodHealthCheck  = pb.ClamAVScanner_HealthCheck_FullMethodName
+	MethodScanFile     = pb.ClamAVScanner_ScanFile_FullMethodName
+	MethodScanStream   = pb.ClamAVScanner_ScanStream_FullMethodName
+	MethodScanMultiple = pb.ClamAVScanner_ScanMultiple_FullMethodName
+)
+
+const (
+	bufSize  = 1024 * 1024
+	scanTime = 0.001
+)
+
+// ScanFunc decides the response for the content of one file. Returning an error fails
+// the RPC, or, in ScanMultiple, produces a response with Status "ERROR" for the file.
+type ScanFunc func(data []byte, filename string) (*pb.ScanResponse, error)
+
+// Request is a file received by the fake server.
+type Request struct {
+	// Method is the full method name, e.g. MethodScanStream.
+	Method string
+	// Filename is the file name sent by the client.
+	Filename string
+	// Data is the reassembled file content.
+	Data []byte
+	// Chunks is the number of stream messages the file was sent in (1 for ScanFile).
+	Chunks int
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithScanFunc replaces the default EICAR detection.
+func WithScanFunc(fn ScanFunc) Option {
+	return func(s *Server) {
+		if fn != nil {
+			s.scanFunc = fn
+		}
+	}
+}
+
+// WithLatency delays every RPC by d, e.g. to trigger client timeouts.
+func WithLatency(d time.Duration) Option {
+	return func(s *Server) {
+		s.latency = d
+	}
+}
+
+// WithServerOptions sets options of the underlying grpc.Server, e.g. interceptors.
+func WithServerOptions(opts ...grpclib.ServerOption) Option {
+	return func(s *Server) {
+		s.serverOpts = append(s.serverOpts, opts...)
+	}
+}
+
+// Server is a fake ClamAV gRPC server. It is safe for concurrent use.
+type Server struct {
+	pb.UnimplementedClamAVScannerServer
+
+	lis        *bufconn.Listener
+	srv        *grpclib.Server
+	scanFunc   ScanFunc
+	serverOpts []grpclib.ServerOption
+
+	mu       sync.Mutex
+	latency  time.Duration
+	healthy  bool
+	failures []failure
+	requests []Request
+}
+
+var _ pb.ClamAVScannerServer = (*Server)(nil)
+
+// failure is an error injected with FailNext.
+type failure struct {
+	method string
+	err    error
+}
+
+// NewServer starts a fake server on an in-memory listener. The caller should call
+// Close when finished.
+func NewServer(opts ...Option) *Server {
+	s := &Server{healthy: true, lis: bufconn.Listen(bufSize)}
+	s.scanFunc = detectEICAR
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	serverOpts := append([]grpclib.ServerOption{
+		grpclib.ChainUnaryInterceptor(s.unaryInterceptor),
+		grpclib.ChainStreamInterceptor(s.streamInterceptor),
+	}, s.serverOpts...)
+	s.srv = grpclib.NewServer(serverOpts...)
+	pb.RegisterClamAVScannerServer(s.srv, s)
+	go func() {
+		_ = s.srv.Serve(s.lis)
+	}()
+	return s
+}
+
+// Close stops the server and closes the listener.
+func (s *Server) Close() {
+	s.srv.Stop()
+	_ = s.lis.Close()
+}
+
+// Dialer returns a dialer for grpc.WithContextDialer that connects to the server.
+func (s *Server) Dialer() func(context.Context, string) (net.Conn, error) {
+	return func(ctx context.Context, _ string) (net.Conn, error) {
+		return s.lis.DialContext(ctx)
+	}
+}
+
+// Client returns a gRPC client connected to the server. opts are applied after the
+// options that connect it; the caller should close the client when finished.
+func (s *Server) Client(opts ...clamavgrpc.ClientOption) (*clamavgrpc.Client, error) {
+	opts = append([]clamavgrpc.ClientOption{
+		clamavgrpc.WithDialOptions(grpclib.WithContextDialer(s.Dialer())),
+	}, opts...)
+	return clamavgrpc.NewClient("passthrough:///bufconn", opts...)
+}
+
+// SetHealthy sets whether HealthCheck reports the service as healthy.
+func (s *Server) SetHealthy(healthy bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.healthy = healthy
+}
+
+// SetLatency changes the delay added to every RPC.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// FailNext makes the next call of method (any method if empty) fail with err, which
+// should be a status error such as status.Error(codes.Unavailable, "..."). Failures
+// are queued, so calling FailNext n times fails the next n matching calls.
+func (s *Server) FailNext(method string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, failure{method: method, err: err})
+}
+
+// Requests returns the files received so far, in order.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Reset clears the recorded requests, pending failures and latency, and makes the
+// service healthy.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests, s.failures, s.latency, s.healthy = nil, nil, 0, true
+}
+
+// HealthCheck implements pb.ClamAVScannerServer.
+func (s *Server) HealthCheck(context.Context, *pb.HealthCheckRequest) (*pb.HealthCheckResponse, error) {
+	s.mu.Lock()
+	healthy := s.healthy
+	s.mu.Unlock()
+
+	if !healthy {
+		return &pb.HealthCheckResponse{Status: "unhealthy", Message: "clamd is not responding"}, nil
+	}
+	return &pb.HealthCheckResponse{Status: "healthy", Message: "ok"}, nil
+}
+
+// ScanFile implements pb.ClamAVScannerServer.
+func (s *Server) ScanFile(_ context.Context, req *pb.ScanFileRequest) (*pb.ScanResponse, error) {
+	if len(req.Data) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "file data is required")
+	}
+	s.record(MethodScanFile, req.Filename, req.Data, 1)
+	return s.scanFunc(req.Data, req.Filename)
+}
+
+// ScanStream implements pb.ClamAVScannerServer.
+func (s *Server) ScanStream(stream pb.ClamAVScanner_ScanStreamServer) error {
+	f, err := receiveFile(stream)
+	if err != nil {
+		return err
+	}
+	resp, err := s.scan(MethodScanStream, f)
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(resp)
+}
+
+// ScanMultiple implements pb.ClamAVScannerServer. Each file ends with a chunk that
+// has IsLast set, and gets one response; a file that fails to scan gets a response
+// with Status "ERROR".
+func (s *Server) ScanMultiple(stream pb.ClamAVScanner_ScanMultipleServer) error {
+	for {
+		f, err := receiveFile(stream)
+		if err != nil {
+			return err
+		}
+		if f.chunks == 0 {
+			return nil
+		}
+		resp, err := s.scan(MethodScanMultiple, f)
+		if err != nil {
+			resp = &pb.ScanResponse{Status: "ERROR", Message: status.Convert(err).Message(), Filename: f.filename}
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+// receivedFile is a file reassembled from stream chunks.
+type receivedFile struct {
+	filename string
+	data     []byte
+	chunks   int
+}
+
+// receiveFile reads chunks until one has IsLast set or the client closes its side.
+// It returns a file with no chunks if the stream ended before any chunk.
+func receiveFile(stream interface {
+	Recv() (*pb.ScanStreamRequest, error)
+}) (receivedFile, error) {
+	var f receivedFile
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return f, nil
+		}
+		if err != nil {
+			return f, err
+		}
+		f.chunks++
+		if f.filename == "" {
+			f.filename = req.Filename
+		}
+		f.data = append(f.data, req.Chunk...)
+		if req.IsLast {
+			return f, nil
+		}
+	}
+}
+
+// scan records a reassembled file and scans it.
+func (s *Server) scan(method string, f receivedFile) (*pb.ScanResponse, error) {
+	if len(f.data) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "file data is required")
+	}
+	s.record(method, f.filename, f.data, f.chunks)
+	return s.scanFunc(f.data, f.filename)
+}
+
+func (s *Server) record(method, filename string, data []byte, chunks int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, Request{
+		Method:   method,
+		Filename: filename,
+		Data:     append([]byte(nil), data...),
+		Chunks:   chunks,
+	})
+}
+
+// detectEICAR is the default ScanFunc.
+func detectEICAR(data []byte, filename string) (*pb.ScanResponse, error) {
+	resp := &pb.ScanResponse{Status: "OK", ScanTime: scanTime, Filename: filename}
+	if bytes.Contains(data, []byte(clamavtest.EICAR)) {
+		resp.Status, resp.Message = "FOUND", clamavtest.EICARSignature
+	}
+	return resp, nil
+}
+
+// intercept applies the latency and the next injected failure to a call.
+func (s *Server) intercept(ctx context.Context, method string) error {
+	s.mu.Lock()
+	latency := s.latency
+	var err error
+	for i, f := range s.failures {
+		if f.method == "" || f.method == method {
+			err = f.err
+			s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+	return err
+}
+
+func (s *Server) unaryInterceptor(ctx context.Context, req any, info *grpclib.UnaryServerInfo, handler grpclib.UnaryHandler) (any, error) {
+	if err := s.intercept(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) streamInterceptor(srv any, ss grpclib.ServerStream, info *grpclib.StreamServerInfo, handler grpclib.StreamHandler) error {
+	if err := s.intercept(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
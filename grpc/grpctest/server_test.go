@@ -0,0 +1,242 @@
+package grpctest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	clamav "github.com/DevHatRo/clamav-api-sdk-go"
+	"github.com/DevHatRo/clamav-api-sdk-go/clamavtest"
+	clamavgrpc "github.com/DevHatRo/clamav-api-sdk-go/grpc"
+	pb "github.com/DevHatRo/clamav-api-sdk-go/grpc/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newTestServer(t *testing.T, opts ...Option) (*Server, *clamavgrpc.Client) {
+	t.Helper()
+	srv := NewServer(opts...)
+	t.Cleanup(srv.Close)
+	return srv, newClient(t, srv)
+}
+
+func newClient(t *testing.T, srv *Server, opts ...clamavgrpc.ClientOption) *clamavgrpc.Client {
+	t.Helper()
+	client, err := srv.Client(opts...)
+	if err != nil {
+		t.Fatalf("Client: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+// --- Server tests ---
+
+func TestHealthCheck(t *testing.T) {
+	srv, client := newTestServer(t)
+	ctx := context.Background()
+
+	health, err := client.HealthCheck(ctx)
+	if err != nil || !health.Healthy {
+		t.Errorf("HealthCheck = %+v, %v", health, err)
+	}
+
+	srv.SetHealthy(false)
+	health, err = client.HealthCheck(ctx)
+	if err != nil || health.Healthy {
+		t.Errorf("HealthCheck = %+v, %v, want unhealthy", health, err)
+	}
+}
+
+func TestScan(t *testing.T) {
+	srv, client := newTestServer(t)
+	chunked := newClient(t, srv, clamavgrpc.WithChunkSize(8))
+	ctx := context.Background()
+	eicar := []byte("x" + clamavtest.EICAR)
+
+	tests := []struct {
+		name   string
+		scan   func() (*clamav.ScanResult, error)
+		method string
+		data   []byte
+		chunks int
+		status clamav.ScanStatus
+	}{
+		{"ScanFile clean", func() (*clamav.ScanResult, error) {
+			return client.ScanFile(ctx, []byte("hello"), "a.txt")
+		}, MethodScanFile, []byte("hello"), 1, "OK"},
+		{"ScanFile EICAR", func() (*clamav.ScanResult, error) {
+			return client.ScanFile(ctx, eicar, "a.txt")
+		}, MethodScanFile, eicar, 1, "FOUND"},
+		{"ScanStream reassembles chunks", func() (*clamav.ScanResult, error) {
+			return chunked.ScanStream(ctx, eicar, "a.txt")
+		}, MethodScanStream, eicar, 9, "FOUND"},
+		{"ScanStreamReader", func() (*clamav.ScanResult, error) {
+			return chunked.ScanStreamReader(ctx, strings.NewReader("hello world"), "a.txt")
+		}, MethodScanStream, []byte("hello world"), 2, "OK"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv.Reset()
+			result, err := tt.scan()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Status != tt.status || result.Filename != "a.txt" {
+				t.Errorf("result = %+v, want status %s", result, tt.status)
+			}
+			if tt.status == "FOUND" && result.Message != clamavtest.EICARSignature {
+				t.Errorf("Message = %q, want %q", result.Message, clamavtest.EICARSignature)
+			}
+
+			reqs := srv.Requests()
+			if len(reqs) != 1 {
+				t.Fatalf("got %d requests, want 1", len(reqs))
+			}
+			r := reqs[0]
+			if r.Method != tt.method || r.Filename != "a.txt" || !bytes.Equal(r.Data, tt.data) || r.Chunks != tt.chunks {
+				t.Errorf("request = {%s %s %d bytes %d chunks}, want {%s a.txt %d bytes %d chunks}",
+					r.Method, r.Filename, len(r.Data), r.Chunks, tt.method, len(tt.data), tt.chunks)
+			}
+		})
+	}
+
+	t.Run("empty file", func(t *testing.T) {
+		if _, err := client.ScanFile(ctx, nil, "empty.txt"); !clamav.IsValidationError(err) {
+			t.Errorf("ScanFile: expected validation error, got: %v", err)
+		}
+		if _, err := client.ScanStream(ctx, nil, "empty.txt"); !clamav.IsValidationError(err) {
+			t.Errorf("ScanStream: expected validation error, got: %v", err)
+		}
+	})
+}
+
+func TestScanMultiple(t *testing.T) {
+	srv := NewServer(WithScanFunc(func(data []byte, filename string) (*pb.ScanResponse, error) {
+		if string(data) == "broken" {
+			return nil, status.Error(codes.Internal, "scan failed")
+		}
+		return &pb.ScanResponse{Status: "OK", Filename: filename}, nil
+	}))
+	t.Cleanup(srv.Close)
+	client := newClient(t, srv, clamavgrpc.WithChunkSize(4))
+
+	files := []clamav.FileInput{
+		{Data: []byte("first file"), Filename: "a.txt"},
+		{Data: []byte("broken"), Filename: "b.txt"},
+		{Data: []byte("third"), Filename: "c.txt"},
+	}
+	got := map[string]*clamav.ScanResult{}
+	err := client.ScanMultipleCallback(context.Background(), files, func(r *clamav.ScanResult) {
+		got[r.Filename] = r
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 3 || got["a.txt"].Status != "OK" || got["c.txt"].Status != "OK" {
+		t.Errorf("results = %+v", got)
+	}
+	if r := got["b.txt"]; r == nil || r.Status != "ERROR" || r.Message != "scan failed" {
+		t.Errorf("b.txt = %+v, want ERROR result", r)
+	}
+
+	reqs := srv.Requests()
+	if len(reqs) != 3 {
+		t.Fatalf("got %d requests, want 3", len(reqs))
+	}
+	for i, r := range reqs {
+		if r.Method != MethodScanMultiple || r.Filename != files[i].Filename || !bytes.Equal(r.Data, files[i].Data) {
+			t.Errorf("request %d = %+v", i, r)
+		}
+	}
+	if reqs[0].Chunks != 3 {
+		t.Errorf("Chunks = %d, want 3", reqs[0].Chunks)
+	}
+}
+
+func TestFailNext(t *testing.T) {
+	srv, client := newTestServer(t)
+	ctx := context.Background()
+
+	t.Run("method", func(t *testing.T) {
+		srv.FailNext(MethodScanFile, status.Error(codes.Unavailable, "clamd down"))
+
+		// Other methods are not affected.
+		if _, err := client.ScanStream(ctx, []byte("hello"), "a.txt"); err != nil {
+			t.Fatalf("ScanStream: %v", err)
+		}
+		_, err := client.ScanFile(ctx, []byte("hello"), "a.txt")
+		if !clamav.IsConnectionError(err) || !strings.Contains(err.Error(), "clamd down") {
+			t.Errorf("expected connection error, got: %v", err)
+		}
+		// Only the next call fails.
+		if _, err := client.ScanFile(ctx, []byte("hello"), "a.txt"); err != nil {
+			t.Errorf("unexpected error after the injected failure: %v", err)
+		}
+	})
+
+	t.Run("any method", func(t *testing.T) {
+		srv.FailNext("", status.Error(codes.Internal, "boom"))
+		if _, err := client.HealthCheck(ctx); !clamav.IsServiceError(err) {
+			t.Errorf("expected service error, got: %v", err)
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		srv.FailNext(MethodScanStream, status.Error(codes.InvalidArgument, "bad chunk"))
+		if _, err := client.ScanStream(ctx, []byte("hello"), "a.txt"); !clamav.IsValidationError(err) {
+			t.Errorf("expected validation error, got: %v", err)
+		}
+	})
+
+	t.Run("retried by the client", func(t *testing.T) {
+		srv.Reset()
+		srv.FailNext(MethodScanFile, status.Error(codes.Unavailable, "clamd down"))
+		retrying := newClient(t, srv, clamavgrpc.WithRetryPolicy(clamav.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}))
+		if _, err := retrying.ScanFile(ctx, []byte("hello"), "a.txt"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// The failed call never reached the handler.
+		if n := len(srv.Requests()); n != 1 {
+			t.Errorf("got %d requests, want 1", n)
+		}
+	})
+}
+
+func TestLatency(t *testing.T) {
+	srv, _ := newTestServer(t, WithLatency(time.Second))
+	slow := newClient(t, srv, clamavgrpc.WithTimeout(50*time.Millisecond))
+	ctx := context.Background()
+
+	if _, err := slow.ScanFile(ctx, []byte("hello"), "a.txt"); !clamav.IsTimeoutError(err) {
+		t.Errorf("ScanFile: expected timeout error, got: %v", err)
+	}
+	if _, err := slow.ScanStream(ctx, []byte("hello"), "a.txt"); !clamav.IsTimeoutError(err) {
+		t.Errorf("ScanStream: expected timeout error, got: %v", err)
+	}
+
+	srv.SetLatency(0)
+	if _, err := slow.ScanFile(ctx, []byte("hello"), "a.txt"); err != nil {
+		t.Errorf("unexpected error without latency: %v", err)
+	}
+}
+
+func TestClose(t *testing.T) {
+	srv := NewServer()
+	client, err := srv.Client(clamavgrpc.WithTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+
+	srv.Close()
+	_, err = client.ScanFile(context.Background(), []byte("hello"), "a.txt")
+	var cerr *clamav.Error
+	if err == nil || !errors.As(err, &cerr) {
+		t.Errorf("expected *clamav.Error after Close, got: %v", err)
+	}
+}
@@ -0,0 +1,253 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	clamav "github.com/DevHatRo/clamav-api-sdk-go"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	// ReasonMalwareDetected is the ErrorInfo reason of requests rejected as infected.
+	ReasonMalwareDetected = "MALWARE_DETECTED"
+	// ReasonScanFailed is the ErrorInfo reason of requests rejected because a field could not be scanned.
+	ReasonScanFailed = "SCAN_FAILED"
+	// ErrorInfoDomain is the ErrorInfo domain of errors returned by the interceptors.
+	ErrorInfoDomain = "clamav"
+)
+
+// InterceptorConfig selects the bytes fields scanned by the server interceptors.
+type InterceptorConfig struct {
+	// FieldPaths are dot-separated field names from the request message to a bytes field,
+	// e.g. "data" or "attachments.content". Repeated message and bytes fields along the path
+	// are scanned element by element. A path can be restricted to one message type by
+	// prefixing it with the full message name and a colon, e.g. "myapp.v1.UploadRequest:data".
+	// Paths that do not resolve in a message are ignored for that message.
+	FieldPaths []string
+	// FieldOption, if set, is a bool extension of google.protobuf.FieldOptions: every
+	// populated bytes field annotated with the option set to true is scanned, at any depth.
+	FieldOption protoreflect.ExtensionType
+	// FailOpen lets messages through when a field cannot be scanned, instead of
+	// rejecting them with codes.Unavailable.
+	FailOpen bool
+}
+
+// UnaryServerInterceptor returns an interceptor that scans the selected bytes fields of
+// each request with s before the handler runs.
+//
+// Infected requests are rejected with codes.PermissionDenied and an errdetails.ErrorInfo
+// detail with reason ReasonMalwareDetected and the field path and virus name as metadata.
+// Requests whose fields cannot be scanned are rejected with codes.Unavailable and reason
+// ReasonScanFailed, unless FailOpen is set.
+func UnaryServerInterceptor(s clamav.Scanner, cfg InterceptorConfig) grpclib.UnaryServerInterceptor {
+	fs := newFieldScanner(s, cfg)
+	return func(ctx context.Context, req any, _ *grpclib.UnaryServerInfo, handler grpclib.UnaryHandler) (any, error) {
+		if err := fs.check(ctx, req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns an interceptor that scans the selected bytes fields of
+// every message received on the stream. RecvMsg fails with the same errors as
+// UnaryServerInterceptor when a message is rejected.
+func StreamServerInterceptor(s clamav.Scanner, cfg InterceptorConfig) grpclib.StreamServerInterceptor {
+	fs := newFieldScanner(s, cfg)
+	return func(srv any, ss grpclib.ServerStream, _ *grpclib.StreamServerInfo, handler grpclib.StreamHandler) error {
+		return handler(srv, &scanningServerStream{ServerStream: ss, fs: fs})
+	}
+}
+
+// scanningServerStream scans messages as the handler receives them.
+type scanningServerStream struct {
+	grpclib.ServerStream
+	fs *fieldScanner
+}
+
+// RecvMsg receives a message and scans its selected fields.
+func (s *scanningServerStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return s.fs.check(s.Context(), m)
+}
+
+// fieldPath is a parsed InterceptorConfig.FieldPaths entry.
+type fieldPath struct {
+	message protoreflect.FullName // empty matches every message
+	names   []protoreflect.Name
+}
+
+// payload is the value of one selected bytes field.
+type payload struct {
+	path string
+	data []byte
+}
+
+// fieldScanner finds and scans the selected fields of messages.
+type fieldScanner struct {
+	scanner  clamav.Scanner
+	paths    []fieldPath
+	option   protoreflect.ExtensionType
+	failOpen bool
+}
+
+func newFieldScanner(s clamav.Scanner, cfg InterceptorConfig) *fieldScanner {
+	fs := &fieldScanner{scanner: s, option: cfg.FieldOption, failOpen: cfg.FailOpen}
+	for _, raw := range cfg.FieldPaths {
+		var p fieldPath
+		if msg, path, ok := strings.Cut(raw, ":"); ok {
+			p.message, raw = protoreflect.FullName(msg), path
+		}
+		for _, name := range strings.Split(raw, ".") {
+			p.names = append(p.names, protoreflect.Name(name))
+		}
+		fs.paths = append(fs.paths, p)
+	}
+	return fs
+}
+
+// check scans the selected fields of m and returns a status error if it must be rejected.
+// Values that are not proto messages are let through.
+func (fs *fieldScanner) check(ctx context.Context, m any) error {
+	msg, ok := m.(proto.Message)
+	if !ok {
+		return nil
+	}
+
+	for _, p := range fs.collect(msg.ProtoReflect()) {
+		result, err := fs.scanner.ScanFile(ctx, p.data, p.path)
+		if err == nil {
+			err = result.Err()
+		}
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return status.FromContextError(ctxErr).Err()
+			}
+			if fs.failOpen {
+				continue
+			}
+			return rejection(codes.Unavailable, "virus scan unavailable", ReasonScanFailed, map[string]string{
+				"field": p.path,
+			})
+		}
+		if result.IsInfected() {
+			return rejection(codes.PermissionDenied, "infected payload rejected", ReasonMalwareDetected, map[string]string{
+				"field": p.path,
+				"virus": result.Message,
+			})
+		}
+	}
+	return nil
+}
+
+// collect returns the values of the selected bytes fields of m. Empty values are skipped.
+func (fs *fieldScanner) collect(m protoreflect.Message) []payload {
+	var out []payload
+	for _, p := range fs.paths {
+		if p.message != "" && p.message != m.Descriptor().FullName() {
+			continue
+		}
+		out = collectPath(m, p.names, "", out)
+	}
+	if fs.option != nil {
+		out = fs.collectAnnotated(m, "", out)
+	}
+	return out
+}
+
+// collectPath appends the values found along names in m.
+func collectPath(m protoreflect.Message, names []protoreflect.Name, prefix string, out []payload) []payload {
+	fd := m.Descriptor().Fields().ByName(names[0])
+	if fd == nil || fd.IsMap() || !m.Has(fd) {
+		return out
+	}
+	path := prefix + string(fd.Name())
+
+	if len(names) == 1 {
+		if fd.Kind() != protoreflect.BytesKind {
+			return out
+		}
+		return appendBytes(m.Get(fd), fd, path, out)
+	}
+
+	if fd.Message() == nil {
+		return out
+	}
+	if fd.IsList() {
+		list := m.Get(fd).List()
+		for i := 0; i < list.Len(); i++ {
+			out = collectPath(list.Get(i).Message(), names[1:], fmt.Sprintf("%s[%d].", path, i), out)
+		}
+		return out
+	}
+	return collectPath(m.Get(fd).Message(), names[1:], path+".", out)
+}
+
+// collectAnnotated appends the values of populated bytes fields annotated with the
+// field option, recursing into nested messages.
+func (fs *fieldScanner) collectAnnotated(m protoreflect.Message, prefix string, out []payload) []payload {
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		path := prefix + string(fd.Name())
+		switch {
+		case fd.Kind() == protoreflect.BytesKind && !fd.IsMap():
+			if annotated, _ := proto.GetExtension(fd.Options(), fs.option).(bool); annotated {
+				out = appendBytes(v, fd, path, out)
+			}
+		case fd.IsMap():
+			if fd.MapValue().Message() != nil {
+				v.Map().Range(func(k protoreflect.MapKey, mv protoreflect.Value) bool {
+					out = fs.collectAnnotated(mv.Message(), fmt.Sprintf("%s[%v].", path, k.Interface()), out)
+					return true
+				})
+			}
+		case fd.Message() != nil && fd.IsList():
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				out = fs.collectAnnotated(list.Get(i).Message(), fmt.Sprintf("%s[%d].", path, i), out)
+			}
+		case fd.Message() != nil:
+			out = fs.collectAnnotated(v.Message(), path+".", out)
+		}
+		return true
+	})
+	return out
+}
+
+// appendBytes appends a singular or repeated bytes value.
+func appendBytes(v protoreflect.Value, fd protoreflect.FieldDescriptor, path string, out []payload) []payload {
+	if !fd.IsList() {
+		if data := v.Bytes(); len(data) > 0 {
+			out = append(out, payload{path: path, data: data})
+		}
+		return out
+	}
+	list := v.List()
+	for i := 0; i < list.Len(); i++ {
+		if data := list.Get(i).Bytes(); len(data) > 0 {
+			out = append(out, payload{path: fmt.Sprintf("%s[%d]", path, i), data: data})
+		}
+	}
+	return out
+}
+
+// rejection builds a status error with an ErrorInfo detail.
+func rejection(code codes.Code, msg, reason string, metadata map[string]string) error {
+	st := status.New(code, msg)
+	if detailed, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   reason,
+		Domain:   ErrorInfoDomain,
+		Metadata: metadata,
+	}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
@@ -0,0 +1,293 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	pb "github.com/DevHatRo/clamav-api-sdk-go/grpc/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// eicarServer is a mock server that reports data containing "EICAR" as infected.
+func eicarServer() *mockClamAVServer {
+	return &mockClamAVServer{scanFunc: func(data []byte, filename string) (*pb.ScanResponse, error) {
+		if bytes.Contains(data, []byte("EICAR")) {
+			return &pb.ScanResponse{Status: "FOUND", Message: "Eicar-Test-Signature", Filename: filename}, nil
+		}
+		return &pb.ScanResponse{Status: "OK", Filename: filename}, nil
+	}}
+}
+
+// uploadTypes builds a "test.Upload" message type whose fields are annotated with a
+// dynamically created "test.scan" bool field option.
+func uploadTypes(t *testing.T) (protoreflect.MessageDescriptor, protoreflect.ExtensionType) {
+	t.Helper()
+
+	optFile, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String("test/scan_option.proto"),
+		Package:    proto.String("test"),
+		Dependency: []string{"google/protobuf/descriptor.proto"},
+		Extension: []*descriptorpb.FieldDescriptorProto{{
+			Name:     proto.String("scan"),
+			Number:   proto.Int32(50001),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     descriptorpb.FieldDescriptorProto_TYPE_BOOL.Enum(),
+			Extendee: proto.String(".google.protobuf.FieldOptions"),
+		}},
+	}, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatalf("failed to build option file: %v", err)
+	}
+	scanOpt := dynamicpb.NewExtensionType(optFile.Extensions().Get(0))
+
+	annotated := &descriptorpb.FieldOptions{}
+	proto.SetExtension(annotated, scanOpt, true)
+
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, repeated bool, opts *descriptorpb.FieldOptions) *descriptorpb.FieldDescriptorProto {
+		label := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+		if repeated {
+			label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+		}
+		fd := &descriptorpb.FieldDescriptorProto{
+			Name:    proto.String(name),
+			Number:  proto.Int32(number),
+			Label:   label.Enum(),
+			Type:    typ.Enum(),
+			Options: opts,
+		}
+		if typ == descriptorpb.FieldDescriptorProto_TYPE_MESSAGE {
+			fd.TypeName = proto.String(".test.Part")
+		}
+		return fd
+	}
+
+	files := new(protoregistry.Files)
+	if err := files.RegisterFile(optFile); err != nil {
+		t.Fatal(err)
+	}
+	desc, _ := protoregistry.GlobalFiles.FindFileByPath("google/protobuf/descriptor.proto")
+	if err := files.RegisterFile(desc); err != nil {
+		t.Fatal(err)
+	}
+
+	uploadFile, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String("test/upload.proto"),
+		Package:    proto.String("test"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"test/scan_option.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("Part"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("body", 1, descriptorpb.FieldDescriptorProto_TYPE_BYTES, false, annotated),
+					field("name", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING, false, nil),
+				},
+			},
+			{
+				Name: proto.String("Upload"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("content", 1, descriptorpb.FieldDescriptorProto_TYPE_BYTES, false, annotated),
+					field("thumbnail", 2, descriptorpb.FieldDescriptorProto_TYPE_BYTES, false, nil),
+					field("parts", 3, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, true, nil),
+					field("blobs", 4, descriptorpb.FieldDescriptorProto_TYPE_BYTES, true, nil),
+				},
+			},
+		},
+	}, files)
+	if err != nil {
+		t.Fatalf("failed to build upload file: %v", err)
+	}
+
+	return uploadFile.Messages().ByName("Upload"), scanOpt
+}
+
+// newUpload builds a test.Upload message.
+func newUpload(md protoreflect.MessageDescriptor, content, thumbnail string, parts, blobs []string) *dynamicpb.Message {
+	m := dynamicpb.NewMessage(md)
+	fields := md.Fields()
+	if content != "" {
+		m.Set(fields.ByName("content"), protoreflect.ValueOfBytes([]byte(content)))
+	}
+	if thumbnail != "" {
+		m.Set(fields.ByName("thumbnail"), protoreflect.ValueOfBytes([]byte(thumbnail)))
+	}
+	partsList := m.Mutable(fields.ByName("parts")).List()
+	partMD := fields.ByName("parts").Message()
+	for _, body := range parts {
+		part := dynamicpb.NewMessage(partMD)
+		part.Set(partMD.Fields().ByName("body"), protoreflect.ValueOfBytes([]byte(body)))
+		partsList.Append(protoreflect.ValueOfMessage(part))
+	}
+	blobList := m.Mutable(fields.ByName("blobs")).List()
+	for _, b := range blobs {
+		blobList.Append(protoreflect.ValueOfBytes([]byte(b)))
+	}
+	return m
+}
+
+// assertRejected checks that err is a status error with the given code, reason and field.
+func assertRejected(t *testing.T, err error, code codes.Code, reason, field string) {
+	t.Helper()
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != code {
+		t.Fatalf("expected %v status, got: %v", code, err)
+	}
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok {
+			if info.Reason != reason || info.Domain != ErrorInfoDomain || info.Metadata["field"] != field {
+				t.Errorf("ErrorInfo = %+v, want reason %s and field %s", info, reason, field)
+			}
+			return
+		}
+	}
+	t.Error("missing ErrorInfo detail")
+}
+
+// --- Interceptor tests ---
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	env := newTestEnv(t, eicarServer())
+	defer env.close()
+
+	handled := false
+	handler := func(context.Context, any) (any, error) {
+		handled = true
+		return "ok", nil
+	}
+	call := func(cfg InterceptorConfig, req any) error {
+		handled = false
+		_, err := UnaryServerInterceptor(env.client, cfg)(context.Background(), req, &grpclib.UnaryServerInfo{}, handler)
+		return err
+	}
+
+	t.Run("clean request reaches handler", func(t *testing.T) {
+		err := call(InterceptorConfig{FieldPaths: []string{"data"}}, &pb.ScanFileRequest{Data: []byte("clean")})
+		if err != nil || !handled {
+			t.Errorf("err = %v, handled = %v", err, handled)
+		}
+	})
+
+	t.Run("infected request is rejected", func(t *testing.T) {
+		err := call(InterceptorConfig{FieldPaths: []string{"data"}}, &pb.ScanFileRequest{Data: []byte("EICAR")})
+		if handled {
+			t.Error("handler should not be called")
+		}
+		assertRejected(t, err, codes.PermissionDenied, ReasonMalwareDetected, "data")
+	})
+
+	t.Run("message-qualified path", func(t *testing.T) {
+		cfg := InterceptorConfig{FieldPaths: []string{"clamav.v1.Other:data"}}
+		if err := call(cfg, &pb.ScanFileRequest{Data: []byte("EICAR")}); err != nil {
+			t.Errorf("path for another message should be ignored, got: %v", err)
+		}
+
+		md := (&pb.ScanFileRequest{}).ProtoReflect().Descriptor()
+		cfg = InterceptorConfig{FieldPaths: []string{string(md.FullName()) + ":data"}}
+		assertRejected(t, call(cfg, &pb.ScanFileRequest{Data: []byte("EICAR")}), codes.PermissionDenied, ReasonMalwareDetected, "data")
+	})
+
+	t.Run("nested and repeated paths", func(t *testing.T) {
+		md, _ := uploadTypes(t)
+		cfg := InterceptorConfig{FieldPaths: []string{"parts.body", "blobs"}}
+
+		if err := call(cfg, newUpload(md, "", "EICAR", []string{"a", "b"}, []string{"c"})); err != nil {
+			t.Errorf("unselected field should not be scanned, got: %v", err)
+		}
+		err := call(cfg, newUpload(md, "", "", []string{"a", "EICAR"}, nil))
+		assertRejected(t, err, codes.PermissionDenied, ReasonMalwareDetected, "parts[1].body")
+		err = call(cfg, newUpload(md, "", "", nil, []string{"a", "EICAR"}))
+		assertRejected(t, err, codes.PermissionDenied, ReasonMalwareDetected, "blobs[1]")
+	})
+
+	t.Run("field option", func(t *testing.T) {
+		md, scanOpt := uploadTypes(t)
+		cfg := InterceptorConfig{FieldOption: scanOpt}
+
+		if err := call(cfg, newUpload(md, "clean", "EICAR", []string{"clean"}, []string{"EICAR"})); err != nil {
+			t.Errorf("unannotated fields should not be scanned, got: %v", err)
+		}
+		err := call(cfg, newUpload(md, "clean", "", []string{"clean", "EICAR"}, nil))
+		assertRejected(t, err, codes.PermissionDenied, ReasonMalwareDetected, "parts[1].body")
+	})
+
+	t.Run("non-proto request passes through", func(t *testing.T) {
+		if err := call(InterceptorConfig{FieldPaths: []string{"data"}}, "not a message"); err != nil || !handled {
+			t.Errorf("err = %v, handled = %v", err, handled)
+		}
+	})
+}
+
+func TestInterceptorScanErrors(t *testing.T) {
+	env := newTestEnv(t, &mockClamAVServer{scanFunc: func([]byte, string) (*pb.ScanResponse, error) {
+		return nil, status.Error(codes.Unavailable, "clamd down")
+	}})
+	defer env.close()
+
+	handler := func(context.Context, any) (any, error) { return "ok", nil }
+	req := &pb.ScanFileRequest{Data: []byte("data")}
+
+	t.Run("fails closed", func(t *testing.T) {
+		_, err := UnaryServerInterceptor(env.client, InterceptorConfig{FieldPaths: []string{"data"}})(
+			context.Background(), req, &grpclib.UnaryServerInfo{}, handler)
+		assertRejected(t, err, codes.Unavailable, ReasonScanFailed, "data")
+	})
+
+	t.Run("fail open", func(t *testing.T) {
+		_, err := UnaryServerInterceptor(env.client, InterceptorConfig{FieldPaths: []string{"data"}, FailOpen: true})(
+			context.Background(), req, &grpclib.UnaryServerInfo{}, handler)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+// fakeServerStream delivers queued messages to RecvMsg.
+type fakeServerStream struct {
+	grpclib.ServerStream
+	msgs []*pb.ScanStreamRequest
+}
+
+func (s *fakeServerStream) Context() context.Context { return context.Background() }
+
+func (s *fakeServerStream) RecvMsg(m any) error {
+	next := s.msgs[0]
+	s.msgs = s.msgs[1:]
+	proto.Merge(m.(proto.Message), next)
+	return nil
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	env := newTestEnv(t, eicarServer())
+	defer env.close()
+
+	ss := &fakeServerStream{msgs: []*pb.ScanStreamRequest{
+		{Chunk: []byte("clean")},
+		{Chunk: []byte("EICAR")},
+	}}
+	var errs []error
+	handler := func(_ any, stream grpclib.ServerStream) error {
+		for i := 0; i < 2; i++ {
+			errs = append(errs, stream.RecvMsg(&pb.ScanStreamRequest{}))
+		}
+		return nil
+	}
+
+	interceptor := StreamServerInterceptor(env.client, InterceptorConfig{FieldPaths: []string{"chunk"}})
+	if err := interceptor(nil, ss, &grpclib.StreamServerInfo{}, handler); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if errs[0] != nil {
+		t.Errorf("first message: unexpected error: %v", errs[0])
+	}
+	assertRejected(t, errs[1], codes.PermissionDenied, ReasonMalwareDetected, "chunk")
+}
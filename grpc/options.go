@@ -3,6 +3,7 @@ package grpc
 import (
 	"time"
 
+	clamav "github.com/DevHatRo/clamav-api-sdk-go"
 	grpclib "google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 )
@@ -60,3 +61,12 @@ func WithMaxMessageSize(size int) ClientOption {
 		}
 	}
 }
+
+// WithRetryPolicy enables automatic retries of transient failures such as
+// codes.Unavailable. ScanStreamReader only retries readers that implement
+// io.Seeker, and ScanMultiple is never retried.
+func WithRetryPolicy(policy clamav.RetryPolicy) ClientOption {
+	return func(c *Client) {
+		c.retry = &policy
+	}
+}
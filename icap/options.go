@@ -0,0 +1,92 @@
+package icap
+
+import (
+	"html/template"
+	"time"
+)
+
+const (
+	defaultISTag           = `"clamav-api-sdk-go"`
+	defaultPreview         = 1024
+	defaultIdleTimeout     = 60 * time.Second
+	defaultMemoryThreshold = 8 * 1024 * 1024 // 8MB
+	defaultBlockStatus     = 403
+)
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithISTag sets the ISTag sent in every response (default: "clamav-api-sdk-go").
+// Clients invalidate cached verdicts when it changes, so set it to something that
+// changes with the signature database, e.g. its version. The quotes required by
+// RFC 3507 are added if missing.
+func WithISTag(tag string) Option {
+	return func(s *Server) {
+		if tag == "" {
+			return
+		}
+		if tag[0] != '"' {
+			tag = `"` + tag + `"`
+		}
+		s.istag = tag
+	}
+}
+
+// WithPreview sets the preview size advertised by OPTIONS (default: 1024 bytes).
+// Zero disables previews.
+func WithPreview(n int) Option {
+	return func(s *Server) {
+		if n >= 0 {
+			s.preview = n
+		}
+	}
+}
+
+// WithBlockPage sets the HTTP response that replaces infected content: its status
+// (default: 403 Forbidden) and an HTML template executed with an Infection.
+func WithBlockPage(status int, tmpl *template.Template) Option {
+	return func(s *Server) {
+		if status >= 100 && status <= 999 {
+			s.blockStatus = status
+		}
+		if tmpl != nil {
+			s.blockPage = tmpl
+		}
+	}
+}
+
+// WithFailOpen lets content through when it cannot be scanned, instead of responding
+// 500 Server Error, which most clients treat as a failed adaptation.
+func WithFailOpen(failOpen bool) Option {
+	return func(s *Server) {
+		s.failOpen = failOpen
+	}
+}
+
+// WithIdleTimeout sets how long a connection may wait for its next request before it
+// is closed (default: 60s).
+func WithIdleTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		if d > 0 {
+			s.idleTimeout = d
+		}
+	}
+}
+
+// WithMemoryThreshold sets how much of a body is buffered in memory before it is
+// spilled to a temporary file (default: 8MB). Bodies are only buffered when the
+// client does not accept 204 No Content, so that clean content can be sent back.
+func WithMemoryThreshold(n int64) Option {
+	return func(s *Server) {
+		if n >= 0 {
+			s.memoryThreshold = n
+		}
+	}
+}
+
+// WithTempDir sets the directory for spilled bodies (default: os.TempDir()).
+func WithTempDir(dir string) Option {
+	return func(s *Server) {
+		s.tempDir = dir
+	}
+}
@@ -0,0 +1,297 @@
+package icap
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxHeaderSize bounds an encapsulated HTTP header section.
+	maxHeaderSize = 64 * 1024
+	// maxChunkLine bounds a chunk-size line, including its extensions.
+	maxChunkLine = 4096
+)
+
+// Encapsulated entity names (RFC 3507, section 4.4.1).
+const (
+	entityReqHdr   = "req-hdr"
+	entityResHdr   = "res-hdr"
+	entityReqBody  = "req-body"
+	entityResBody  = "res-body"
+	entityOptBody  = "opt-body"
+	entityNullBody = "null-body"
+)
+
+var (
+	// errMalformed is wrapped by errors in requests that do not follow RFC 3507.
+	errMalformed = errors.New("malformed ICAP request")
+	// errVersion is returned for requests in another version than ICAP/1.0.
+	errVersion = errors.New("unsupported ICAP version")
+)
+
+// request is a parsed ICAP request. The body, if any, has not been read yet.
+type request struct {
+	method string
+	uri    *url.URL
+	header textproto.MIMEHeader
+
+	// reqHdr and resHdr are the raw encapsulated HTTP headers, or nil.
+	reqHdr []byte
+	resHdr []byte
+	// body is the name of the body entity: entityReqBody, entityResBody,
+	// entityOptBody, or empty for none.
+	body string
+	// preview is the Preview header, or -1 without preview.
+	preview int
+	// allow204 is set when the client accepts 204 outside of a preview.
+	allow204 bool
+}
+
+// readRequest reads the request line, the ICAP headers and the encapsulated HTTP
+// headers of the next request.
+func readRequest(br *bufio.Reader) (*request, error) {
+	tp := textproto.NewReader(br)
+	line, err := tp.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	method, rest, ok1 := strings.Cut(line, " ")
+	rawURI, proto, ok2 := strings.Cut(rest, " ")
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("%w: request line %q", errMalformed, line)
+	}
+	if proto != "ICAP/1.0" {
+		return nil, errVersion
+	}
+	uri, err := url.Parse(rawURI)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errMalformed, err)
+	}
+	header, err := tp.ReadMIMEHeader()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errMalformed, err)
+	}
+
+	req := &request{method: method, uri: uri, header: header, preview: -1}
+	if p := header.Get("Preview"); p != "" {
+		if req.preview, err = strconv.Atoi(p); err != nil || req.preview < 0 {
+			return nil, fmt.Errorf("%w: Preview %q", errMalformed, p)
+		}
+	}
+	for _, v := range strings.Split(header.Get("Allow"), ",") {
+		if strings.TrimSpace(v) == "204" {
+			req.allow204 = true
+		}
+	}
+
+	entities, err := parseEncapsulated(header.Get("Encapsulated"))
+	if err != nil {
+		return nil, err
+	}
+	for i, e := range entities {
+		switch e.name {
+		case entityReqHdr, entityResHdr:
+			if i+1 == len(entities) {
+				return nil, fmt.Errorf("%w: Encapsulated ends with %s", errMalformed, e.name)
+			}
+			hdr := make([]byte, entities[i+1].offset-e.offset)
+			if _, err := io.ReadFull(br, hdr); err != nil {
+				return nil, fmt.Errorf("%w: reading %s: %v", errMalformed, e.name, err)
+			}
+			if e.name == entityReqHdr {
+				req.reqHdr = hdr
+			} else {
+				req.resHdr = hdr
+			}
+		case entityReqBody, entityResBody, entityOptBody:
+			req.body = e.name
+		}
+	}
+	return req, nil
+}
+
+// entity is one element of the Encapsulated header.
+type entity struct {
+	name   string
+	offset int
+}
+
+// parseEncapsulated parses an Encapsulated header such as
+// "req-hdr=0, res-hdr=137, res-body=296". Header entities come first, in increasing
+// order, and a single body entity comes last. An empty header means no entities.
+func parseEncapsulated(v string) ([]entity, error) {
+	if v == "" {
+		return nil, nil
+	}
+	var entities []entity
+	for _, field := range strings.Split(v, ",") {
+		name, off, ok := strings.Cut(strings.TrimSpace(field), "=")
+		offset, err := strconv.Atoi(off)
+		if !ok || err != nil || offset < 0 {
+			return nil, fmt.Errorf("%w: Encapsulated %q", errMalformed, v)
+		}
+		if n := len(entities); n > 0 {
+			last := entities[n-1].name
+			if offset < entities[n-1].offset || (last != entityReqHdr && last != entityResHdr) {
+				return nil, fmt.Errorf("%w: Encapsulated %q", errMalformed, v)
+			}
+		}
+		switch name {
+		case entityReqHdr, entityResHdr, entityReqBody, entityResBody, entityOptBody, entityNullBody:
+		default:
+			return nil, fmt.Errorf("%w: Encapsulated entity %q", errMalformed, name)
+		}
+		if offset-lastOffset(entities) > maxHeaderSize {
+			return nil, fmt.Errorf("%w: encapsulated header larger than %d bytes", errMalformed, maxHeaderSize)
+		}
+		entities = append(entities, entity{name: name, offset: offset})
+	}
+	return entities, nil
+}
+
+// lastOffset returns the offset of the last entity, or 0.
+func lastOffset(entities []entity) int {
+	if len(entities) == 0 {
+		return 0
+	}
+	return entities[len(entities)-1].offset
+}
+
+// httpRequest parses the encapsulated HTTP request header, or returns nil.
+func (r *request) httpRequest() *http.Request {
+	if r.reqHdr == nil {
+		return nil
+	}
+	req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(r.reqHdr)))
+	if err != nil {
+		return nil
+	}
+	return req
+}
+
+// httpResponse parses the encapsulated HTTP response header, or returns nil.
+func (r *request) httpResponse() *http.Response {
+	if r.resHdr == nil {
+		return nil
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(r.resHdr)), nil)
+	if err != nil {
+		return nil
+	}
+	return resp
+}
+
+// chunkedReader decodes an encapsulated body. Reads return io.EOF at the zero-length
+// chunk that ends the body or, in a preview, the preview.
+type chunkedReader struct {
+	br   *bufio.Reader
+	n    int64 // bytes left in the current chunk
+	eof  bool  // the zero-length chunk was read
+	ieof bool  // the zero-length chunk had the "ieof" extension
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	if c.eof {
+		return 0, io.EOF
+	}
+	if c.n == 0 {
+		if err := c.nextChunk(); err != nil {
+			return 0, err
+		}
+		if c.eof {
+			return 0, io.EOF
+		}
+	}
+	if int64(len(p)) > c.n {
+		p = p[:c.n]
+	}
+	n, err := c.br.Read(p)
+	c.n -= int64(n)
+	if c.n == 0 && err == nil {
+		err = c.readCRLF()
+	}
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// nextChunk reads a chunk-size line, and the trailer after the last chunk.
+func (c *chunkedReader) nextChunk() error {
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	size, ext, _ := strings.Cut(line, ";")
+	n, err := strconv.ParseInt(strings.TrimSpace(size), 16, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("%w: chunk size %q", errMalformed, line)
+	}
+	if n > 0 {
+		c.n = n
+		return nil
+	}
+
+	c.eof, c.ieof = true, strings.TrimSpace(ext) == "ieof"
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return err
+		}
+		if line == "" {
+			return nil
+		}
+	}
+}
+
+// continueBody prepares the reader for the rest of the body after a preview.
+func (c *chunkedReader) continueBody() {
+	c.eof = false
+}
+
+func (c *chunkedReader) readLine() (string, error) {
+	line, err := c.br.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) || len(line) > maxChunkLine {
+		return "", fmt.Errorf("%w: chunk line too long", errMalformed)
+	}
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+func (c *chunkedReader) readCRLF() error {
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	if line != "" {
+		return fmt.Errorf("%w: missing CRLF after chunk", errMalformed)
+	}
+	return nil
+}
+
+// writeChunked writes r as a chunked body, ending with the zero-length chunk.
+func writeChunked(w io.Writer, r io.Reader) error {
+	cw := httputil.NewChunkedWriter(w)
+	if _, err := io.Copy(cw, r); err != nil {
+		return err
+	}
+	if err := cw.Close(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\r\n")
+	return err
+}
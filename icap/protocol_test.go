@@ -0,0 +1,102 @@
+package icap
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// --- Encapsulated tests ---
+
+func TestParseEncapsulated(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []entity
+	}{
+		{"", nil},
+		{"null-body=0", []entity{{entityNullBody, 0}}},
+		{"req-hdr=0, res-hdr=137, res-body=296", []entity{{entityReqHdr, 0}, {entityResHdr, 137}, {entityResBody, 296}}},
+		{"req-hdr=0,req-body=20", []entity{{entityReqHdr, 0}, {entityReqBody, 20}}},
+	}
+	for _, tt := range tests {
+		got, err := parseEncapsulated(tt.header)
+		if err != nil {
+			t.Errorf("parseEncapsulated(%q): %v", tt.header, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseEncapsulated(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+
+	for _, header := range []string{
+		"req-hdr",
+		"req-hdr=x",
+		"req-hdr=-1",
+		"foo=0",
+		"req-hdr=10, req-body=5",
+		"req-body=0, res-body=0",
+		"req-hdr=0, res-hdr=70000",
+	} {
+		if _, err := parseEncapsulated(header); !errors.Is(err, errMalformed) {
+			t.Errorf("parseEncapsulated(%q) = %v, want errMalformed", header, err)
+		}
+	}
+}
+
+// --- Chunked body tests ---
+
+func TestChunkedReader(t *testing.T) {
+	t.Run("body", func(t *testing.T) {
+		cr := &chunkedReader{br: bufio.NewReader(strings.NewReader("5\r\nhello\r\n7; name=x\r\n, world\r\n0\r\n\r\nnext"))}
+		got, err := io.ReadAll(cr)
+		if err != nil || string(got) != "hello, world" {
+			t.Fatalf("ReadAll = %q, %v", got, err)
+		}
+		if cr.ieof {
+			t.Error("ieof set without the extension")
+		}
+		rest, _ := io.ReadAll(cr.br)
+		if string(rest) != "next" {
+			t.Errorf("read past the body: rest = %q", rest)
+		}
+	})
+
+	t.Run("preview", func(t *testing.T) {
+		cr := &chunkedReader{br: bufio.NewReader(strings.NewReader("4\r\nhell\r\n0\r\n\r\n1\r\no\r\n0\r\n\r\n"))}
+		got, _ := io.ReadAll(cr)
+		if string(got) != "hell" || cr.ieof {
+			t.Fatalf("preview = %q, ieof = %v", got, cr.ieof)
+		}
+		cr.continueBody()
+		got, err := io.ReadAll(cr)
+		if err != nil || string(got) != "o" {
+			t.Errorf("rest = %q, %v", got, err)
+		}
+	})
+
+	t.Run("ieof", func(t *testing.T) {
+		cr := &chunkedReader{br: bufio.NewReader(strings.NewReader("5\r\nhello\r\n0; ieof\r\n\r\n"))}
+		got, err := io.ReadAll(cr)
+		if err != nil || string(got) != "hello" || !cr.ieof {
+			t.Errorf("ReadAll = %q, %v, ieof = %v", got, err, cr.ieof)
+		}
+	})
+
+	for name, body := range map[string]string{
+		"size":      "x\r\nhello\r\n0\r\n\r\n",
+		"crlf":      "5\r\nhelloX\r\n0\r\n\r\n",
+		"line":      strings.Repeat("1", maxChunkLine+1) + "\r\n",
+		"truncated": "5\r\nhel",
+	} {
+		t.Run(name, func(t *testing.T) {
+			cr := &chunkedReader{br: bufio.NewReaderSize(strings.NewReader(body), 2*maxChunkLine)}
+			if _, err := io.ReadAll(cr); err == nil || errors.Is(err, io.EOF) {
+				t.Errorf("ReadAll error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,664 @@
+// Package icap serves ClamAV scanning over ICAP (RFC 3507), for HTTP proxies such as
+// Squid and for storage appliances that offload virus scanning.
+//
+// A Server handles OPTIONS, REQMOD and RESPMOD on any service path. The encapsulated
+// request or response body is streamed to a clamav.Scanner as it arrives. Clean
+// content gets 204 No Content, or is sent back unmodified to clients that do not
+// accept 204; infected content is replaced by an HTML block page naming the
+// signature, which is also reported in the X-Infection-Found header.
+//
+// # Quick Start
+//
+//	client, err := clamav.NewClient("http://localhost:6000")
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	defer client.Close()
+//
+//	srv := icap.NewServer(client, icap.WithISTag("db-27432"))
+//	log.Fatal(srv.ListenAndServe(":1344"))
+//
+// and in squid.conf:
+//
+//	icap_enable on
+//	icap_preview_enable on
+//	icap_service clamav_req reqmod_precache icap://127.0.0.1:1344/avscan
+//	icap_service clamav_resp respmod_precache icap://127.0.0.1:1344/avscan
+//	adaptation_access clamav_req allow all
+//	adaptation_access clamav_resp allow all
+//
+// Previews are supported: when a preview does not contain the whole body, the server
+// asks for the rest with 100 Continue once the scanner has read the preview.
+package icap
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	clamav "github.com/DevHatRo/clamav-api-sdk-go"
+)
+
+// DefaultAddress is the address ListenAndServe listens on when given none; 1344 is
+// the ICAP port.
+const DefaultAddress = ":1344"
+
+const (
+	service       = "clamav-api-sdk-go ICAP"
+	optionsTTL    = 3600
+	shutdownPoll  = 10 * time.Millisecond
+	noFilename    = "body"
+	continueReply = "ICAP/1.0 100 Continue\r\n\r\n"
+)
+
+// ErrServerClosed is returned by Serve and ListenAndServe after Shutdown or Close.
+var ErrServerClosed = errors.New("icap: server closed")
+
+var statusText = map[int]string{
+	100: "Continue",
+	200: "OK",
+	204: "No Content",
+	400: "Bad Request",
+	500: "Server Error",
+	501: "Method Not Implemented",
+	505: "ICAP Version Not Supported",
+}
+
+var defaultBlockPage = template.Must(template.New("block").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Content blocked</title></head>
+<body>
+<h1>Content blocked</h1>
+<p>{{if .Filename}}<b>{{.Filename}}</b>{{else}}The content{{end}}{{if .URL}} from {{.URL}}{{end}} contains a virus: <b>{{.Signature}}</b>.</p>
+</body>
+</html>
+`))
+
+// Infection describes blocked content. It is the data of the block page template.
+type Infection struct {
+	// Method is the ICAP method, "REQMOD" or "RESPMOD".
+	Method string
+	// URL is the URL of the encapsulated HTTP request, if any.
+	URL string
+	// Filename is the name the content was scanned as.
+	Filename string
+	// Signature is the name of the virus found.
+	Signature string
+}
+
+// Server is an ICAP server. It is safe for concurrent use.
+type Server struct {
+	scanner         clamav.Scanner
+	istag           string
+	preview         int
+	blockStatus     int
+	blockPage       *template.Template
+	failOpen        bool
+	idleTimeout     time.Duration
+	memoryThreshold int64
+	tempDir         string
+
+	ctx    context.Context // canceled by Close, to abort scans in flight
+	cancel context.CancelFunc
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[*conn]bool // true while the connection waits for a request
+	closed    bool
+}
+
+// NewServer returns a server that scans with s. The scanner is not closed by
+// Shutdown or Close.
+func NewServer(s clamav.Scanner, opts ...Option) *Server {
+	srv := &Server{
+		scanner:         s,
+		istag:           defaultISTag,
+		preview:         defaultPreview,
+		blockStatus:     defaultBlockStatus,
+		blockPage:       defaultBlockPage,
+		idleTimeout:     defaultIdleTimeout,
+		memoryThreshold: defaultMemoryThreshold,
+		listeners:       map[net.Listener]struct{}{},
+		conns:           map[*conn]bool{},
+	}
+	for _, opt := range opts {
+		opt(srv)
+	}
+	srv.ctx, srv.cancel = context.WithCancel(context.Background())
+	return srv
+}
+
+// ListenAndServe listens on the TCP address addr, or DefaultAddress if empty, and
+// calls Serve.
+func (s *Server) ListenAndServe(addr string) error {
+	if addr == "" {
+		addr = DefaultAddress
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ln)
+}
+
+// Serve accepts connections on ln and serves them until Shutdown or Close is called,
+// when it returns ErrServerClosed. ln is closed on return.
+func (s *Server) Serve(ln net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		_ = ln.Close()
+		return ErrServerClosed
+	}
+	s.listeners[ln] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, ln)
+		s.mu.Unlock()
+		_ = ln.Close()
+	}()
+
+	for {
+		nc, err := ln.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			return err
+		}
+		c := &conn{nc: nc, br: bufio.NewReader(nc), bw: bufio.NewWriter(nc)}
+		if !s.setIdle(c, true) {
+			_ = nc.Close()
+			return ErrServerClosed
+		}
+		go s.serveConn(c)
+	}
+}
+
+// Shutdown stops the server gracefully: it closes the listeners and idle connections,
+// and waits for requests in flight to finish until ctx is done, when the remaining
+// connections are closed.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	err := s.closeListeners()
+	s.mu.Unlock()
+
+	ticker := time.NewTicker(shutdownPoll)
+	defer ticker.Stop()
+	for {
+		s.mu.Lock()
+		for c, idle := range s.conns {
+			if idle {
+				_ = c.nc.Close()
+			}
+		}
+		n := len(s.conns)
+		s.mu.Unlock()
+		if n == 0 {
+			s.cancel()
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			_ = s.Close()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close stops the server immediately, closing the listeners and every connection and
+// aborting scans in flight.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	s.cancel()
+	err := s.closeListeners()
+	for c := range s.conns {
+		_ = c.nc.Close()
+	}
+	return err
+}
+
+// closeListeners closes the listeners; s.mu must be held.
+func (s *Server) closeListeners() error {
+	var first error
+	for ln := range s.listeners {
+		if err := ln.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// setIdle tracks whether c waits for a request. It returns false if the server is
+// shutting down and c should not wait for another request.
+func (s *Server) setIdle(c *conn, idle bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed && idle {
+		return false
+	}
+	s.conns[c] = idle
+	return true
+}
+
+// conn is a client connection.
+type conn struct {
+	nc net.Conn
+	br *bufio.Reader
+	bw *bufio.Writer
+	// closing is set when the connection is closed after the current response,
+	// e.g. because the request body was not read to its end.
+	closing bool
+}
+
+// writeContinue asks the client for the rest of the body after a preview.
+func (c *conn) writeContinue() error {
+	if _, err := c.bw.WriteString(continueReply); err != nil {
+		return err
+	}
+	return c.bw.Flush()
+}
+
+// serveConn serves the requests on c until it is closed or fails.
+func (s *Server) serveConn(c *conn) {
+	defer func() {
+		_ = c.nc.Close()
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+	}()
+
+	for !c.closing {
+		if !s.setIdle(c, true) {
+			return
+		}
+		_ = c.nc.SetReadDeadline(time.Now().Add(s.idleTimeout))
+		if _, err := c.br.Peek(1); err != nil {
+			return
+		}
+		if !s.setIdle(c, false) {
+			return
+		}
+
+		req, err := readRequest(c.br)
+		if err != nil {
+			c.closing = true
+			switch {
+			case errors.Is(err, errVersion):
+				s.write(c, &response{status: 505})
+			case errors.Is(err, errMalformed):
+				s.write(c, &response{status: 400})
+			}
+			_ = c.bw.Flush()
+			return
+		}
+		_ = c.nc.SetReadDeadline(time.Time{})
+
+		if strings.EqualFold(req.header.Get("Connection"), "close") {
+			c.closing = true
+		}
+		switch req.method {
+		case "OPTIONS":
+			s.options(c, req)
+		case "REQMOD", "RESPMOD":
+			s.modify(c, req)
+		default:
+			c.closing = true
+			s.write(c, &response{status: 501})
+		}
+		if err := c.bw.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// options describes the service.
+func (s *Server) options(c *conn, req *request) {
+	if req.body != "" {
+		if _, err := io.Copy(io.Discard, &chunkedReader{br: c.br}); err != nil {
+			c.closing = true
+		}
+	}
+	h := http.Header{}
+	set(h, "Methods", "REQMOD, RESPMOD")
+	set(h, "Service", service)
+	set(h, "Options-TTL", strconv.Itoa(optionsTTL))
+	set(h, "Allow", "204")
+	if s.preview > 0 {
+		set(h, "Preview", strconv.Itoa(s.preview))
+		set(h, "Transfer-Preview", "*")
+	}
+	s.write(c, &response{status: 200, header: h})
+}
+
+// modify scans the body of a REQMOD or RESPMOD request.
+func (s *Server) modify(c *conn, req *request) {
+	bodyName := entityReqBody
+	if req.method == "RESPMOD" {
+		bodyName = entityResBody
+	}
+	if req.body != "" && req.body != bodyName {
+		c.closing = true
+		s.write(c, &response{status: 400})
+		return
+	}
+	if req.body == "" {
+		s.clean(c, req, nil, req.allow204)
+		return
+	}
+
+	b := &bodyReader{c: c, cr: &chunkedReader{br: c.br}, preview: req.preview >= 0}
+	var r io.Reader = b
+	var sp *spool
+	if !req.allow204 {
+		// Clean content is sent back, so keep a copy.
+		sp = &spool{threshold: s.memoryThreshold, dir: s.tempDir}
+		defer func() { _ = sp.Close() }()
+		r = io.TeeReader(b, sp)
+	}
+
+	info := describe(req)
+	result, err := s.scan(r, info.Filename)
+	if err == nil {
+		err = result.Err()
+	}
+	if b.err != nil {
+		s.bodyFailed(c, b.err)
+		return
+	}
+
+	switch {
+	case err == nil && result.IsInfected():
+		// The rest of the body is not needed, but the client may still be sending it.
+		c.closing = c.closing || !b.done()
+		info.Signature = result.Message
+		s.block(c, info)
+	case err != nil && !s.failOpen:
+		c.closing = c.closing || !b.done()
+		s.write(c, &response{status: 500})
+	default:
+		// Read what the scanner left, for the client to be waiting for the response
+		// and the spool to hold the whole body.
+		if _, err := io.Copy(io.Discard, r); err != nil {
+			s.bodyFailed(c, err)
+			return
+		}
+		s.clean(c, req, sp, req.allow204 || (b.preview && !b.continued))
+	}
+}
+
+// scan scans the body read from r. Empty bodies are clean.
+func (s *Server) scan(r io.Reader, filename string) (*clamav.ScanResult, error) {
+	br := bufio.NewReader(r)
+	if _, err := br.Peek(1); err != nil {
+		if errors.Is(err, io.EOF) {
+			return &clamav.ScanResult{Status: clamav.StatusOK, Filename: filename}, nil
+		}
+		return nil, err
+	}
+	return s.scanner.StreamScanReader(s.ctx, br, filename, clamav.UnknownSize)
+}
+
+// bodyFailed responds to a request whose body could not be read, and closes the
+// connection.
+func (s *Server) bodyFailed(c *conn, err error) {
+	c.closing = true
+	switch {
+	case errors.Is(err, errMalformed):
+		s.write(c, &response{status: 400})
+	case !isNetError(err):
+		s.write(c, &response{status: 500})
+	}
+}
+
+// clean responds to clean content with 204, or else by sending the encapsulated
+// message back with the body kept in sp.
+func (s *Server) clean(c *conn, req *request, sp *spool, noContent bool) {
+	if noContent {
+		s.write(c, &response{status: 204})
+		return
+	}
+
+	resp := &response{status: 200}
+	if req.method == "REQMOD" {
+		resp.reqHdr, resp.bodyName = req.reqHdr, entityReqBody
+	} else {
+		resp.resHdr, resp.bodyName = req.resHdr, entityResBody
+	}
+	if sp != nil {
+		body, err := sp.reader()
+		if err != nil {
+			c.closing = true
+			s.write(c, &response{status: 500})
+			return
+		}
+		resp.body = body
+	}
+	s.write(c, resp)
+}
+
+// block replaces infected content with the block page.
+func (s *Server) block(c *conn, info Infection) {
+	var page bytes.Buffer
+	if err := s.blockPage.Execute(&page, info); err != nil {
+		s.write(c, &response{status: 500})
+		return
+	}
+	hdr := fmt.Sprintf("HTTP/1.1 %d %s\r\n"+
+		"Content-Type: text/html; charset=utf-8\r\n"+
+		"Content-Length: %d\r\n"+
+		"Cache-Control: no-store\r\n\r\n",
+		s.blockStatus, http.StatusText(s.blockStatus), page.Len())
+
+	h := http.Header{}
+	set(h, "X-Infection-Found", fmt.Sprintf("Type=0; Resolution=2; Threat=%s;", info.Signature))
+	set(h, "X-Virus-ID", info.Signature)
+	s.write(c, &response{status: 200, header: h, resHdr: []byte(hdr), body: &page, bodyName: entityResBody})
+}
+
+// response is an ICAP response.
+type response struct {
+	status int
+	header http.Header
+	// reqHdr and resHdr are the encapsulated HTTP headers, or nil.
+	reqHdr []byte
+	resHdr []byte
+	// body is the encapsulated body, or nil, and bodyName its entity name.
+	body     io.Reader
+	bodyName string
+}
+
+// write writes resp, computing its Encapsulated header. Errors are left for the
+// final flush of the connection to report.
+func (s *Server) write(c *conn, resp *response) {
+	h := resp.header
+	if h == nil {
+		h = http.Header{}
+	}
+	set(h, "ISTag", s.istag)
+	set(h, "Date", time.Now().UTC().Format(http.TimeFormat))
+	if s.isClosed() {
+		c.closing = true
+	}
+	if c.closing {
+		set(h, "Connection", "close")
+	}
+
+	var enc []string
+	off := 0
+	if resp.reqHdr != nil {
+		enc = append(enc, entityReqHdr+"=0")
+		off += len(resp.reqHdr)
+	}
+	if resp.resHdr != nil {
+		enc = append(enc, entityResHdr+"="+strconv.Itoa(off))
+		off += len(resp.resHdr)
+	}
+	if resp.body != nil {
+		enc = append(enc, resp.bodyName+"="+strconv.Itoa(off))
+	} else {
+		enc = append(enc, entityNullBody+"="+strconv.Itoa(off))
+	}
+	set(h, "Encapsulated", strings.Join(enc, ", "))
+
+	fmt.Fprintf(c.bw, "ICAP/1.0 %d %s\r\n", resp.status, statusText[resp.status])
+	_ = h.Write(c.bw)
+	_, _ = c.bw.WriteString("\r\n")
+	_, _ = c.bw.Write(resp.reqHdr)
+	_, _ = c.bw.Write(resp.resHdr)
+	if resp.body != nil {
+		if err := writeChunked(c.bw, resp.body); err != nil {
+			c.closing = true
+		}
+	}
+}
+
+// set sets an ICAP header without canonicalizing its name, which would turn
+// "ISTag" into "Istag".
+func set(h http.Header, key, value string) {
+	h[key] = []string{value}
+}
+
+// describe returns what is known of the content of req before it is scanned.
+func describe(req *request) Infection {
+	info := Infection{Method: req.method}
+	var header http.Header
+	urlPath := ""
+	if hr := req.httpRequest(); hr != nil {
+		u := *hr.URL
+		if u.Host == "" && hr.Host != "" {
+			u.Scheme, u.Host = "http", hr.Host
+		}
+		info.URL = u.String()
+		header, urlPath = hr.Header, hr.URL.Path
+	}
+	if req.method == "RESPMOD" {
+		if resp := req.httpResponse(); resp != nil {
+			header = resp.Header
+		}
+	}
+	info.Filename = filename(header, urlPath)
+	return info
+}
+
+// filename returns the file name from a Content-Disposition header, falling back to
+// the last element of the URL path.
+func filename(header http.Header, urlPath string) string {
+	if _, params, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		return path.Base(params["filename"])
+	}
+	if base := path.Base(urlPath); base != "/" && base != "." {
+		return base
+	}
+	return noFilename
+}
+
+func isNetError(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed)
+}
+
+// bodyReader reads an encapsulated body. When a preview does not contain the whole
+// body, it asks the client for the rest with 100 Continue at the end of the preview.
+type bodyReader struct {
+	c         *conn
+	cr        *chunkedReader
+	preview   bool  // the body starts with a preview
+	continued bool  // 100 Continue was sent
+	err       error // the first error reading the body
+}
+
+func (b *bodyReader) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	n, err := b.cr.Read(p)
+	if errors.Is(err, io.EOF) && b.preview && !b.continued && !b.cr.ieof {
+		b.continued = true
+		if err = b.c.writeContinue(); err == nil {
+			b.cr.continueBody()
+			n, err = b.cr.Read(p)
+		}
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		b.err = err
+	}
+	return n, err
+}
+
+// done reports whether the client waits for the response: the body, or the preview
+// that was not continued, was read to its end.
+func (b *bodyReader) done() bool {
+	return b.cr.eof && b.err == nil
+}
+
+// spool keeps a copy of a body in memory or, past the memory threshold, in a
+// temporary file.
+type spool struct {
+	threshold int64
+	dir       string
+	buf       bytes.Buffer
+	file      *os.File
+}
+
+func (s *spool) Write(p []byte) (int, error) {
+	if s.file == nil && int64(s.buf.Len()+len(p)) > s.threshold {
+		f, err := os.CreateTemp(s.dir, "clamav-icap-*")
+		if err != nil {
+			return 0, err
+		}
+		s.file = f
+		if _, err := s.buf.WriteTo(f); err != nil {
+			return 0, err
+		}
+	}
+	if s.file != nil {
+		return s.file.Write(p)
+	}
+	return s.buf.Write(p)
+}
+
+// reader returns the spooled body.
+func (s *spool) reader() (io.Reader, error) {
+	if s.file == nil {
+		return &s.buf, nil
+	}
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return s.file, nil
+}
+
+// Close removes the temporary file, if any.
+func (s *spool) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	_ = s.file.Close()
+	return os.Remove(s.file.Name())
+}
@@ -0,0 +1,576 @@
+package icap
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DevHatRo/clamav-api-sdk-go/clamavtest"
+)
+
+const (
+	getRequest  = "GET http://example.com/files/report.pdf HTTP/1.1\r\nHost: example.com\r\n\r\n"
+	postRequest = "POST /upload/doc.txt HTTP/1.1\r\nHost: example.com\r\nContent-Type: application/octet-stream\r\n\r\n"
+	okResponse  = "HTTP/1.1 200 OK\r\nContent-Type: application/octet-stream\r\n\r\n"
+)
+
+// icapResponse is a response read by the test client.
+type icapResponse struct {
+	status  int
+	header  textproto.MIMEHeader
+	reqHdr  string
+	resHdr  string
+	body    string
+	hasBody bool
+}
+
+// client is a raw ICAP client.
+type client struct {
+	t  *testing.T
+	nc net.Conn
+	br *bufio.Reader
+}
+
+// newTestServer starts a server scanning with a clamavtest fake, and returns its address.
+func newTestServer(t *testing.T, opts ...Option) (*Server, *clamavtest.Server, string) {
+	t.Helper()
+	fake := clamavtest.NewServer()
+	t.Cleanup(fake.Close)
+	scanner := fake.Client()
+	t.Cleanup(func() { _ = scanner.Close() })
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(scanner, opts...)
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ln) }()
+	t.Cleanup(func() {
+		_ = srv.Close()
+		if err := <-done; !errors.Is(err, ErrServerClosed) {
+			t.Errorf("Serve = %v, want ErrServerClosed", err)
+		}
+	})
+	return srv, fake, ln.Addr().String()
+}
+
+func dial(t *testing.T, addr string) *client {
+	t.Helper()
+	nc, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = nc.Close() })
+	_ = nc.SetDeadline(time.Now().Add(10 * time.Second))
+	return &client{t: t, nc: nc, br: bufio.NewReader(nc)}
+}
+
+func (c *client) send(s string) {
+	c.t.Helper()
+	if _, err := io.WriteString(c.nc, s); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// read reads a response and its encapsulated parts.
+func (c *client) read() *icapResponse {
+	c.t.Helper()
+	tp := textproto.NewReader(c.br)
+	line, err := tp.ReadLine()
+	if err != nil {
+		c.t.Fatalf("reading status line: %v", err)
+	}
+	fields := strings.SplitN(line, " ", 3)
+	if len(fields) < 2 || fields[0] != "ICAP/1.0" {
+		c.t.Fatalf("status line = %q", line)
+	}
+	resp := &icapResponse{}
+	resp.status, _ = strconv.Atoi(fields[1])
+	if resp.status == 100 {
+		if _, err := tp.ReadLine(); err != nil {
+			c.t.Fatal(err)
+		}
+		return resp
+	}
+	if resp.header, err = tp.ReadMIMEHeader(); err != nil {
+		c.t.Fatalf("reading header: %v", err)
+	}
+
+	entities, err := parseEncapsulated(resp.header.Get("Encapsulated"))
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	for i, e := range entities {
+		switch e.name {
+		case entityReqHdr, entityResHdr:
+			buf := make([]byte, entities[i+1].offset-e.offset)
+			if _, err := io.ReadFull(c.br, buf); err != nil {
+				c.t.Fatal(err)
+			}
+			if e.name == entityReqHdr {
+				resp.reqHdr = string(buf)
+			} else {
+				resp.resHdr = string(buf)
+			}
+		case entityReqBody, entityResBody:
+			body, err := io.ReadAll(&chunkedReader{br: c.br})
+			if err != nil {
+				c.t.Fatalf("reading body: %v", err)
+			}
+			resp.body, resp.hasBody = string(body), true
+		}
+	}
+	return resp
+}
+
+func (c *client) roundTrip(req string) *icapResponse {
+	c.t.Helper()
+	c.send(req)
+	return c.read()
+}
+
+// encapsulate builds a REQMOD or RESPMOD request. header holds extra ICAP header lines.
+// body is sent in one chunk unless empty.
+func encapsulate(method, header, reqHdr, resHdr, body string) string {
+	var enc []string
+	off := 0
+	if reqHdr != "" {
+		enc = append(enc, "req-hdr=0")
+		off += len(reqHdr)
+	}
+	if resHdr != "" {
+		enc = append(enc, fmt.Sprintf("res-hdr=%d", off))
+		off += len(resHdr)
+	}
+	bodyName := "req-body"
+	if method == "RESPMOD" {
+		bodyName = "res-body"
+	}
+	if body == "" {
+		enc = append(enc, fmt.Sprintf("null-body=%d", off))
+	} else {
+		enc = append(enc, fmt.Sprintf("%s=%d", bodyName, off))
+	}
+
+	s := method + " icap://127.0.0.1/avscan ICAP/1.0\r\nHost: 127.0.0.1\r\n" + header +
+		"Encapsulated: " + strings.Join(enc, ", ") + "\r\n\r\n" + reqHdr + resHdr
+	if body != "" {
+		s += chunk(body) + "0\r\n\r\n"
+	}
+	return s
+}
+
+func chunk(s string) string {
+	return fmt.Sprintf("%x\r\n%s\r\n", len(s), s)
+}
+
+// --- OPTIONS tests ---
+
+func TestOptions(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		_, _, addr := newTestServer(t)
+		c := dial(t, addr)
+		resp := c.roundTrip("OPTIONS icap://127.0.0.1/avscan ICAP/1.0\r\nHost: 127.0.0.1\r\nEncapsulated: null-body=0\r\n\r\n")
+		if resp.status != 200 {
+			t.Fatalf("status = %d, want 200", resp.status)
+		}
+		want := map[string]string{
+			"Methods":          "REQMOD, RESPMOD",
+			"Istag":            defaultISTag,
+			"Allow":            "204",
+			"Preview":          "1024",
+			"Transfer-Preview": "*",
+			"Options-Ttl":      "3600",
+			"Encapsulated":     "null-body=0",
+		}
+		for k, v := range want {
+			if got := resp.header.Get(k); got != v {
+				t.Errorf("%s = %q, want %q", k, got, v)
+			}
+		}
+	})
+
+	t.Run("options", func(t *testing.T) {
+		_, _, addr := newTestServer(t, WithISTag("db-1"), WithPreview(0))
+		c := dial(t, addr)
+		resp := c.roundTrip("OPTIONS icap://127.0.0.1/avscan ICAP/1.0\r\nEncapsulated: null-body=0\r\n\r\n")
+		if got := resp.header.Get("ISTag"); got != `"db-1"` {
+			t.Errorf("ISTag = %q, want quoted", got)
+		}
+		if got := resp.header.Get("Preview"); got != "" {
+			t.Errorf("Preview = %q, want none", got)
+		}
+	})
+}
+
+// --- REQMOD tests ---
+
+func TestReqmod(t *testing.T) {
+	_, fake, addr := newTestServer(t)
+
+	t.Run("clean with allow 204", func(t *testing.T) {
+		c := dial(t, addr)
+		resp := c.roundTrip(encapsulate("REQMOD", "Allow: 204\r\n", postRequest, "", "hello"))
+		if resp.status != 204 {
+			t.Fatalf("status = %d, want 204", resp.status)
+		}
+		reqs := fake.Requests()
+		if got := string(reqs[len(reqs)-1].Body); got != "hello" {
+			t.Errorf("scanned %q, want %q", got, "hello")
+		}
+	})
+
+	t.Run("clean is echoed without allow 204", func(t *testing.T) {
+		c := dial(t, addr)
+		resp := c.roundTrip(encapsulate("REQMOD", "", postRequest, "", "hello"))
+		if resp.status != 200 {
+			t.Fatalf("status = %d, want 200", resp.status)
+		}
+		if resp.reqHdr != postRequest || resp.body != "hello" {
+			t.Errorf("echoed %q %q", resp.reqHdr, resp.body)
+		}
+	})
+
+	t.Run("no body", func(t *testing.T) {
+		n := len(fake.Requests())
+		c := dial(t, addr)
+		resp := c.roundTrip(encapsulate("REQMOD", "Allow: 204\r\n", getRequest, "", ""))
+		if resp.status != 204 {
+			t.Fatalf("status = %d, want 204", resp.status)
+		}
+		resp = c.roundTrip(encapsulate("REQMOD", "", getRequest, "", ""))
+		if resp.status != 200 || resp.reqHdr != getRequest || resp.hasBody {
+			t.Errorf("echo = %d %q body=%v", resp.status, resp.reqHdr, resp.hasBody)
+		}
+		if len(fake.Requests()) != n {
+			t.Error("requests without a body should not be scanned")
+		}
+	})
+
+	t.Run("infected", func(t *testing.T) {
+		c := dial(t, addr)
+		resp := c.roundTrip(encapsulate("REQMOD", "Allow: 204\r\n", postRequest, "", clamavtest.EICAR))
+		if resp.status != 200 {
+			t.Fatalf("status = %d, want 200", resp.status)
+		}
+		if got := resp.header.Get("X-Infection-Found"); !strings.Contains(got, "Threat="+clamavtest.EICARSignature+";") {
+			t.Errorf("X-Infection-Found = %q", got)
+		}
+		if !strings.HasPrefix(resp.resHdr, "HTTP/1.1 403 Forbidden\r\n") {
+			t.Errorf("res-hdr = %q", resp.resHdr)
+		}
+		if !strings.Contains(resp.resHdr, "Content-Length: "+strconv.Itoa(len(resp.body))) {
+			t.Errorf("res-hdr = %q, want Content-Length %d", resp.resHdr, len(resp.body))
+		}
+		for _, s := range []string{clamavtest.EICARSignature, "doc.txt", "http://example.com/upload/doc.txt"} {
+			if !strings.Contains(resp.body, s) {
+				t.Errorf("block page does not contain %q:\n%s", s, resp.body)
+			}
+		}
+	})
+}
+
+// --- RESPMOD tests ---
+
+func TestRespmod(t *testing.T) {
+	_, _, addr := newTestServer(t)
+
+	t.Run("clean", func(t *testing.T) {
+		c := dial(t, addr)
+		resp := c.roundTrip(encapsulate("RESPMOD", "Allow: 204\r\n", getRequest, okResponse, "hello"))
+		if resp.status != 204 {
+			t.Fatalf("status = %d, want 204", resp.status)
+		}
+	})
+
+	t.Run("clean is echoed without allow 204", func(t *testing.T) {
+		c := dial(t, addr)
+		resp := c.roundTrip(encapsulate("RESPMOD", "", getRequest, okResponse, "hello"))
+		if resp.status != 200 || resp.resHdr != okResponse || resp.body != "hello" || resp.reqHdr != "" {
+			t.Errorf("echo = %d %q %q %q", resp.status, resp.reqHdr, resp.resHdr, resp.body)
+		}
+	})
+
+	t.Run("infected", func(t *testing.T) {
+		res := "HTTP/1.1 200 OK\r\nContent-Disposition: attachment; filename=\"eicar.com\"\r\n\r\n"
+		c := dial(t, addr)
+		resp := c.roundTrip(encapsulate("RESPMOD", "", getRequest, res, clamavtest.EICAR))
+		if got := resp.header.Get("X-Virus-ID"); got != clamavtest.EICARSignature {
+			t.Errorf("X-Virus-ID = %q", got)
+		}
+		if !strings.Contains(resp.body, "eicar.com") {
+			t.Errorf("block page does not name the file:\n%s", resp.body)
+		}
+	})
+
+	t.Run("block page", func(t *testing.T) {
+		tmpl := template.Must(template.New("").Parse("{{.Method}} {{.Filename}} {{.Signature}}"))
+		_, _, addr := newTestServer(t, WithBlockPage(451, tmpl))
+		c := dial(t, addr)
+		resp := c.roundTrip(encapsulate("RESPMOD", "", getRequest, okResponse, clamavtest.EICAR))
+		if !strings.HasPrefix(resp.resHdr, "HTTP/1.1 451 ") {
+			t.Errorf("res-hdr = %q", resp.resHdr)
+		}
+		if want := "RESPMOD report.pdf " + clamavtest.EICARSignature; resp.body != want {
+			t.Errorf("body = %q, want %q", resp.body, want)
+		}
+	})
+
+	t.Run("large body is spooled", func(t *testing.T) {
+		_, _, addr := newTestServer(t, WithMemoryThreshold(16), WithTempDir(t.TempDir()))
+		body := strings.Repeat("0123456789", 100)
+		c := dial(t, addr)
+		resp := c.roundTrip(encapsulate("RESPMOD", "", "", okResponse, body))
+		if resp.status != 200 || resp.body != body {
+			t.Errorf("echo = %d, %d bytes", resp.status, len(resp.body))
+		}
+	})
+}
+
+// --- Preview tests ---
+
+func TestPreview(t *testing.T) {
+	_, fake, addr := newTestServer(t)
+	head := func(extra string) string {
+		return "RESPMOD icap://127.0.0.1/avscan ICAP/1.0\r\n" + extra +
+			fmt.Sprintf("Encapsulated: res-hdr=0, res-body=%d\r\n\r\n", len(okResponse)) + okResponse
+	}
+
+	t.Run("whole body in preview", func(t *testing.T) {
+		c := dial(t, addr)
+		// 204 is allowed in response to a preview even without Allow: 204.
+		resp := c.roundTrip(head("Preview: 10\r\n") + chunk("hello") + "0; ieof\r\n\r\n")
+		if resp.status != 204 {
+			t.Fatalf("status = %d, want 204", resp.status)
+		}
+	})
+
+	t.Run("continue", func(t *testing.T) {
+		c := dial(t, addr)
+		c.send(head("Preview: 4\r\nAllow: 204\r\n") + chunk("hell") + "0\r\n\r\n")
+		if resp := c.read(); resp.status != 100 {
+			t.Fatalf("status = %d, want 100", resp.status)
+		}
+		resp := c.roundTrip(chunk("o, ") + chunk("world") + "0\r\n\r\n")
+		if resp.status != 204 {
+			t.Fatalf("status = %d, want 204", resp.status)
+		}
+		reqs := fake.Requests()
+		if got := string(reqs[len(reqs)-1].Body); got != "hello, world" {
+			t.Errorf("scanned %q, want %q", got, "hello, world")
+		}
+	})
+
+	t.Run("continue and echo", func(t *testing.T) {
+		c := dial(t, addr)
+		c.send(head("Preview: 4\r\n") + chunk("hell") + "0\r\n\r\n")
+		if resp := c.read(); resp.status != 100 {
+			t.Fatalf("status = %d, want 100", resp.status)
+		}
+		resp := c.roundTrip(chunk("o") + "0\r\n\r\n")
+		if resp.status != 200 || resp.body != "hello" {
+			t.Errorf("echo = %d %q", resp.status, resp.body)
+		}
+	})
+
+	t.Run("infected in preview", func(t *testing.T) {
+		c := dial(t, addr)
+		resp := c.roundTrip(head("Preview: 100\r\n") + chunk(clamavtest.EICAR) + "0; ieof\r\n\r\n")
+		if resp.status != 200 || resp.header.Get("X-Infection-Found") == "" {
+			t.Errorf("status = %d, X-Infection-Found = %q", resp.status, resp.header.Get("X-Infection-Found"))
+		}
+	})
+
+	t.Run("infected after preview", func(t *testing.T) {
+		c := dial(t, addr)
+		c.send(head("Preview: 4\r\n") + chunk(clamavtest.EICAR[:4]) + "0\r\n\r\n")
+		if resp := c.read(); resp.status != 100 {
+			t.Fatalf("status = %d, want 100", resp.status)
+		}
+		resp := c.roundTrip(chunk(clamavtest.EICAR[4:]) + "0\r\n\r\n")
+		if resp.status != 200 || resp.header.Get("X-Virus-ID") != clamavtest.EICARSignature {
+			t.Errorf("status = %d, X-Virus-ID = %q", resp.status, resp.header.Get("X-Virus-ID"))
+		}
+	})
+
+	t.Run("empty preview", func(t *testing.T) {
+		c := dial(t, addr)
+		c.send(head("Preview: 0\r\nAllow: 204\r\n") + "0\r\n\r\n")
+		if resp := c.read(); resp.status != 100 {
+			t.Fatalf("status = %d, want 100", resp.status)
+		}
+		if resp := c.roundTrip(chunk("hello") + "0\r\n\r\n"); resp.status != 204 {
+			t.Errorf("status = %d, want 204", resp.status)
+		}
+	})
+}
+
+// --- Connection tests ---
+
+func TestKeepAlive(t *testing.T) {
+	_, _, addr := newTestServer(t)
+	c := dial(t, addr)
+	for i := 0; i < 3; i++ {
+		if resp := c.roundTrip(encapsulate("REQMOD", "Allow: 204\r\n", postRequest, "", "hello")); resp.status != 204 {
+			t.Fatalf("request %d: status = %d, want 204", i, resp.status)
+		}
+		resp := c.roundTrip(encapsulate("RESPMOD", "", getRequest, okResponse, clamavtest.EICAR))
+		if resp.status != 200 || resp.header.Get("Connection") == "close" {
+			t.Fatalf("request %d: status = %d, Connection = %q", i, resp.status, resp.header.Get("Connection"))
+		}
+	}
+
+	t.Run("connection close", func(t *testing.T) {
+		c := dial(t, addr)
+		resp := c.roundTrip(encapsulate("REQMOD", "Allow: 204\r\nConnection: close\r\n", getRequest, "", ""))
+		if resp.header.Get("Connection") != "close" {
+			t.Errorf("Connection = %q, want close", resp.header.Get("Connection"))
+		}
+		if _, err := c.br.ReadByte(); err != io.EOF {
+			t.Errorf("read after close = %v, want EOF", err)
+		}
+	})
+
+	t.Run("idle timeout", func(t *testing.T) {
+		_, _, addr := newTestServer(t, WithIdleTimeout(50*time.Millisecond))
+		c := dial(t, addr)
+		if _, err := c.br.ReadByte(); err != io.EOF {
+			t.Errorf("read = %v, want EOF", err)
+		}
+	})
+}
+
+// --- Error tests ---
+
+func TestErrors(t *testing.T) {
+	_, fake, addr := newTestServer(t)
+
+	tests := []struct {
+		name   string
+		req    string
+		status int
+	}{
+		{"unknown method", "PUT icap://127.0.0.1/avscan ICAP/1.0\r\nEncapsulated: null-body=0\r\n\r\n", 501},
+		{"version", "OPTIONS icap://127.0.0.1/avscan ICAP/2.0\r\n\r\n", 505},
+		{"request line", "OPTIONS\r\n\r\n", 400},
+		{"encapsulated", "REQMOD icap://127.0.0.1/avscan ICAP/1.0\r\nEncapsulated: req-body=0, req-hdr=5\r\n\r\n", 400},
+		{"wrong body", "REQMOD" + strings.TrimPrefix(encapsulate("RESPMOD", "", "", okResponse, "x"), "RESPMOD"), 400},
+		{"chunk", strings.Replace(encapsulate("REQMOD", "", postRequest, "", "hello"), "\r\n5\r\nhello", "\r\nzz\r\nhello", 1), 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := dial(t, addr)
+			resp := c.roundTrip(tt.req)
+			if resp.status != tt.status {
+				t.Errorf("status = %d, want %d", resp.status, tt.status)
+			}
+			if resp.header.Get("Connection") != "close" {
+				t.Errorf("Connection = %q, want close", resp.header.Get("Connection"))
+			}
+		})
+	}
+
+	t.Run("scan error", func(t *testing.T) {
+		fake.FailNext(clamavtest.Failure{StatusCode: 502})
+		c := dial(t, addr)
+		resp := c.roundTrip(encapsulate("REQMOD", "Allow: 204\r\n", postRequest, "", "hello"))
+		if resp.status != 500 {
+			t.Fatalf("status = %d, want 500", resp.status)
+		}
+		// The body was read, so the connection can be reused.
+		if resp := c.roundTrip(encapsulate("REQMOD", "Allow: 204\r\n", postRequest, "", "hello")); resp.status != 204 {
+			t.Errorf("next status = %d, want 204", resp.status)
+		}
+	})
+
+	t.Run("fail open", func(t *testing.T) {
+		_, fake, addr := newTestServer(t, WithFailOpen(true))
+		fake.FailNext(clamavtest.Failure{StatusCode: 502})
+		c := dial(t, addr)
+		resp := c.roundTrip(encapsulate("REQMOD", "", postRequest, "", "hello"))
+		if resp.status != 200 || resp.body != "hello" {
+			t.Errorf("echo = %d %q", resp.status, resp.body)
+		}
+	})
+}
+
+// --- Shutdown tests ---
+
+func TestShutdown(t *testing.T) {
+	t.Run("idle connections", func(t *testing.T) {
+		srv, _, addr := newTestServer(t)
+		c := dial(t, addr)
+		if resp := c.roundTrip(encapsulate("REQMOD", "Allow: 204\r\n", getRequest, "", "")); resp.status != 204 {
+			t.Fatalf("status = %d", resp.status)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			t.Fatalf("Shutdown: %v", err)
+		}
+		if _, err := c.br.ReadByte(); err != io.EOF {
+			t.Errorf("read after Shutdown = %v, want EOF", err)
+		}
+		if _, err := net.Dial("tcp", addr); err == nil {
+			t.Error("listener still accepts connections")
+		}
+	})
+
+	t.Run("request in flight", func(t *testing.T) {
+		srv, _, addr := newTestServer(t)
+		c := dial(t, addr)
+		req := "RESPMOD icap://127.0.0.1/avscan ICAP/1.0\r\nPreview: 4\r\nAllow: 204\r\n" +
+			fmt.Sprintf("Encapsulated: res-hdr=0, res-body=%d\r\n\r\n", len(okResponse)) + okResponse
+		c.send(req + chunk("hell") + "0\r\n\r\n")
+		if resp := c.read(); resp.status != 100 {
+			t.Fatalf("status = %d, want 100", resp.status)
+		}
+
+		errc := make(chan error, 1)
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			errc <- srv.Shutdown(ctx)
+		}()
+		select {
+		case err := <-errc:
+			t.Fatalf("Shutdown returned %v with a request in flight", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		resp := c.roundTrip(chunk("o") + "0\r\n\r\n")
+		if resp.status != 204 || resp.header.Get("Connection") != "close" {
+			t.Errorf("status = %d, Connection = %q", resp.status, resp.header.Get("Connection"))
+		}
+		if err := <-errc; err != nil {
+			t.Errorf("Shutdown: %v", err)
+		}
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		srv, _, addr := newTestServer(t)
+		c := dial(t, addr)
+		c.send(encapsulate("REQMOD", "", postRequest, "", "hello")[:len("REQMOD")+10])
+		time.Sleep(20 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if err := srv.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Shutdown = %v, want DeadlineExceeded", err)
+		}
+		if _, err := c.br.ReadByte(); err != io.EOF {
+			t.Errorf("read after Shutdown = %v, want EOF", err)
+		}
+	})
+}
@@ -0,0 +1,412 @@
+// Package fakeclamd provides an in-process fake clamd daemon speaking the clamd socket
+// protocol, for tests of the clamd transport.
+package fakeclamd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DevHatRo/clamav-api-sdk-go/clamavtest"
+)
+
+// DefaultVersion is the VERSION reply of a new Server.
+const DefaultVersion = "ClamAV 1.4.1/27480/Tue Nov 26 09:34:56 2024"
+
+// DefaultCommands is the command list of a new Server in its VERSIONCOMMANDS reply.
+const DefaultCommands = "SCAN QUIT RELOAD PING CONTSCAN VERSIONCOMMANDS VERSION END SHUTDOWN MULTISCAN FILDES STATS IDSESSION INSTREAM DETSTATSCLEAR DETSTATS ALLMATCHSCAN"
+
+// DefaultStats is the STATS reply of a new Server: one idle thread pool, an empty
+// queue and the memory statistics of a clamd built with mallinfo.
+const DefaultStats = "POOLS: 1\n\nSTATE: VALID PRIMARY\nTHREADS: live 1  idle 0 max 12 idle-timeout 30\nQUEUE: 0 items\n\tSTATS 0.000054 \n\nMEMSTATS: heap 9.082M mmap 0.000M used 6.902M free 2.184M releasable 0.129M pools 1 pools_used 565.141M pools_total 565.185M\nEND"
+
+// replySizeLimit is the reply to a stream longer than the stream size limit.
+const replySizeLimit = "INSTREAM size limit exceeded. ERROR"
+
+// Server is a fake clamd listening on a TCP or Unix socket. It is safe for concurrent use.
+type Server struct {
+	// Network and Addr are the address to dial, e.g. "tcp" and "127.0.0.1:41234".
+	Network string
+	Addr    string
+
+	ln    net.Listener
+	conns sync.WaitGroup
+
+	mu           sync.Mutex
+	version      string
+	stats        string
+	maxStream    int64
+	delay        time.Duration
+	reply        func(data []byte) string
+	commands     []string
+	open         map[net.Conn]struct{}
+	closed       bool
+	dropNext     int
+	connections  int
+	sessions     int
+	streamChunks []int
+}
+
+// NewServer starts a fake clamd on a loopback TCP port.
+func NewServer() (*Server, error) {
+	return Listen("tcp", "127.0.0.1:0")
+}
+
+// Listen starts a fake clamd on the given network and address, e.g. "unix" and a
+// socket path.
+func Listen(network, address string) (*Server, error) {
+	ln, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		Network: network,
+		Addr:    ln.Addr().String(),
+		ln:      ln,
+		version: DefaultVersion,
+		stats:   DefaultStats,
+		open:    make(map[net.Conn]struct{}),
+	}
+	go s.serve()
+	return s, nil
+}
+
+// Close stops the server and closes all open connections.
+func (s *Server) Close() {
+	s.mu.Lock()
+	s.closed = true
+	for conn := range s.open {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+	_ = s.ln.Close()
+	s.conns.Wait()
+}
+
+// SetVersion sets the VERSION reply.
+func (s *Server) SetVersion(v string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.version = v
+}
+
+// SetStats sets the STATS reply.
+func (s *Server) SetStats(stats string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats = stats
+}
+
+// SetStreamMaxLength makes INSTREAM reject streams longer than n bytes, like the
+// clamd StreamMaxLength setting. Zero means no limit.
+func (s *Server) SetStreamMaxLength(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxStream = n
+}
+
+// SetDelay delays every reply by d.
+func (s *Server) SetDelay(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delay = d
+}
+
+// SetReply replaces the default INSTREAM verdict, which reports content containing
+// the EICAR test string as infected. fn returns the reply without its terminator,
+// e.g. "stream: OK".
+func (s *Server) SetReply(fn func(data []byte) string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reply = fn
+}
+
+// DropNext makes the server close the connection right after reading each of the
+// next n commands, without replying.
+func (s *Server) DropNext(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dropNext = n
+}
+
+// Commands returns the commands received so far, without prefix and terminator.
+func (s *Server) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+// Connections returns the number of connections accepted so far.
+func (s *Server) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connections
+}
+
+// Sessions returns the number of IDSESSION sessions started so far.
+func (s *Server) Sessions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions
+}
+
+// CloseConnections closes all open connections, as clamd does with sessions that
+// exceed its IdleTimeout, while the server keeps accepting new ones.
+func (s *Server) CloseConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.open {
+		_ = conn.Close()
+	}
+}
+
+// StreamChunks returns the number of chunks of each INSTREAM received so far.
+func (s *Server) StreamChunks() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int(nil), s.streamChunks...)
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = conn.Close()
+			return
+		}
+		s.open[conn] = struct{}{}
+		s.connections++
+		s.conns.Add(1)
+		s.mu.Unlock()
+
+		go func() {
+			defer s.conns.Done()
+			defer func() {
+				s.mu.Lock()
+				delete(s.open, conn)
+				s.mu.Unlock()
+				_ = conn.Close()
+			}()
+			s.handle(conn)
+		}()
+	}
+}
+
+// handle serves the commands of one connection.
+func (s *Server) handle(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	cmd, delim, err := readCommand(r)
+	if err != nil {
+		return
+	}
+	if !s.record(cmd) {
+		return
+	}
+	if cmd == "IDSESSION" {
+		s.session(conn, r)
+		return
+	}
+	reply, ok := s.execute(cmd, r)
+	if !ok {
+		return
+	}
+	s.wait()
+	_, _ = io.WriteString(conn, reply+string(delim))
+	drain(conn, r)
+}
+
+// drain reads the rest of the input before the connection is closed, as clamd does
+// after a reply, so that a client still sending, e.g. after the stream size limit was
+// exceeded, reads the reply rather than a connection reset.
+func drain(conn net.Conn, r io.Reader) {
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _ = io.Copy(io.Discard, r)
+}
+
+// session serves the commands of an IDSESSION until END. Commands are numbered from 1
+// and replies are prefixed with the number. Verdicts are computed concurrently, so
+// replies may be sent out of order, as by clamd.
+func (s *Server) session(conn net.Conn, r *bufio.Reader) {
+	s.mu.Lock()
+	s.sessions++
+	s.mu.Unlock()
+
+	var wmu sync.Mutex
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	send := func(id int, reply string) {
+		s.wait()
+		wmu.Lock()
+		defer wmu.Unlock()
+		_, _ = fmt.Fprintf(conn, "%d: %s\x00", id, reply)
+	}
+
+	for id := 1; ; id++ {
+		cmd, _, err := readCommand(r)
+		if err != nil || !s.record(cmd) || cmd == "END" {
+			return
+		}
+		if cmd != "INSTREAM" {
+			reply, _ := s.execute(cmd, r)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				send(id, reply)
+			}()
+			continue
+		}
+
+		data, ok, tooLarge := s.readStream(r)
+		switch {
+		case !ok:
+			return
+		case tooLarge:
+			// clamd ends the session after this error.
+			wg.Wait()
+			send(id, replySizeLimit)
+			drain(conn, r)
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			send(id, s.verdict(data))
+		}()
+	}
+}
+
+// record records a command and reports whether to serve it.
+func (s *Server) record(cmd string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commands = append(s.commands, cmd)
+	if s.dropNext > 0 {
+		s.dropNext--
+		return false
+	}
+	return true
+}
+
+// wait applies the configured delay.
+func (s *Server) wait() {
+	s.mu.Lock()
+	delay := s.delay
+	s.mu.Unlock()
+	time.Sleep(delay)
+}
+
+// execute runs one command and returns its reply, or false if the connection must be
+// closed without one.
+func (s *Server) execute(cmd string, r *bufio.Reader) (string, bool) {
+	switch cmd {
+	case "PING":
+		return "PONG", true
+	case "VERSION":
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.version, true
+	case "VERSIONCOMMANDS":
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.version + "| COMMANDS: " + DefaultCommands, true
+	case "STATS":
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.stats, true
+	case "INSTREAM":
+		return s.instream(r)
+	}
+	return "UNKNOWN COMMAND", true
+}
+
+// instream reads a chunked stream and returns the verdict.
+func (s *Server) instream(r *bufio.Reader) (string, bool) {
+	data, ok, tooLarge := s.readStream(r)
+	switch {
+	case !ok:
+		return "", false
+	case tooLarge:
+		return replySizeLimit, true
+	}
+	return s.verdict(data), true
+}
+
+// readStream reads the chunks of an INSTREAM. It reports whether the stream was read
+// completely, and whether it was cut short for exceeding the stream size limit.
+func (s *Server) readStream(r *bufio.Reader) (data []byte, ok, tooLarge bool) {
+	s.mu.Lock()
+	maxStream := s.maxStream
+	s.mu.Unlock()
+
+	var buf bytes.Buffer
+	chunks := 0
+	for {
+		var size uint32
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return nil, false, false
+		}
+		if size == 0 {
+			break
+		}
+		chunks++
+		if maxStream > 0 && int64(buf.Len())+int64(size) > maxStream {
+			return nil, true, true
+		}
+		if _, err := io.CopyN(&buf, r, int64(size)); err != nil {
+			return nil, false, false
+		}
+	}
+
+	s.mu.Lock()
+	s.streamChunks = append(s.streamChunks, chunks)
+	s.mu.Unlock()
+	return buf.Bytes(), true, false
+}
+
+// verdict returns the INSTREAM reply for data.
+func (s *Server) verdict(data []byte) string {
+	s.mu.Lock()
+	reply := s.reply
+	s.mu.Unlock()
+
+	if reply != nil {
+		return reply(data)
+	}
+	if bytes.Contains(data, []byte(clamavtest.EICAR)) {
+		return fmt.Sprintf("stream: %s FOUND", clamavtest.EICARSignature)
+	}
+	return "stream: OK"
+}
+
+// readCommand reads one command in any of the clamd formats: "zCMD\0", "nCMD\n" or
+// "CMD\n". It returns the command and the reply terminator.
+func readCommand(r *bufio.Reader) (string, byte, error) {
+	prefix, err := r.Peek(1)
+	if err != nil {
+		return "", 0, err
+	}
+	delim := byte('\n')
+	switch prefix[0] {
+	case 'z':
+		delim = 0
+		fallthrough
+	case 'n':
+		_, _ = r.Discard(1)
+	}
+	line, err := r.ReadString(delim)
+	if err != nil {
+		return "", 0, err
+	}
+	return strings.TrimSuffix(line, string(delim)), delim, nil
+}
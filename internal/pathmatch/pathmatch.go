@@ -0,0 +1,30 @@
+// Package pathmatch implements the Include and Exclude patterns shared by directory
+// scans and the watcher.
+package pathmatch
+
+import "path"
+
+// Validate returns the first malformed pattern and its path.Match error, if any.
+func Validate(patterns []string) (string, error) {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return pattern, err
+		}
+	}
+	return "", nil
+}
+
+// Any reports whether the slash-separated name or its base name matches any of the
+// patterns.
+func Any(patterns []string, name string) bool {
+	base := path.Base(name)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, base); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,22 @@
+// Package readerutil provides io.Reader helpers shared by the REST, gRPC and clamd
+// clients.
+package readerutil
+
+import "io"
+
+// Rewinder returns a function that seeks r back to its current offset, and
+// false if r is not an io.Seeker.
+func Rewinder(r io.Reader) (func() error, bool) {
+	seeker, ok := r.(io.Seeker)
+	if !ok {
+		return nil, false
+	}
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, false
+	}
+	return func() error {
+		_, err := seeker.Seek(start, io.SeekStart)
+		return err
+	}, true
+}
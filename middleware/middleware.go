@@ -0,0 +1,298 @@
+// Package middleware provides net/http middleware that scans uploaded files with
+// ClamAV before the wrapped handler runs.
+//
+// Every file part of multipart/form-data requests is scanned, as well as the whole
+// body of requests with one of the configured raw content types. Infected uploads
+// are rejected; clean requests reach the handler with their body intact and the
+// scan results in the request context.
+//
+// # Quick Start
+//
+//	client, err := clamav.NewClient("http://localhost:6000")
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	defer client.Close()
+//
+//	scan := middleware.New(client, middleware.WithRawContentTypes("application/octet-stream"))
+//	http.Handle("/upload", scan(uploadHandler))
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+
+	clamav "github.com/DevHatRo/clamav-api-sdk-go"
+)
+
+// ErrBodyTooLarge is wrapped by the error passed to the error handler when the
+// request body exceeds the maximum body size.
+var ErrBodyTooLarge = errors.New("request body too large")
+
+// FileResult is the outcome of scanning one uploaded file.
+type FileResult struct {
+	// FieldName is the multipart form field name, or empty for a raw body.
+	FieldName string
+	// Filename is the file name sent by the client, if any.
+	Filename string
+	// Result is the scan result, or nil if the scan request failed.
+	Result *clamav.ScanResult
+	// Err is set when the file could not be scanned, including when the server
+	// returned an ERROR verdict (only reachable with WithFailOpen).
+	Err error
+}
+
+type contextKey struct{}
+
+// Results returns the scan results attached to ctx by the middleware, in upload
+// order, and false if the request was not scanned.
+func Results(ctx context.Context) ([]FileResult, bool) {
+	files, ok := ctx.Value(contextKey{}).([]FileResult)
+	return files, ok
+}
+
+// New returns middleware that scans uploads with s before calling the wrapped handler.
+// Requests that carry no multipart form or raw content type are passed through unscanned.
+func New(s clamav.Scanner, opts ...Option) func(http.Handler) http.Handler {
+	cfg := &config{
+		maxBodySize:     defaultMaxBodySize,
+		memoryThreshold: defaultMemoryThreshold,
+		onInfected:      defaultInfectedHandler,
+		onError:         defaultErrorHandler,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil || r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var scan func(ctx context.Context, body *spooledBody) ([]FileResult, error)
+			switch {
+			case mediaType == "multipart/form-data":
+				boundary := params["boundary"]
+				scan = func(ctx context.Context, body *spooledBody) ([]FileResult, error) {
+					return scanMultipart(ctx, s, body, boundary, cfg.failOpen)
+				}
+			case cfg.isRaw(mediaType):
+				scan = func(ctx context.Context, body *spooledBody) ([]FileResult, error) {
+					return scanRaw(ctx, s, body, rawFilename(r), cfg.failOpen)
+				}
+			default:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := spoolBody(r.Body, cfg.maxBodySize, cfg.memoryThreshold, cfg.tempDir)
+			if err != nil {
+				cfg.onError(w, r, err)
+				return
+			}
+			defer func() { _ = body.Close() }()
+
+			files, err := scan(r.Context(), body)
+			if err != nil {
+				cfg.onError(w, r, err)
+				return
+			}
+			for _, f := range files {
+				if f.Result != nil && f.Result.IsInfected() {
+					cfg.onInfected(w, r, files)
+					return
+				}
+			}
+
+			if err := body.rewind(); err != nil {
+				cfg.onError(w, r, err)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), contextKey{}, files))
+			r.Body = io.NopCloser(body)
+			r.ContentLength = body.size
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isRaw reports whether bodies of mediaType are scanned as a single file.
+func (c *config) isRaw(mediaType string) bool {
+	for _, t := range c.rawContentTypes {
+		if t == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// scanMultipart scans every file part of a multipart body.
+func scanMultipart(ctx context.Context, s clamav.Scanner, body io.Reader, boundary string, failOpen bool) ([]FileResult, error) {
+	if boundary == "" {
+		return nil, clamav.NewValidationError("multipart boundary is missing", nil)
+	}
+
+	var files []FileResult
+	mr := multipart.NewReader(body, boundary)
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, clamav.NewValidationError("malformed multipart body", err)
+		}
+
+		filename := part.FileName()
+		if filename == "" {
+			_ = part.Close()
+			continue
+		}
+
+		file := FileResult{FieldName: part.FormName(), Filename: filename}
+		file.Result, file.Err = s.ScanReader(ctx, part, filename)
+		file.Err = scanErr(file.Result, file.Err)
+		_ = part.Close()
+		if file.Err != nil && !failOpen {
+			return nil, file.Err
+		}
+		files = append(files, file)
+	}
+}
+
+// scanRaw scans a whole request body as one file.
+func scanRaw(ctx context.Context, s clamav.Scanner, body *spooledBody, filename string, failOpen bool) ([]FileResult, error) {
+	file := FileResult{Filename: filename}
+	file.Result, file.Err = s.StreamScanReader(ctx, body, filename, body.size)
+	file.Err = scanErr(file.Result, file.Err)
+	if file.Err != nil && !failOpen {
+		return nil, file.Err
+	}
+	return []FileResult{file}, nil
+}
+
+// scanErr returns err, or the error of an ERROR verdict, so that files the server
+// failed to scan are not mistaken for clean ones.
+func scanErr(result *clamav.ScanResult, err error) error {
+	if err != nil || result == nil {
+		return err
+	}
+	return result.Err()
+}
+
+// rawFilename returns the file name of a raw upload from its Content-Disposition
+// header, falling back to the last element of the URL path.
+func rawFilename(r *http.Request) string {
+	if _, params, err := mime.ParseMediaType(r.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		return path.Base(params["filename"])
+	}
+	if base := path.Base(r.URL.Path); base != "/" && base != "." {
+		return base
+	}
+	return "upload"
+}
+
+// defaultInfectedHandler responds 422 with the infected files as JSON.
+func defaultInfectedHandler(w http.ResponseWriter, _ *http.Request, files []FileResult) {
+	type infected struct {
+		Field    string `json:"field,omitempty"`
+		Filename string `json:"filename,omitempty"`
+		Virus    string `json:"virus"`
+	}
+	resp := struct {
+		Error string     `json:"error"`
+		Files []infected `json:"files"`
+	}{Error: "infected file rejected"}
+	for _, f := range files {
+		if f.Result != nil && f.Result.IsInfected() {
+			resp.Files = append(resp.Files, infected{Field: f.FieldName, Filename: f.Filename, Virus: f.Result.Message})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// defaultErrorHandler maps err to 413, 400 or 503.
+func defaultErrorHandler(w http.ResponseWriter, _ *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrBodyTooLarge):
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+	case clamav.IsValidationError(err):
+		http.Error(w, "malformed upload", http.StatusBadRequest)
+	default:
+		http.Error(w, "virus scan unavailable", http.StatusServiceUnavailable)
+	}
+}
+
+// spooledBody holds a request body in memory or, past the memory threshold, in a temporary file.
+type spooledBody struct {
+	io.ReadSeeker
+	size int64
+	file *os.File
+}
+
+// spoolBody reads r to EOF, failing with ErrBodyTooLarge past maxSize bytes.
+func spoolBody(r io.Reader, maxSize, threshold int64, dir string) (*spooledBody, error) {
+	limited := io.LimitReader(r, maxSize+1)
+
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, io.LimitReader(limited, threshold+1))
+	if err != nil {
+		return nil, clamav.NewValidationError("failed to read request body", err)
+	}
+	if n > maxSize {
+		return nil, clamav.NewValidationError(ErrBodyTooLarge.Error(), ErrBodyTooLarge)
+	}
+	if n <= threshold {
+		return &spooledBody{ReadSeeker: bytes.NewReader(buf.Bytes()), size: n}, nil
+	}
+
+	f, err := os.CreateTemp(dir, "clamav-upload-*")
+	if err != nil {
+		return nil, clamav.NewValidationError("failed to create temporary file", err)
+	}
+	b := &spooledBody{ReadSeeker: f, file: f}
+	if b.size, err = io.Copy(f, io.MultiReader(&buf, limited)); err != nil {
+		_ = b.Close()
+		return nil, clamav.NewValidationError("failed to read request body", err)
+	}
+	if b.size > maxSize {
+		_ = b.Close()
+		return nil, clamav.NewValidationError(ErrBodyTooLarge.Error(), ErrBodyTooLarge)
+	}
+	if err := b.rewind(); err != nil {
+		_ = b.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+// rewind seeks back to the start of the body.
+func (b *spooledBody) rewind() error {
+	if _, err := b.Seek(0, io.SeekStart); err != nil {
+		return clamav.NewValidationError("failed to rewind request body", err)
+	}
+	return nil
+}
+
+// Close removes the temporary file, if any.
+func (b *spooledBody) Close() error {
+	if b.file == nil {
+		return nil
+	}
+	_ = b.file.Close()
+	return os.Remove(b.file.Name())
+}
@@ -0,0 +1,305 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	clamav "github.com/DevHatRo/clamav-api-sdk-go"
+	"github.com/DevHatRo/clamav-api-sdk-go/internal/testutil"
+)
+
+// newScanner starts a mock ClamAV API that reports content containing "EICAR" as infected.
+func newScanner(t *testing.T, calls *int32) clamav.Scanner {
+	t.Helper()
+	check := func(data []byte, filename string) (int, interface{}) {
+		atomic.AddInt32(calls, 1)
+		if bytes.Contains(data, []byte("EICAR")) {
+			return http.StatusOK, testutil.InfectedScanResponse()
+		}
+		return http.StatusOK, testutil.CleanScanResponse()
+	}
+	srv := testutil.NewMockServer(map[string]http.HandlerFunc{
+		"/api/scan":        testutil.ScanHandler(check),
+		"/api/stream-scan": testutil.ScanHandler(check),
+	})
+	t.Cleanup(srv.Close)
+
+	client, err := clamav.NewClient(srv.URL)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+// multipartRequest builds an upload with a text field and the given files.
+func multipartRequest(t *testing.T, files map[string]string) *http.Request {
+	t.Helper()
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	if err := w.WriteField("title", "report"); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		fw, err := w.CreateFormFile("file", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.WriteString(fw, content)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", &buf)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	return req
+}
+
+// echoHandler parses the form it receives and records the context results.
+type echoHandler struct {
+	called  bool
+	title   string
+	files   []string
+	results []FileResult
+}
+
+func (h *echoHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.called = true
+	h.results, _ = Results(r.Context())
+	if err := r.ParseMultipartForm(1 << 20); err == nil {
+		h.title = r.FormValue("title")
+		for _, fh := range r.MultipartForm.File["file"] {
+			h.files = append(h.files, fh.Filename)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// --- Middleware tests ---
+
+func TestMiddlewareMultipart(t *testing.T) {
+	t.Run("clean upload reaches handler", func(t *testing.T) {
+		var calls int32
+		next := &echoHandler{}
+		rec := httptest.NewRecorder()
+		New(newScanner(t, &calls))(next).ServeHTTP(rec, multipartRequest(t, map[string]string{"a.txt": "hello"}))
+
+		if rec.Code != http.StatusNoContent || !next.called {
+			t.Fatalf("status = %d, called = %v", rec.Code, next.called)
+		}
+		if next.title != "report" || len(next.files) != 1 || next.files[0] != "a.txt" {
+			t.Errorf("handler saw title %q and files %v", next.title, next.files)
+		}
+		if len(next.results) != 1 || !next.results[0].Result.IsClean() || next.results[0].FieldName != "file" {
+			t.Errorf("results = %+v", next.results)
+		}
+		if calls != 1 {
+			t.Errorf("scan calls = %d, want 1", calls)
+		}
+	})
+
+	t.Run("infected upload is rejected", func(t *testing.T) {
+		var calls int32
+		next := &echoHandler{}
+		rec := httptest.NewRecorder()
+		req := multipartRequest(t, map[string]string{"a.txt": "hello", "eicar.com": "EICAR"})
+		New(newScanner(t, &calls))(next).ServeHTTP(rec, req)
+
+		if next.called {
+			t.Error("handler should not be called")
+		}
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("status = %d, want 422", rec.Code)
+		}
+		if !strings.Contains(rec.Body.String(), `"filename":"eicar.com"`) {
+			t.Errorf("body = %s", rec.Body.String())
+		}
+	})
+
+	t.Run("custom infected handler", func(t *testing.T) {
+		var calls int32
+		var got []FileResult
+		mw := New(newScanner(t, &calls), WithInfectedHandler(func(w http.ResponseWriter, _ *http.Request, files []FileResult) {
+			got = files
+			w.WriteHeader(http.StatusForbidden)
+		}))
+		rec := httptest.NewRecorder()
+		mw(&echoHandler{}).ServeHTTP(rec, multipartRequest(t, map[string]string{"eicar.com": "EICAR"}))
+
+		if rec.Code != http.StatusForbidden || len(got) != 1 || got[0].Result.Message != "Eicar-Test-Signature" {
+			t.Errorf("status = %d, files = %+v", rec.Code, got)
+		}
+	})
+
+	t.Run("malformed body", func(t *testing.T) {
+		var calls int32
+		req := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader("not multipart"))
+		req.Header.Set("Content-Type", "multipart/form-data; boundary=xyz")
+		rec := httptest.NewRecorder()
+		New(newScanner(t, &calls))(&echoHandler{}).ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", rec.Code)
+		}
+	})
+
+	t.Run("body too large", func(t *testing.T) {
+		var calls int32
+		rec := httptest.NewRecorder()
+		req := multipartRequest(t, map[string]string{"a.txt": strings.Repeat("x", 1000)})
+		New(newScanner(t, &calls), WithMaxBodySize(100))(&echoHandler{}).ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("status = %d, want 413", rec.Code)
+		}
+		if calls != 0 {
+			t.Errorf("scan calls = %d, want 0", calls)
+		}
+	})
+
+	t.Run("spills large bodies to disk", func(t *testing.T) {
+		var calls int32
+		next := &echoHandler{}
+		rec := httptest.NewRecorder()
+		req := multipartRequest(t, map[string]string{"a.txt": strings.Repeat("x", 1000)})
+		New(newScanner(t, &calls), WithMemoryThreshold(10), WithTempDir(t.TempDir()))(next).ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusNoContent || len(next.files) != 1 {
+			t.Errorf("status = %d, files = %v", rec.Code, next.files)
+		}
+	})
+}
+
+func TestMiddlewareRawBody(t *testing.T) {
+	t.Run("configured content type is scanned", func(t *testing.T) {
+		var calls int32
+		var body string
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			data, _ := io.ReadAll(r.Body)
+			body = string(data)
+			files, _ := Results(r.Context())
+			if len(files) != 1 || files[0].Filename != "doc.pdf" {
+				t.Errorf("results = %+v", files)
+			}
+		})
+		req := httptest.NewRequest(http.MethodPut, "/files/doc.pdf", strings.NewReader("pdf content"))
+		req.Header.Set("Content-Type", "application/PDF")
+		rec := httptest.NewRecorder()
+		New(newScanner(t, &calls), WithRawContentTypes("application/pdf"))(next).ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK || body != "pdf content" {
+			t.Errorf("status = %d, body = %q", rec.Code, body)
+		}
+		if calls != 1 {
+			t.Errorf("scan calls = %d, want 1", calls)
+		}
+	})
+
+	t.Run("infected raw body is rejected", func(t *testing.T) {
+		var calls int32
+		req := httptest.NewRequest(http.MethodPut, "/files/x", strings.NewReader("EICAR"))
+		req.Header.Set("Content-Type", "application/octet-stream")
+		rec := httptest.NewRecorder()
+		New(newScanner(t, &calls), WithRawContentTypes("application/octet-stream"))(&echoHandler{}).ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("status = %d, want 422", rec.Code)
+		}
+	})
+
+	t.Run("other content types pass through", func(t *testing.T) {
+		var calls int32
+		req := httptest.NewRequest(http.MethodPost, "/api", strings.NewReader(`{"EICAR":1}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		next := &echoHandler{}
+		New(newScanner(t, &calls))(next).ServeHTTP(rec, req)
+
+		if !next.called || calls != 0 || next.results != nil {
+			t.Errorf("called = %v, calls = %d, results = %v", next.called, calls, next.results)
+		}
+	})
+}
+
+func TestMiddlewareScanErrors(t *testing.T) {
+	dead := func(t *testing.T) clamav.Scanner {
+		srv := httptest.NewServer(http.NotFoundHandler())
+		srv.Close()
+		client, err := clamav.NewClient(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = client.Close() })
+		return client
+	}
+
+	t.Run("fails closed by default", func(t *testing.T) {
+		next := &echoHandler{}
+		rec := httptest.NewRecorder()
+		New(dead(t))(next).ServeHTTP(rec, multipartRequest(t, map[string]string{"a.txt": "hello"}))
+
+		if next.called || rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("called = %v, status = %d, want 503", next.called, rec.Code)
+		}
+	})
+
+	t.Run("fail open", func(t *testing.T) {
+		next := &echoHandler{}
+		rec := httptest.NewRecorder()
+		New(dead(t), WithFailOpen(true))(next).ServeHTTP(rec, multipartRequest(t, map[string]string{"a.txt": "hello"}))
+
+		if !next.called || len(next.results) != 1 || !clamav.IsConnectionError(next.results[0].Err) {
+			t.Errorf("called = %v, results = %+v", next.called, next.results)
+		}
+	})
+
+	t.Run("error verdict", func(t *testing.T) {
+		srv := testutil.NewMockServer(map[string]http.HandlerFunc{
+			"/api/scan": testutil.ScanHandler(func(_ []byte, filename string) (int, interface{}) {
+				return http.StatusOK, map[string]interface{}{"status": "ERROR", "message": "Can't allocate memory", "filename": filename}
+			}),
+		})
+		t.Cleanup(srv.Close)
+		client, err := clamav.NewClient(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = client.Close() })
+
+		next := &echoHandler{}
+		rec := httptest.NewRecorder()
+		New(client)(next).ServeHTTP(rec, multipartRequest(t, map[string]string{"a.txt": "hello"}))
+		if next.called || rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("called = %v, status = %d, want 503", next.called, rec.Code)
+		}
+
+		next = &echoHandler{}
+		New(client, WithFailOpen(true))(next).ServeHTTP(httptest.NewRecorder(), multipartRequest(t, map[string]string{"a.txt": "hello"}))
+		if !next.called || len(next.results) != 1 || !clamav.IsScanError(next.results[0].Err) {
+			t.Errorf("called = %v, results = %+v, want a scan error", next.called, next.results)
+		}
+	})
+
+	t.Run("custom error handler", func(t *testing.T) {
+		var got error
+		mw := New(dead(t), WithErrorHandler(func(w http.ResponseWriter, _ *http.Request, err error) {
+			got = err
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		rec := httptest.NewRecorder()
+		mw(&echoHandler{}).ServeHTTP(rec, multipartRequest(t, map[string]string{"a.txt": "hello"}))
+
+		var clamErr *clamav.Error
+		if rec.Code != http.StatusBadGateway || !errors.As(got, &clamErr) {
+			t.Errorf("status = %d, err = %v", rec.Code, got)
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultMaxBodySize     = 100 * 1024 * 1024 // 100MB
+	defaultMemoryThreshold = 8 * 1024 * 1024   // 8MB
+)
+
+// Option configures the middleware.
+type Option func(*config)
+
+type config struct {
+	maxBodySize     int64
+	memoryThreshold int64
+	tempDir         string
+	rawContentTypes []string
+	failOpen        bool
+	onInfected      func(w http.ResponseWriter, r *http.Request, files []FileResult)
+	onError         func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// WithMaxBodySize sets the largest request body accepted (default: 100MB).
+// Larger bodies are rejected with 413 Request Entity Too Large.
+func WithMaxBodySize(n int64) Option {
+	return func(c *config) {
+		if n > 0 {
+			c.maxBodySize = n
+		}
+	}
+}
+
+// WithMemoryThreshold sets how much of a body is buffered in memory before it is
+// spilled to a temporary file (default: 8MB).
+func WithMemoryThreshold(n int64) Option {
+	return func(c *config) {
+		if n >= 0 {
+			c.memoryThreshold = n
+		}
+	}
+}
+
+// WithTempDir sets the directory for spilled bodies (default: os.TempDir()).
+func WithTempDir(dir string) Option {
+	return func(c *config) {
+		c.tempDir = dir
+	}
+}
+
+// WithRawContentTypes sets media types, e.g. "application/octet-stream" or "application/pdf",
+// whose whole request body is scanned as a single file. Multipart form data is always scanned.
+func WithRawContentTypes(mediaTypes ...string) Option {
+	return func(c *config) {
+		for _, t := range mediaTypes {
+			c.rawContentTypes = append(c.rawContentTypes, strings.ToLower(t))
+		}
+	}
+}
+
+// WithFailOpen passes requests to the wrapped handler when scanning fails with an error,
+// instead of rejecting them. The failed files are still reported in the context results.
+func WithFailOpen(failOpen bool) Option {
+	return func(c *config) {
+		c.failOpen = failOpen
+	}
+}
+
+// WithInfectedHandler sets the response written when an upload is infected.
+// files contains the result of every file scanned before the request was rejected.
+// The default responds 422 Unprocessable Entity with a JSON body naming the infected files.
+func WithInfectedHandler(fn func(w http.ResponseWriter, r *http.Request, files []FileResult)) Option {
+	return func(c *config) {
+		if fn != nil {
+			c.onInfected = fn
+		}
+	}
+}
+
+// WithErrorHandler sets the response written when the body cannot be read or scanned.
+// The default responds 413 for oversized bodies, 400 for malformed multipart data,
+// and 503 Service Unavailable for scanner errors.
+func WithErrorHandler(fn func(w http.ResponseWriter, r *http.Request, err error)) Option {
+	return func(c *config) {
+		if fn != nil {
+			c.onError = fn
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package clamav
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"os"
+	"sync"
+
+	"github.com/DevHatRo/clamav-api-sdk-go/internal/readerutil"
+)
+
+// multipartBody streams a single-file multipart form through an io.Pipe, so
+// uploads use constant memory regardless of the file size.
+type multipartBody struct {
+	r        io.Reader
+	filename string
+	boundary string
+	size     int64 // length of the whole body, or -1 if unknown
+	rewind   func() error
+
+	mu      sync.Mutex
+	pr      *io.PipeReader
+	done    chan struct{}
+	readErr error
+}
+
+// newMultipartBody prepares a multipart body for r. The Content-Length is
+// precomputed when the remaining length of r can be determined.
+func newMultipartBody(r io.Reader, filename string) (*multipartBody, error) {
+	b := &multipartBody{
+		r:        r,
+		filename: filename,
+		boundary: multipart.NewWriter(io.Discard).Boundary(),
+		size:     -1,
+	}
+
+	if n := readerSize(r); n >= 0 {
+		// Render the envelope without the file content to measure its overhead.
+		var envelope bytes.Buffer
+		w := multipart.NewWriter(&envelope)
+		if err := w.SetBoundary(b.boundary); err != nil {
+			return nil, NewValidationError("failed to create multipart form", err)
+		}
+		if _, err := w.CreateFormFile("file", filename); err != nil {
+			return nil, NewValidationError("failed to create multipart form", err)
+		}
+		if err := w.Close(); err != nil {
+			return nil, NewValidationError("failed to close multipart writer", err)
+		}
+		b.size = int64(envelope.Len()) + n
+	}
+
+	if rewind, ok := readerutil.Rewinder(r); ok {
+		b.rewind = rewind
+	}
+
+	return b, nil
+}
+
+// contentType returns the Content-Type header for the body.
+func (b *multipartBody) contentType() string {
+	return "multipart/form-data; boundary=" + b.boundary
+}
+
+// open starts writing a fresh copy of the body and returns its reading end.
+// The previous copy, if any, must have been released first.
+func (b *multipartBody) open() io.ReadCloser {
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+
+	b.mu.Lock()
+	b.pr, b.done, b.readErr = pr, done, nil
+	b.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		_ = pw.CloseWithError(b.write(pw))
+	}()
+
+	return pr
+}
+
+// write renders the multipart form into w.
+func (b *multipartBody) write(w io.Writer) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(b.boundary); err != nil {
+		return err
+	}
+
+	part, err := mw.CreateFormFile("file", b.filename)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, recordingReader{b.r, b}); err != nil {
+		return err
+	}
+	return mw.Close()
+}
+
+// getBody returns a GetBody function that rewinds the source reader and reopens
+// the body, or nil if the source cannot be rewound.
+func (b *multipartBody) getBody() func() (io.ReadCloser, error) {
+	if b.rewind == nil {
+		return nil
+	}
+	return func() (io.ReadCloser, error) {
+		b.release()
+		if err := b.rewind(); err != nil {
+			return nil, err
+		}
+		return b.open(), nil
+	}
+}
+
+// release stops the current writer goroutine and waits for it to exit, so the
+// source reader is no longer in use.
+func (b *multipartBody) release() {
+	b.mu.Lock()
+	pr, done := b.pr, b.done
+	b.mu.Unlock()
+
+	if pr == nil {
+		return
+	}
+	_ = pr.CloseWithError(io.ErrClosedPipe)
+	<-done
+}
+
+// readError returns the error, if any, from reading the source of the current copy.
+func (b *multipartBody) readError() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.readErr
+}
+
+// recordingReader records read errors of the source reader on its body.
+type recordingReader struct {
+	r    io.Reader
+	body *multipartBody
+}
+
+// Read reads from the source and records any error other than io.EOF.
+func (r recordingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil && err != io.EOF {
+		r.body.mu.Lock()
+		r.body.readErr = err
+		r.body.mu.Unlock()
+	}
+	return n, err
+}
+
+// readerSize returns the number of bytes remaining in r, or -1 if it cannot be
+// determined without reading.
+func readerSize(r io.Reader) int64 {
+	switch v := r.(type) {
+	case interface{ Len() int }: // bytes.Reader, bytes.Buffer, strings.Reader
+		return int64(v.Len())
+	case *os.File:
+		stat, err := v.Stat()
+		if err != nil || !stat.Mode().IsRegular() {
+			return -1
+		}
+		offset, err := v.Seek(0, io.SeekCurrent)
+		if err != nil || offset > stat.Size() {
+			return -1
+		}
+		return stat.Size() - offset
+	default:
+		return -1
+	}
+}
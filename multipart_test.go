@@ -0,0 +1,138 @@
+package clamav
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DevHatRo/clamav-api-sdk-go/internal/testutil"
+)
+
+// errReader returns data and then a read error.
+type errReader struct {
+	data []byte
+	err  error
+}
+
+func (r *errReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, r.err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestScanReaderStreaming(t *testing.T) {
+	type received struct {
+		contentLength    int64
+		transferEncoding []string
+		data             []byte
+		filename         string
+	}
+	var got received
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = received{contentLength: r.ContentLength, transferEncoding: r.TransferEncoding}
+		testutil.ScanHandler(func(data []byte, filename string) (int, interface{}) {
+			got.data, got.filename = data, filename
+			return http.StatusOK, testutil.CleanScanResponse()
+		})(w, r)
+	}))
+	defer srv.Close()
+
+	client := mustNewClient(t, srv.URL)
+	defer func() { _ = client.Close() }()
+
+	t.Run("known size sets Content-Length", func(t *testing.T) {
+		data := bytes.Repeat([]byte("0123456789"), 100000)
+		if _, err := client.ScanFile(context.Background(), data, "big.bin"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.contentLength <= int64(len(data)) {
+			t.Errorf("ContentLength = %d, want > %d", got.contentLength, len(data))
+		}
+		if len(got.transferEncoding) != 0 {
+			t.Errorf("TransferEncoding = %v, want none", got.transferEncoding)
+		}
+		if !bytes.Equal(got.data, data) {
+			t.Errorf("received %d bytes, want %d", len(got.data), len(data))
+		}
+	})
+
+	t.Run("file uses remaining size", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "offset.txt")
+		if err := os.WriteFile(path, []byte("skip-me:payload"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = f.Close() }()
+		if _, err := f.Seek(int64(len("skip-me:")), io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := client.ScanReader(context.Background(), f, "offset.txt"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(got.data) != "payload" {
+			t.Errorf("data = %q, want %q", got.data, "payload")
+		}
+		if got.contentLength <= 0 {
+			t.Errorf("ContentLength = %d, want precomputed length", got.contentLength)
+		}
+	})
+
+	t.Run("unknown size is chunked", func(t *testing.T) {
+		r := io.MultiReader(strings.NewReader("unknown "), strings.NewReader("length"))
+		if _, err := client.ScanReader(context.Background(), r, "pipe.txt"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.contentLength != -1 {
+			t.Errorf("ContentLength = %d, want -1", got.contentLength)
+		}
+		if string(got.data) != "unknown length" || got.filename != "pipe.txt" {
+			t.Errorf("received %q as %q", got.data, got.filename)
+		}
+	})
+
+	t.Run("default filename", func(t *testing.T) {
+		if _, err := client.ScanReader(context.Background(), strings.NewReader("x"), ""); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.filename != "file" {
+			t.Errorf("filename = %q, want %q", got.filename, "file")
+		}
+	})
+
+	t.Run("read error is a validation error", func(t *testing.T) {
+		readErr := errors.New("disk on fire")
+		_, err := client.ScanReader(context.Background(), &errReader{data: []byte("partial"), err: readErr}, "broken.bin")
+		if !IsValidationError(err) {
+			t.Fatalf("expected validation error, got: %v", err)
+		}
+		if !errors.Is(err, readErr) {
+			t.Errorf("expected error to wrap the read error, got: %v", err)
+		}
+	})
+}
+
+func TestReaderSize(t *testing.T) {
+	if got := readerSize(strings.NewReader("abc")); got != 3 {
+		t.Errorf("strings.Reader size = %d, want 3", got)
+	}
+	if got := readerSize(bytes.NewBufferString("abcd")); got != 4 {
+		t.Errorf("bytes.Buffer size = %d, want 4", got)
+	}
+	if got := readerSize(io.MultiReader()); got != -1 {
+		t.Errorf("MultiReader size = %d, want -1", got)
+	}
+}
@@ -42,3 +42,70 @@ func WithHeaders(headers map[string]string) ClientOption {
 		}
 	}
 }
+
+// WithRetryPolicy enables automatic retries of transient failures.
+// Requests whose body cannot be replayed are never retried: scans rewind readers
+// that implement io.Seeker (including the data of ScanFile and files opened by
+// ScanFilePath and StreamScanFile), while other readers are sent exactly once.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *Client) {
+		c.retry = &policy
+	}
+}
+
+// WithBalancer sets how NewClientWithEndpoints spreads requests across endpoints
+// (default: RoundRobin). It has no effect on single-endpoint clients.
+func WithBalancer(strategy BalancingStrategy) ClientOption {
+	return func(c *Client) {
+		c.balancer = strategy
+	}
+}
+
+// WithEndpointHealthInterval sets how often NewClientWithEndpoints probes each endpoint
+// with a health check to eject unhealthy endpoints and re-admit recovered ones
+// (default: 10s). Non-positive durations are ignored (no-op).
+func WithEndpointHealthInterval(d time.Duration) ClientOption {
+	return func(c *Client) {
+		if d > 0 {
+			c.healthInterval = d
+		}
+	}
+}
+
+// WithBatchConcurrency sets how many scans ScanMultiple and ScanMultipleReaders run
+// at once (default: 4). Non-positive values are ignored (no-op).
+func WithBatchConcurrency(n int) ClientOption {
+	return func(c *Client) {
+		if n > 0 {
+			c.batchConcurrency = n
+		}
+	}
+}
+
+// WithChunkedStreamScan makes StreamScanReader send readers of unknown size to the
+// stream-scan endpoint with chunked transfer encoding instead of spooling them first.
+// Only enable it if the server accepts requests without a Content-Length.
+func WithChunkedStreamScan(enabled bool) ClientOption {
+	return func(c *Client) {
+		c.chunkedStreamScan = enabled
+	}
+}
+
+// WithSpillThreshold sets how many bytes of a reader of unknown size StreamScanReader
+// keeps in memory before spilling it to a temporary file (default: 8MB).
+// Negative values are ignored (no-op); zero always uses a temporary file.
+func WithSpillThreshold(n int64) ClientOption {
+	return func(c *Client) {
+		if n >= 0 {
+			c.spillThreshold = n
+		}
+	}
+}
+
+// WithTempDir sets the directory for the temporary files of StreamScanReader
+// (default: os.TempDir()).
+func WithTempDir(dir string) ClientOption {
+	return func(c *Client) {
+		c.tempDir = dir
+	}
+}
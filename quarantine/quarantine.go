@@ -0,0 +1,395 @@
+// Package quarantine moves infected files into a quarantine directory, so every service
+// handles a FOUND verdict the same way: the file disappears from its original location,
+// is kept with restrictive permissions, and can be listed, restored or purged later.
+//
+// Each quarantined file is stored as <id>.bin next to a JSON sidecar, <id>.json, that
+// records where the file came from and why it was quarantined:
+//
+//	store, err := quarantine.Open("/var/lib/clamav/quarantine", quarantine.WithScannerVersion(v.Version))
+//	...
+//	result, err := client.ScanFilePath(ctx, path)
+//	if err == nil && result.IsInfected() {
+//	    entry, err := store.Quarantine(path, result)
+//	    ...
+//	}
+//
+// Store.OnInfected can be used as clamav.DirScanOptions.OnInfected to quarantine
+// infected files during a directory scan.
+package quarantine
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	clamav "github.com/DevHatRo/clamav-api-sdk-go"
+)
+
+const (
+	dirPerm  = 0o700
+	filePerm = 0o600
+
+	dataExt    = ".bin"
+	sidecarExt = ".json"
+)
+
+// Entry describes a quarantined file. It is the content of the JSON sidecar.
+type Entry struct {
+	// ID identifies the entry in the store.
+	ID string `json:"id"`
+	// OriginalPath is the absolute path the file was moved from.
+	OriginalPath string `json:"original_path"`
+	// SHA256 is the hex-encoded SHA-256 digest of the file content.
+	SHA256 string `json:"sha256"`
+	// Size is the file size in bytes.
+	Size int64 `json:"size"`
+	// Mode is the original permission bits, applied again on restore.
+	Mode fs.FileMode `json:"mode"`
+	// Signature is the name of the detected malware.
+	Signature string `json:"signature"`
+	// QuarantinedAt is when the file was quarantined.
+	QuarantinedAt time.Time `json:"quarantined_at"`
+	// ScannerVersion is the version of the scanner that reported the file, if known.
+	ScannerVersion string `json:"scanner_version,omitempty"`
+}
+
+// Option configures a Store.
+type Option func(*Store)
+
+// WithScannerVersion sets the scanner version recorded in new entries, e.g. the
+// Version field of the ClamAV API's VersionResult.
+func WithScannerVersion(version string) Option {
+	return func(s *Store) {
+		s.scannerVersion = version
+	}
+}
+
+// Store is a quarantine directory. It is safe for concurrent use, including by
+// several processes sharing the directory.
+type Store struct {
+	dir            string
+	scannerVersion string
+	now            func() time.Time
+}
+
+// Open returns the Store in dir. The directory is created if needed, and its
+// permissions are restricted to the owner.
+func Open(dir string, opts ...Option) (*Store, error) {
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		return nil, clamav.NewValidationError("failed to create quarantine directory", err)
+	}
+	if err := os.Chmod(dir, dirPerm); err != nil {
+		return nil, clamav.NewValidationError("failed to restrict quarantine directory permissions", err)
+	}
+
+	s := &Store{dir: dir, now: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
+}
+
+// Dir returns the quarantine directory.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Quarantine moves the regular file at path into the store, recording the signature
+// from result. The file is renamed when the store is on the same file system, which is
+// atomic; otherwise it is copied into the store and then removed. If any step fails,
+// the file is left at, or moved back to, its original path.
+func (s *Store) Quarantine(path string, result *clamav.ScanResult) (*Entry, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, clamav.NewValidationError(fmt.Sprintf("invalid path: %s", path), err)
+	}
+	info, err := os.Lstat(abs)
+	if err != nil {
+		return nil, clamav.NewValidationError(fmt.Sprintf("failed to stat file: %s", path), err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, clamav.NewValidationError(fmt.Sprintf("not a regular file: %s", path), nil)
+	}
+
+	id, err := newID()
+	if err != nil {
+		return nil, clamav.NewValidationError("failed to generate quarantine ID", err)
+	}
+	entry := &Entry{
+		ID:             id,
+		OriginalPath:   abs,
+		Mode:           info.Mode().Perm(),
+		QuarantinedAt:  s.now().UTC(),
+		ScannerVersion: s.scannerVersion,
+	}
+	if result != nil {
+		entry.Signature = result.Message
+	}
+
+	data := s.dataPath(id)
+	if err := moveFile(abs, data, false); err != nil {
+		return nil, clamav.NewValidationError(fmt.Sprintf("failed to move file to quarantine: %s", path), err)
+	}
+	err = os.Chmod(data, filePerm)
+	if err == nil {
+		entry.SHA256, entry.Size, err = hashFile(data)
+	}
+	if err == nil {
+		err = s.writeSidecar(entry)
+	}
+	if err != nil {
+		// Put the file back rather than leave an entry without a sidecar.
+		if moveErr := moveFile(data, abs, true); moveErr == nil {
+			_ = os.Chmod(abs, entry.Mode)
+		}
+		return nil, clamav.NewValidationError(fmt.Sprintf("failed to quarantine file: %s", path), err)
+	}
+	return entry, nil
+}
+
+// OnInfected quarantines the file of an infected directory scan result. It matches
+// clamav.DirScanOptions.OnInfected; r.Path must be a file system path, as with ScanDir.
+func (s *Store) OnInfected(_ context.Context, r *clamav.FileResult) error {
+	_, err := s.Quarantine(r.Path, r.Result)
+	return err
+}
+
+// List returns the entries in the store, oldest first. Sidecars that cannot be read
+// are skipped.
+func (s *Store) List() ([]*Entry, error) {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, clamav.NewValidationError("failed to read quarantine directory", err)
+	}
+
+	entries := []*Entry{}
+	for _, f := range files {
+		id, ok := strings.CutSuffix(f.Name(), sidecarExt)
+		if !ok || f.IsDir() || !validID(id) {
+			continue
+		}
+		if entry, err := s.Get(id); err == nil {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].QuarantinedAt.Before(entries[j].QuarantinedAt)
+	})
+	return entries, nil
+}
+
+// Get returns the entry with the given ID. The error wraps fs.ErrNotExist if there
+// is no such entry.
+func (s *Store) Get(id string) (*Entry, error) {
+	if !validID(id) {
+		return nil, clamav.NewValidationError(fmt.Sprintf("invalid quarantine ID: %q", id), fs.ErrNotExist)
+	}
+	data, err := os.ReadFile(s.sidecarPath(id))
+	if err != nil {
+		return nil, clamav.NewValidationError(fmt.Sprintf("quarantine entry not found: %s", id), err)
+	}
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, clamav.NewValidationError(fmt.Sprintf("invalid quarantine entry: %s", id), err)
+	}
+	return &entry, nil
+}
+
+// Restore moves the file of an entry back to dest, or to its original path if dest
+// is empty, with its original permissions, and removes the entry. It never overwrites
+// an existing file: if dest exists, the error wraps fs.ErrExist.
+func (s *Store) Restore(id, dest string) error {
+	entry, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	if dest == "" {
+		dest = entry.OriginalPath
+	}
+
+	if err := moveFile(s.dataPath(id), dest, true); err != nil {
+		return clamav.NewValidationError(fmt.Sprintf("failed to restore file: %s", dest), err)
+	}
+	if err := os.Chmod(dest, entry.Mode); err != nil {
+		return clamav.NewValidationError(fmt.Sprintf("failed to restore file permissions: %s", dest), err)
+	}
+	if err := os.Remove(s.sidecarPath(id)); err != nil {
+		return clamav.NewValidationError(fmt.Sprintf("failed to remove quarantine entry: %s", id), err)
+	}
+	return nil
+}
+
+// Purge permanently deletes the file and sidecar of an entry.
+func (s *Store) Purge(id string) error {
+	if _, err := s.Get(id); err != nil {
+		return err
+	}
+	if err := os.Remove(s.dataPath(id)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return clamav.NewValidationError(fmt.Sprintf("failed to purge quarantined file: %s", id), err)
+	}
+	if err := os.Remove(s.sidecarPath(id)); err != nil {
+		return clamav.NewValidationError(fmt.Sprintf("failed to remove quarantine entry: %s", id), err)
+	}
+	return nil
+}
+
+// PurgeOlderThan deletes the entries quarantined more than age ago and returns how
+// many were deleted.
+func (s *Store) PurgeOlderThan(age time.Duration) (int, error) {
+	entries, err := s.List()
+	if err != nil {
+		return 0, err
+	}
+	cutoff := s.now().Add(-age)
+	purged := 0
+	for _, entry := range entries {
+		if !entry.QuarantinedAt.Before(cutoff) {
+			continue
+		}
+		if err := s.Purge(entry.ID); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+func (s *Store) dataPath(id string) string {
+	return filepath.Join(s.dir, id+dataExt)
+}
+
+func (s *Store) sidecarPath(id string) string {
+	return filepath.Join(s.dir, id+sidecarExt)
+}
+
+// writeSidecar writes the JSON sidecar of entry through a temporary file, so readers
+// never see a partial entry.
+func (s *Store) writeSidecar(entry *Entry) error {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Chmod(filePerm)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.sidecarPath(entry.ID))
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+	return err
+}
+
+// moveFile moves the regular file src to dst. It renames when possible and falls back
+// to copying and removing src across file systems. With noReplace, an existing dst is
+// an error wrapping fs.ErrExist instead of being replaced.
+func moveFile(src, dst string, noReplace bool) error {
+	if noReplace {
+		// A hard link fails if dst exists, unlike a rename. Where links are not possible,
+		// copyFile refuses to replace dst as well.
+		err := os.Link(src, dst)
+		if err == nil {
+			return os.Remove(src)
+		}
+		if errors.Is(err, fs.ErrExist) {
+			return err
+		}
+	} else if err := os.Rename(src, dst); err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	if err := copyFile(src, dst); err != nil {
+		return err
+	}
+	if err := os.Remove(src); err != nil {
+		_ = os.Remove(dst)
+		return err
+	}
+	return nil
+}
+
+// copyFile copies src to dst, which must not exist, and syncs it to disk.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, filePerm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if err == nil {
+		err = out.Sync()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(dst)
+	}
+	return err
+}
+
+// hashFile returns the hex-encoded SHA-256 digest and size of a file.
+func hashFile(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// newID returns a random 128-bit hex ID.
+func newID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+// validID reports whether id has the form returned by newID, which also keeps IDs
+// from escaping the store directory.
+func validID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	for _, c := range id {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
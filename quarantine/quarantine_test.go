@@ -0,0 +1,253 @@
+package quarantine
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	clamav "github.com/DevHatRo/clamav-api-sdk-go"
+)
+
+var infected = &clamav.ScanResult{Status: "FOUND", Message: "Eicar-Test-Signature"}
+
+func openStore(t *testing.T, opts ...Option) *Store {
+	t.Helper()
+	s, err := Open(filepath.Join(t.TempDir(), "quarantine"), opts...)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	return s
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// stubScanner reports files containing "EICAR" as infected.
+type stubScanner struct{ clamav.Scanner }
+
+func (stubScanner) StreamScanReader(_ context.Context, r io.Reader, filename string, _ int64) (*clamav.ScanResult, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(string(data), "EICAR") {
+		return &clamav.ScanResult{Status: "FOUND", Message: "Eicar-Test-Signature", Filename: filename}, nil
+	}
+	return &clamav.ScanResult{Status: "OK", Filename: filename}, nil
+}
+
+// --- Quarantine tests ---
+
+func TestQuarantine(t *testing.T) {
+	s := openStore(t, WithScannerVersion("1.2.3"))
+	src := filepath.Join(t.TempDir(), "eicar.com")
+	writeFile(t, src, "EICAR")
+
+	entry, err := s.Quarantine(src, infected)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(src); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("original file still exists: %v", err)
+	}
+
+	sum := sha256.Sum256([]byte("EICAR"))
+	if entry.OriginalPath != src || entry.SHA256 != hex.EncodeToString(sum[:]) || entry.Size != 5 {
+		t.Errorf("entry = %+v", entry)
+	}
+	if entry.Signature != "Eicar-Test-Signature" || entry.ScannerVersion != "1.2.3" || entry.Mode != 0o644 {
+		t.Errorf("entry = %+v", entry)
+	}
+	if entry.QuarantinedAt.IsZero() {
+		t.Error("QuarantinedAt not set")
+	}
+
+	for path, want := range map[string]fs.FileMode{
+		s.Dir():                 0o700,
+		s.dataPath(entry.ID):    0o600,
+		s.sidecarPath(entry.ID): 0o600,
+	} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("%s: mode = %o, want %o", filepath.Base(path), got, want)
+		}
+	}
+
+	got, err := s.Get(entry.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if *got != *entry {
+		t.Errorf("sidecar = %+v, want %+v", got, entry)
+	}
+}
+
+func TestQuarantineErrors(t *testing.T) {
+	s := openStore(t)
+	dir := t.TempDir()
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := s.Quarantine(filepath.Join(dir, "missing"), infected)
+		if !clamav.IsValidationError(err) || !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("expected not-exist validation error, got: %v", err)
+		}
+	})
+
+	t.Run("directory", func(t *testing.T) {
+		_, err := s.Quarantine(dir, infected)
+		if !clamav.IsValidationError(err) {
+			t.Errorf("expected validation error, got: %v", err)
+		}
+	})
+
+	t.Run("invalid ID", func(t *testing.T) {
+		_, err := s.Get("../../etc/passwd")
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("expected not-exist error, got: %v", err)
+		}
+	})
+}
+
+func TestListRestorePurge(t *testing.T) {
+	s := openStore(t)
+	now := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	dir := t.TempDir()
+	var ids []string
+	for _, name := range []string{"a.com", "b.com", "c.com"} {
+		path := filepath.Join(dir, name)
+		writeFile(t, path, "EICAR "+name)
+		entry, err := s.Quarantine(path, infected)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, entry.ID)
+		now = now.Add(time.Hour)
+	}
+
+	entries, err := s.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(entries) != 3 || entries[0].ID != ids[0] || entries[2].ID != ids[2] {
+		t.Fatalf("List returned %d entries in the wrong order", len(entries))
+	}
+
+	t.Run("restore to original path", func(t *testing.T) {
+		if err := s.Restore(ids[0], ""); err != nil {
+			t.Fatalf("Restore: %v", err)
+		}
+		path := filepath.Join(dir, "a.com")
+		data, err := os.ReadFile(path)
+		if err != nil || string(data) != "EICAR a.com" {
+			t.Errorf("restored content = %q, %v", data, err)
+		}
+		if info, _ := os.Stat(path); info.Mode().Perm() != 0o644 {
+			t.Errorf("restored mode = %o, want 644", info.Mode().Perm())
+		}
+		if _, err := s.Get(ids[0]); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("entry still present after restore: %v", err)
+		}
+	})
+
+	t.Run("restore never overwrites", func(t *testing.T) {
+		dest := filepath.Join(dir, "a.com")
+		err := s.Restore(ids[1], dest)
+		if !errors.Is(err, fs.ErrExist) {
+			t.Errorf("expected exist error, got: %v", err)
+		}
+		if _, err := s.Get(ids[1]); err != nil {
+			t.Errorf("entry lost after failed restore: %v", err)
+		}
+	})
+
+	t.Run("purge older than", func(t *testing.T) {
+		// Entries are at 11:00 and 12:00; now is 13:00.
+		n, err := s.PurgeOlderThan(90 * time.Minute)
+		if err != nil || n != 1 {
+			t.Fatalf("PurgeOlderThan = %d, %v, want 1", n, err)
+		}
+		if _, err := os.Stat(s.dataPath(ids[1])); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("purged file still exists: %v", err)
+		}
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		if err := s.Purge(ids[2]); err != nil {
+			t.Fatalf("Purge: %v", err)
+		}
+		if err := s.Purge(ids[2]); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("expected not-exist error for a purged entry, got: %v", err)
+		}
+		entries, err := s.List()
+		if err != nil || len(entries) != 0 {
+			t.Errorf("List = %d entries, %v, want none", len(entries), err)
+		}
+	})
+}
+
+func TestCopyFile(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "src"), filepath.Join(dir, "dst")
+	writeFile(t, src, "data")
+
+	if err := copyFile(src, dst); err != nil {
+		t.Fatalf("copyFile: %v", err)
+	}
+	if err := copyFile(src, dst); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("expected exist error when copying over a file, got: %v", err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "data" {
+		t.Errorf("copied content = %q", data)
+	}
+}
+
+func TestOnInfected(t *testing.T) {
+	s := openStore(t)
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "clean.txt"), "hello")
+	writeFile(t, filepath.Join(dir, "eicar.com"), "EICAR")
+
+	var results []*clamav.FileResult
+	summary, err := clamav.ScanDir(context.Background(), stubScanner{}, dir,
+		&clamav.DirScanOptions{OnInfected: s.OnInfected},
+		func(r *clamav.FileResult) { results = append(results, r) })
+	if err != nil {
+		t.Fatalf("ScanDir: %v", err)
+	}
+	if summary.Infected != 1 {
+		t.Errorf("summary = %+v", summary)
+	}
+	for _, r := range results {
+		if r.HookErr != nil {
+			t.Errorf("%s: HookErr = %v", r.Path, r.HookErr)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "eicar.com")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("infected file not quarantined: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "clean.txt")); err != nil {
+		t.Errorf("clean file moved: %v", err)
+	}
+	entries, _ := s.List()
+	if len(entries) != 1 || entries[0].OriginalPath != filepath.Join(dir, "eicar.com") {
+		t.Errorf("entries = %+v", entries)
+	}
+}
@@ -0,0 +1,97 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	clamav "github.com/DevHatRo/clamav-api-sdk-go"
+)
+
+// JUnit XML document types, in the common schema understood by CI test-report views.
+type (
+	junitTestSuites struct {
+		XMLName  xml.Name         `xml:"testsuites"`
+		Name     string           `xml:"name,attr"`
+		Tests    int              `xml:"tests,attr"`
+		Failures int              `xml:"failures,attr"`
+		Errors   int              `xml:"errors,attr"`
+		Time     string           `xml:"time,attr"`
+		Suites   []junitTestSuite `xml:"testsuite"`
+	}
+	junitTestSuite struct {
+		Name      string          `xml:"name,attr"`
+		Tests     int             `xml:"tests,attr"`
+		Failures  int             `xml:"failures,attr"`
+		Errors    int             `xml:"errors,attr"`
+		Time      string          `xml:"time,attr"`
+		TestCases []junitTestCase `xml:"testcase"`
+	}
+	junitTestCase struct {
+		Name      string        `xml:"name,attr"`
+		ClassName string        `xml:"classname,attr"`
+		Time      string        `xml:"time,attr"`
+		Failure   *junitProblem `xml:"failure,omitempty"`
+		Error     *junitProblem `xml:"error,omitempty"`
+	}
+	junitProblem struct {
+		Message string `xml:"message,attr"`
+		Type    string `xml:"type,attr"`
+		Text    string `xml:",chardata"`
+	}
+)
+
+// WriteJUnit writes results as a JUnit XML report with one test suite.
+//
+// Each file becomes a test case named after its path: infected files are failures whose
+// message is the signature name, and files that could not be scanned are errors.
+// Test case times are the server-reported scan times.
+func WriteJUnit(w io.Writer, results []*clamav.FileResult, opts Options) error {
+	suite := junitTestSuite{Name: opts.SuiteName}
+	if suite.Name == "" {
+		suite.Name = defaultSuiteName
+	}
+
+	var total float64
+	for _, r := range results {
+		v := verdictOf(r, opts.BaseDir)
+		tc := junitTestCase{Name: v.path, ClassName: suite.Name, Time: seconds(v.scanTime)}
+		switch v.status {
+		case "FOUND":
+			suite.Failures++
+			tc.Failure = &junitProblem{Message: v.virus, Type: "FOUND", Text: fmt.Sprintf("%s: %s FOUND", v.path, v.virus)}
+		case "ERROR":
+			suite.Errors++
+			tc.Error = &junitProblem{Message: v.message, Type: "ERROR", Text: fmt.Sprintf("%s: %s ERROR", v.path, v.message)}
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+		total += v.scanTime
+	}
+	suite.Tests = len(suite.TestCases)
+	suite.Time = seconds(total)
+
+	doc := junitTestSuites{
+		Name:     suite.Name,
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Errors:   suite.Errors,
+		Time:     suite.Time,
+		Suites:   []junitTestSuite{suite},
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// seconds formats a duration in seconds as JUnit expects.
+func seconds(s float64) string {
+	return fmt.Sprintf("%.3f", s)
+}
@@ -0,0 +1,81 @@
+// Package report writes scan results as SARIF 2.1.0 and JUnit XML, so malware scans of
+// build artifacts show up in code-scanning and test-report views of CI systems.
+//
+// Both writers take the per-file results of clamav.ScanDir, clamav.ScanFS, or any
+// results assembled by the caller:
+//
+//	var results []*clamav.FileResult
+//	_, err := clamav.ScanDir(ctx, client, "dist", nil, func(r *clamav.FileResult) {
+//	    results = append(results, r)
+//	})
+//	...
+//	f, _ := os.Create("clamav.sarif")
+//	err = report.WriteSARIF(f, results, report.Options{BaseDir: "."})
+package report
+
+import (
+	"path/filepath"
+	"strings"
+
+	clamav "github.com/DevHatRo/clamav-api-sdk-go"
+)
+
+const (
+	defaultToolName  = "clamav-api"
+	defaultSuiteName = "clamav"
+	toolInfoURI      = "https://github.com/DevHatRo/clamav-api-sdk-go"
+)
+
+// Options configures the report writers. The zero value is ready to use.
+type Options struct {
+	// ToolName is the SARIF tool driver name (default: "clamav-api").
+	ToolName string
+	// ToolVersion is the SARIF tool driver version, e.g. the ClamAV API version.
+	ToolVersion string
+	// SuiteName is the JUnit test suite name (default: "clamav").
+	SuiteName string
+	// BaseDir, if set, makes file paths relative to it. In SARIF, relative paths are
+	// resolved against the %SRCROOT% base, which code-scanning tools map to the repository.
+	BaseDir string
+}
+
+// verdict is the outcome of one file, normalized for the writers.
+type verdict struct {
+	path     string
+	status   string // "OK", "FOUND" or "ERROR"
+	virus    string
+	message  string
+	scanTime float64
+}
+
+// verdictOf normalizes a file result.
+func verdictOf(r *clamav.FileResult, baseDir string) verdict {
+	v := verdict{path: relPath(r.Path, baseDir), status: "ERROR"}
+	switch {
+	case r.Err != nil:
+		v.message = r.Err.Error()
+	case r.Result == nil:
+		v.message = "no scan result"
+	default:
+		v.status, v.scanTime = string(r.Result.Status), r.Result.ScanTime
+		switch r.Result.Status {
+		case clamav.StatusFound:
+			v.virus = r.Result.Message
+		case clamav.StatusOK:
+		default:
+			v.status, v.message = "ERROR", r.Result.Message
+		}
+	}
+	return v
+}
+
+// relPath returns path relative to baseDir with forward slashes, or path unchanged if
+// it is not below baseDir.
+func relPath(path, baseDir string) string {
+	if baseDir != "" {
+		if rel, err := filepath.Rel(baseDir, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			path = rel
+		}
+	}
+	return filepath.ToSlash(path)
+}
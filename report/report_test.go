@@ -0,0 +1,127 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	clamav "github.com/DevHatRo/clamav-api-sdk-go"
+)
+
+func sampleResults() []*clamav.FileResult {
+	return []*clamav.FileResult{
+		{Path: "/repo/dist/app.tar", Result: &clamav.ScanResult{Status: "OK", ScanTime: 0.25}},
+		{Path: "/repo/dist/eicar.com", Result: &clamav.ScanResult{Status: "FOUND", Message: "Eicar-Test-Signature", ScanTime: 0.5}},
+		{Path: "/repo/dist/eicar2.com", Result: &clamav.ScanResult{Status: "FOUND", Message: "Eicar-Test-Signature"}},
+		{Path: "/repo/dist/big.iso", Err: clamav.NewServiceError("file too large", 413, nil)},
+	}
+}
+
+// --- SARIF tests ---
+
+func TestWriteSARIF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteSARIF(&buf, sampleResults(), Options{ToolVersion: "1.2.3", BaseDir: "/repo"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("version = %q, runs = %d", log.Version, len(log.Runs))
+	}
+	run := log.Runs[0]
+
+	if run.Tool.Driver.Name != "clamav-api" || run.Tool.Driver.Version != "1.2.3" {
+		t.Errorf("driver = %+v", run.Tool.Driver)
+	}
+	if len(run.Tool.Driver.Rules) != 1 || run.Tool.Driver.Rules[0].ID != "Eicar-Test-Signature" {
+		t.Errorf("rules = %+v, want one rule per signature", run.Tool.Driver.Rules)
+	}
+	if len(run.Results) != 2 {
+		t.Fatalf("got %d results, want 2", len(run.Results))
+	}
+	res := run.Results[0]
+	loc := res.Locations[0].PhysicalLocation.ArtifactLocation
+	if res.RuleID != "Eicar-Test-Signature" || res.RuleIndex != 0 || res.Level != "error" {
+		t.Errorf("result = %+v", res)
+	}
+	if loc.URI != "dist/eicar.com" || loc.URIBaseID != "%SRCROOT%" {
+		t.Errorf("location = %+v", loc)
+	}
+
+	inv := run.Invocations[0]
+	if inv.ExecutionSuccessful || len(inv.ToolExecutionNotifications) != 1 {
+		t.Errorf("invocation = %+v, want one error notification", inv)
+	}
+}
+
+func TestWriteSARIFClean(t *testing.T) {
+	var buf bytes.Buffer
+	results := []*clamav.FileResult{{Path: "/tmp/a b.txt", Result: &clamav.ScanResult{Status: "OK"}}}
+	if err := WriteSARIF(&buf, results, Options{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, `"results": []`) || !strings.Contains(out, `"executionSuccessful": true`) {
+		t.Errorf("unexpected SARIF for a clean scan:\n%s", out)
+	}
+	if got := artifactLocation("/tmp/a b.txt").URI; got != "file:///tmp/a%20b.txt" {
+		t.Errorf("URI = %q, want %q", got, "file:///tmp/a%20b.txt")
+	}
+}
+
+// --- JUnit tests ---
+
+func TestWriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJUnit(&buf, sampleResults(), Options{SuiteName: "artifacts", BaseDir: "/repo"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), xml.Header) {
+		t.Error("missing XML header")
+	}
+
+	var doc junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid XML: %v", err)
+	}
+	if doc.Tests != 4 || doc.Failures != 2 || doc.Errors != 1 || doc.Time != "0.750" {
+		t.Errorf("testsuites = tests %d, failures %d, errors %d, time %s", doc.Tests, doc.Failures, doc.Errors, doc.Time)
+	}
+
+	suite := doc.Suites[0]
+	if suite.Name != "artifacts" || len(suite.TestCases) != 4 {
+		t.Fatalf("suite = %q with %d cases", suite.Name, len(suite.TestCases))
+	}
+	if tc := suite.TestCases[0]; tc.Name != "dist/app.tar" || tc.Failure != nil || tc.Error != nil {
+		t.Errorf("clean case = %+v", tc)
+	}
+	if tc := suite.TestCases[1]; tc.Failure == nil || tc.Failure.Message != "Eicar-Test-Signature" {
+		t.Errorf("infected case = %+v", tc)
+	}
+	if tc := suite.TestCases[3]; tc.Error == nil || !strings.Contains(tc.Error.Message, "file too large") {
+		t.Errorf("error case = %+v", tc)
+	}
+}
+
+func TestRelPath(t *testing.T) {
+	tests := []struct {
+		path, base, want string
+	}{
+		{"/repo/a/b.txt", "/repo", "a/b.txt"},
+		{"/other/b.txt", "/repo", "/other/b.txt"},
+		{"rel/b.txt", "", "rel/b.txt"},
+		{"/repo/..b.txt", "/repo", "..b.txt"},
+	}
+	for _, tt := range tests {
+		if got := relPath(tt.path, tt.base); got != tt.want {
+			t.Errorf("relPath(%q, %q) = %q, want %q", tt.path, tt.base, got, tt.want)
+		}
+	}
+}
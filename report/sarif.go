@@ -0,0 +1,154 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+
+	clamav "github.com/DevHatRo/clamav-api-sdk-go"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifSrcRoot = "%SRCROOT%"
+)
+
+// SARIF 2.1.0 document types; only the properties written by WriteSARIF are modeled.
+type (
+	sarifLog struct {
+		Version string     `json:"version"`
+		Schema  string     `json:"$schema"`
+		Runs    []sarifRun `json:"runs"`
+	}
+	sarifRun struct {
+		Tool        sarifTool         `json:"tool"`
+		Invocations []sarifInvocation `json:"invocations"`
+		Results     []sarifResult     `json:"results"`
+	}
+	sarifTool struct {
+		Driver sarifDriver `json:"driver"`
+	}
+	sarifDriver struct {
+		Name           string      `json:"name"`
+		Version        string      `json:"version,omitempty"`
+		InformationURI string      `json:"informationUri"`
+		Rules          []sarifRule `json:"rules"`
+	}
+	sarifRule struct {
+		ID                   string             `json:"id"`
+		ShortDescription     sarifMessage       `json:"shortDescription"`
+		DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+		Properties           sarifProperties    `json:"properties"`
+	}
+	sarifConfiguration struct {
+		Level string `json:"level"`
+	}
+	sarifProperties struct {
+		Tags []string `json:"tags"`
+	}
+	sarifInvocation struct {
+		ExecutionSuccessful        bool                `json:"executionSuccessful"`
+		ToolExecutionNotifications []sarifNotification `json:"toolExecutionNotifications,omitempty"`
+	}
+	sarifNotification struct {
+		Level     string          `json:"level"`
+		Message   sarifMessage    `json:"message"`
+		Locations []sarifLocation `json:"locations"`
+	}
+	sarifResult struct {
+		RuleID    string          `json:"ruleId"`
+		RuleIndex int             `json:"ruleIndex"`
+		Level     string          `json:"level"`
+		Message   sarifMessage    `json:"message"`
+		Locations []sarifLocation `json:"locations"`
+	}
+	sarifMessage struct {
+		Text string `json:"text"`
+	}
+	sarifLocation struct {
+		PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	}
+	sarifPhysicalLocation struct {
+		ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	}
+	sarifArtifactLocation struct {
+		URI       string `json:"uri"`
+		URIBaseID string `json:"uriBaseId,omitempty"`
+	}
+)
+
+// WriteSARIF writes results as a SARIF 2.1.0 log with one run.
+//
+// Each infected file becomes a result at level "error" whose rule ID is the signature
+// name; every distinct signature is declared once as a rule. Clean files produce no
+// result. Files that could not be scanned are reported as tool execution notifications,
+// and mark the invocation as unsuccessful.
+func WriteSARIF(w io.Writer, results []*clamav.FileResult, opts Options) error {
+	driver := sarifDriver{
+		Name:           opts.ToolName,
+		Version:        opts.ToolVersion,
+		InformationURI: toolInfoURI,
+		Rules:          []sarifRule{},
+	}
+	if driver.Name == "" {
+		driver.Name = defaultToolName
+	}
+
+	run := sarifRun{
+		Tool:        sarifTool{Driver: driver},
+		Invocations: []sarifInvocation{{ExecutionSuccessful: true}},
+		Results:     []sarifResult{},
+	}
+	ruleIndex := map[string]int{}
+
+	for _, r := range results {
+		v := verdictOf(r, opts.BaseDir)
+		loc := []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: artifactLocation(v.path)}}}
+
+		switch v.status {
+		case "FOUND":
+			idx, ok := ruleIndex[v.virus]
+			if !ok {
+				idx = len(run.Tool.Driver.Rules)
+				ruleIndex[v.virus] = idx
+				run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+					ID:                   v.virus,
+					ShortDescription:     sarifMessage{Text: fmt.Sprintf("Malware signature %s", v.virus)},
+					DefaultConfiguration: sarifConfiguration{Level: "error"},
+					Properties:           sarifProperties{Tags: []string{"security", "malware"}},
+				})
+			}
+			run.Results = append(run.Results, sarifResult{
+				RuleID:    v.virus,
+				RuleIndex: idx,
+				Level:     "error",
+				Message:   sarifMessage{Text: fmt.Sprintf("%s is infected with %s", v.path, v.virus)},
+				Locations: loc,
+			})
+		case "ERROR":
+			inv := &run.Invocations[0]
+			inv.ExecutionSuccessful = false
+			inv.ToolExecutionNotifications = append(inv.ToolExecutionNotifications, sarifNotification{
+				Level:     "error",
+				Message:   sarifMessage{Text: fmt.Sprintf("%s could not be scanned: %s", v.path, v.message)},
+				Locations: loc,
+			})
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{Version: sarifVersion, Schema: sarifSchema, Runs: []sarifRun{run}})
+}
+
+// artifactLocation returns the SARIF location of a slash-separated path: a file URI
+// for absolute paths, or a relative URI resolved against %SRCROOT%.
+func artifactLocation(p string) sarifArtifactLocation {
+	if path.IsAbs(p) {
+		return sarifArtifactLocation{URI: (&url.URL{Scheme: "file", Path: p}).String()}
+	}
+	return sarifArtifactLocation{URI: (&url.URL{Path: p}).String(), URIBaseID: sarifSrcRoot}
+}
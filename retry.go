@@ -0,0 +1,256 @@
+package clamav
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/DevHatRo/clamav-api-sdk-go/internal/readerutil"
+)
+
+const (
+	defaultRetryMaxAttempts    = 3
+	defaultRetryInitialBackoff = 100 * time.Millisecond
+	defaultRetryMaxBackoff     = 2 * time.Second
+	defaultRetryMultiplier     = 2.0
+	defaultRetryJitter         = 0.2
+)
+
+// RetryPolicy configures automatic retries with exponential backoff and jitter.
+// It is used by WithRetryPolicy on the REST, gRPC and clamd clients.
+//
+// Zero InitialBackoff, MaxBackoff, Multiplier and Jitter fall back to the defaults of
+// DefaultRetryPolicy, so RetryPolicy{MaxAttempts: 5} retries with jitter. MaxAttempts
+// below 2 disables retries.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts.
+	MaxBackoff time.Duration
+	// Multiplier is the factor by which the delay grows after each attempt.
+	Multiplier float64
+	// Jitter is the fraction (0 to 1) of each delay that is randomized,
+	// so that many clients do not retry in lockstep. A negative value disables it.
+	Jitter float64
+	// PerAttemptTimeout bounds each individual attempt. Zero means attempts are
+	// only bounded by the caller's context and the client timeout.
+	PerAttemptTimeout time.Duration
+	// Retryable reports whether a failed attempt should be retried.
+	// If nil, IsRetryableError is used.
+	Retryable func(error) bool
+}
+
+// DefaultRetryPolicy returns a policy with 3 attempts, 100ms initial backoff doubling
+// up to 2s, 20% jitter and IsRetryableError as the predicate.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    defaultRetryMaxAttempts,
+		InitialBackoff: defaultRetryInitialBackoff,
+		MaxBackoff:     defaultRetryMaxBackoff,
+		Multiplier:     defaultRetryMultiplier,
+		Jitter:         defaultRetryJitter,
+		Retryable:      IsRetryableError,
+	}
+}
+
+// IsRetryableError reports whether err is likely transient: connection errors,
+// timeouts that were not caused by the caller canceling the request, and service
+// errors with status 429, 502, 503 or 504.
+func IsRetryableError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if IsConnectionError(err) || IsTimeoutError(err) {
+		return true
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return isRetryableStatus(e.StatusCode)
+	}
+	return false
+}
+
+// Do calls fn until it succeeds, returns a non-retryable error, the attempts are
+// exhausted or ctx is done. Each call receives a context bounded by PerAttemptTimeout.
+// The error of the last attempt is returned. A nil policy calls fn exactly once.
+func (p *RetryPolicy) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	attempts := p.attempts()
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := p.attemptContext(ctx)
+		err := fn(attemptCtx)
+		cancel()
+
+		if err == nil || attempt >= attempts || ctx.Err() != nil || !p.shouldRetry(err) {
+			return err
+		}
+		if p.wait(ctx, attempt) != nil {
+			return err
+		}
+	}
+}
+
+// attempts returns the total number of attempts allowed by the policy.
+func (p *RetryPolicy) attempts() int {
+	if p == nil || p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// shouldRetry applies the configured predicate, defaulting to IsRetryableError.
+func (p *RetryPolicy) shouldRetry(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryableError(err)
+}
+
+// attemptContext derives the context for a single attempt.
+func (p *RetryPolicy) attemptContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p == nil || p.PerAttemptTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, p.PerAttemptTimeout)
+}
+
+// backoff returns the jittered delay after the given failed attempt (1-based).
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	initial := p.InitialBackoff
+	if initial <= 0 {
+		initial = defaultRetryInitialBackoff
+	}
+	maxBackoff := p.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultRetryMaxBackoff
+	}
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = defaultRetryMultiplier
+	}
+
+	d := float64(initial)
+	for i := 1; i < attempt && d < float64(maxBackoff); i++ {
+		d *= multiplier
+	}
+	if d > float64(maxBackoff) {
+		d = float64(maxBackoff)
+	}
+
+	jitter := p.Jitter
+	switch {
+	case jitter == 0:
+		jitter = defaultRetryJitter
+	case jitter > 1:
+		jitter = 1
+	}
+	if jitter > 0 {
+		d -= d * jitter * rand.Float64()
+	}
+	return time.Duration(d)
+}
+
+// wait sleeps for the backoff of the given attempt or until ctx is done.
+func (p *RetryPolicy) wait(ctx context.Context, attempt int) error {
+	timer := time.NewTimer(p.backoff(attempt))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isRetryableStatus reports whether an HTTP status code indicates a transient failure.
+func isRetryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// cancelOnClose releases a per-attempt context once the response body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close closes the body and cancels the attempt context.
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// closeNotifier signals when the transport has closed a request body, after
+// which it is safe to rewind the underlying reader.
+type closeNotifier struct {
+	io.ReadCloser
+	once   sync.Once
+	closed chan struct{}
+}
+
+// Close closes the body and signals that the transport is done with it.
+func (b *closeNotifier) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { close(b.closed) })
+	return err
+}
+
+// trackBody wraps req.Body so its closing can be awaited. It returns nil for bodiless requests.
+func trackBody(req *http.Request) *closeNotifier {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	body := &closeNotifier{ReadCloser: req.Body, closed: make(chan struct{})}
+	req.Body = body
+	return body
+}
+
+// rewindRequest prepares req for another attempt by replacing its body with a
+// fresh one from req.GetBody, once the transport has released the previous body.
+func rewindRequest(ctx context.Context, req *http.Request, prev *closeNotifier) (*http.Request, error) {
+	if prev == nil {
+		return req, nil
+	}
+
+	select {
+	case <-prev.closed:
+	case <-ctx.Done():
+		return nil, NewTimeoutError("request canceled while waiting to retry", ctx.Err())
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, NewValidationError("failed to rewind request body", err)
+	}
+
+	next := req.Clone(ctx)
+	next.Body = body
+	return next, nil
+}
+
+// seekBody returns a GetBody function that rewinds r to its current offset, or nil
+// if r is not an io.Seeker. The returned bodies do not close r.
+func seekBody(r io.Reader) func() (io.ReadCloser, error) {
+	rewind, ok := readerutil.Rewinder(r)
+	if !ok {
+		return nil
+	}
+	return func() (io.ReadCloser, error) {
+		if err := rewind(); err != nil {
+			return nil, err
+		}
+		return io.NopCloser(r), nil
+	}
+}
@@ -0,0 +1,296 @@
+package clamav
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DevHatRo/clamav-api-sdk-go/internal/testutil"
+)
+
+// testRetryPolicy returns a fast retry policy for tests.
+func testRetryPolicy(attempts int) RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    attempts,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+	}
+}
+
+// flakyHandler fails the first n requests with 503 and then delegates to next.
+// The body of every request is recorded in bodies.
+func flakyHandler(n int32, calls *int32, bodies *[]string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		*bodies = append(*bodies, string(data))
+		r.Body = io.NopCloser(bytes.NewReader(data))
+
+		if atomic.AddInt32(calls, 1) <= n {
+			testutil.JSONHandler(http.StatusServiceUnavailable, map[string]string{"message": "restarting"})(w, r)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"connection", NewConnectionError("refused", nil), true},
+		{"timeout", NewTimeoutError("timed out", context.DeadlineExceeded), true},
+		{"canceled", NewTimeoutError("request canceled", context.Canceled), false},
+		{"validation", NewValidationError("bad input", nil), false},
+		{"service 502", NewServiceError("bad gateway", 502, nil), true},
+		{"service 503", NewServiceError("unavailable", 503, nil), true},
+		{"service 429", NewServiceError("slow down", 429, nil), true},
+		{"service 500", NewServiceError("internal", 500, nil), false},
+		{"plain error", errors.New("boom"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryableError(tt.err); got != tt.want {
+				t.Errorf("IsRetryableError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := &RetryPolicy{
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     50 * time.Millisecond,
+		Multiplier:     2,
+		Jitter:         -1,
+	}
+
+	want := []time.Duration{10, 20, 40, 50, 50}
+	for i, w := range want {
+		if got := p.backoff(i + 1); got != w*time.Millisecond {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, got, w*time.Millisecond)
+		}
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := p.backoff(1)
+		if d < 5*time.Millisecond || d > 10*time.Millisecond {
+			t.Fatalf("jittered backoff %v outside [5ms, 10ms]", d)
+		}
+	}
+
+	// Zero jitter means the default, so clients do not retry in lockstep.
+	p.Jitter = 0
+	seen := map[time.Duration]bool{}
+	for i := 0; i < 100; i++ {
+		d := p.backoff(1)
+		if d < 8*time.Millisecond || d > 10*time.Millisecond {
+			t.Fatalf("default jittered backoff %v outside [8ms, 10ms]", d)
+		}
+		seen[d] = true
+	}
+	if len(seen) < 2 {
+		t.Error("zero Jitter should apply the default jitter")
+	}
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	t.Run("nil policy runs once", func(t *testing.T) {
+		var p *RetryPolicy
+		calls := 0
+		err := p.Do(context.Background(), func(context.Context) error {
+			calls++
+			return NewConnectionError("refused", nil)
+		})
+		if err == nil || calls != 1 {
+			t.Errorf("calls = %d, err = %v; want 1 call and an error", calls, err)
+		}
+	})
+
+	t.Run("retries until success", func(t *testing.T) {
+		p := testRetryPolicy(3)
+		calls := 0
+		err := p.Do(context.Background(), func(context.Context) error {
+			calls++
+			if calls < 3 {
+				return NewConnectionError("refused", nil)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if calls != 3 {
+			t.Errorf("calls = %d, want 3", calls)
+		}
+	})
+
+	t.Run("stops on non-retryable error", func(t *testing.T) {
+		p := testRetryPolicy(5)
+		calls := 0
+		err := p.Do(context.Background(), func(context.Context) error {
+			calls++
+			return NewValidationError("bad input", nil)
+		})
+		if !IsValidationError(err) {
+			t.Errorf("expected validation error, got: %v", err)
+		}
+		if calls != 1 {
+			t.Errorf("calls = %d, want 1", calls)
+		}
+	})
+
+	t.Run("per-attempt timeout", func(t *testing.T) {
+		p := testRetryPolicy(2)
+		p.PerAttemptTimeout = 10 * time.Millisecond
+		calls := 0
+		err := p.Do(context.Background(), func(ctx context.Context) error {
+			calls++
+			<-ctx.Done()
+			return NewTimeoutError("request timed out", ctx.Err())
+		})
+		if !IsTimeoutError(err) {
+			t.Errorf("expected timeout error, got: %v", err)
+		}
+		if calls != 2 {
+			t.Errorf("calls = %d, want 2", calls)
+		}
+	})
+}
+
+func TestClientRetry(t *testing.T) {
+	t.Run("retries 503 and replays multipart body", func(t *testing.T) {
+		var calls int32
+		var bodies []string
+		srv := testutil.NewMockServer(map[string]http.HandlerFunc{
+			"/api/scan": flakyHandler(2, &calls, &bodies, testutil.ScanHandler(func(data []byte, filename string) (int, interface{}) {
+				return http.StatusOK, testutil.CleanScanResponse()
+			})),
+		})
+		defer srv.Close()
+
+		client := mustNewClient(t, srv.URL, WithRetryPolicy(testRetryPolicy(3)))
+		defer func() { _ = client.Close() }()
+
+		result, err := client.ScanFile(context.Background(), []byte("payload"), "retry.txt")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !result.IsClean() {
+			t.Errorf("expected clean, got status %q", result.Status)
+		}
+		if calls != 3 {
+			t.Errorf("calls = %d, want 3", calls)
+		}
+		for i, b := range bodies {
+			if !strings.Contains(b, "payload") {
+				t.Errorf("attempt %d body missing payload", i+1)
+			}
+		}
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		var calls int32
+		var bodies []string
+		srv := testutil.NewMockServer(map[string]http.HandlerFunc{
+			"/api/version": flakyHandler(10, &calls, &bodies, nil),
+		})
+		defer srv.Close()
+
+		client := mustNewClient(t, srv.URL, WithRetryPolicy(testRetryPolicy(3)))
+		defer func() { _ = client.Close() }()
+
+		_, err := client.Version(context.Background())
+		if !IsServiceError(err) {
+			t.Fatalf("expected service error, got: %v", err)
+		}
+		var sdkErr *Error
+		if errors.As(err, &sdkErr) && sdkErr.Message != "unexpected status 503: restarting" {
+			t.Errorf("Message = %q", sdkErr.Message)
+		}
+		if calls != 3 {
+			t.Errorf("calls = %d, want 3", calls)
+		}
+	})
+
+	t.Run("rewinds seekable stream reader", func(t *testing.T) {
+		var calls int32
+		var bodies []string
+		srv := testutil.NewMockServer(map[string]http.HandlerFunc{
+			"/api/stream-scan": flakyHandler(1, &calls, &bodies, testutil.ScanHandler(func(data []byte, filename string) (int, interface{}) {
+				return http.StatusOK, testutil.CleanScanResponse()
+			})),
+		})
+		defer srv.Close()
+
+		client := mustNewClient(t, srv.URL, WithRetryPolicy(testRetryPolicy(3)))
+		defer func() { _ = client.Close() }()
+
+		data := []byte("seekable stream")
+		if _, err := client.StreamScan(context.Background(), bytes.NewReader(data), int64(len(data))); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if calls != 2 {
+			t.Fatalf("calls = %d, want 2", calls)
+		}
+		if bodies[1] != string(data) {
+			t.Errorf("replayed body = %q, want %q", bodies[1], data)
+		}
+	})
+
+	t.Run("does not retry non-rewindable reader", func(t *testing.T) {
+		var calls int32
+		var bodies []string
+		srv := testutil.NewMockServer(map[string]http.HandlerFunc{
+			"/api/stream-scan": flakyHandler(1, &calls, &bodies, nil),
+		})
+		defer srv.Close()
+
+		client := mustNewClient(t, srv.URL, WithRetryPolicy(testRetryPolicy(3)))
+		defer func() { _ = client.Close() }()
+
+		data := "one-shot stream"
+		reader := io.LimitReader(strings.NewReader(data), int64(len(data)))
+		_, err := client.StreamScan(context.Background(), reader, int64(len(data)))
+		if !IsServiceError(err) {
+			t.Errorf("expected service error, got: %v", err)
+		}
+		if calls != 1 {
+			t.Errorf("calls = %d, want 1", calls)
+		}
+	})
+
+	t.Run("unhealthy health check is returned after retries", func(t *testing.T) {
+		var calls int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			testutil.JSONHandler(http.StatusBadGateway, map[string]string{"message": "Clamd service unavailable"})(w, r)
+		}))
+		defer srv.Close()
+
+		client := mustNewClient(t, srv.URL, WithRetryPolicy(testRetryPolicy(2)))
+		defer func() { _ = client.Close() }()
+
+		result, err := client.HealthCheck(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.Healthy || result.Message != "Clamd service unavailable" {
+			t.Errorf("result = %+v, want unhealthy with message", result)
+		}
+		if calls != 2 {
+			t.Errorf("calls = %d, want 2", calls)
+		}
+	})
+}
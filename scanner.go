@@ -0,0 +1,35 @@
+package clamav
+
+import (
+	"context"
+	"io"
+)
+
+// UnknownSize can be passed as the size argument of StreamScanReader when the
+// length of the reader is not known in advance.
+const UnknownSize int64 = -1
+
+// Scanner is the transport-independent interface implemented by both the REST
+// client in this package and the gRPC client in the grpc sub-package.
+//
+// Code that depends on Scanner rather than a concrete client can switch
+// transports by configuration and can wrap either client with the same decorators.
+type Scanner interface {
+	// HealthCheck checks if the ClamAV service is healthy.
+	HealthCheck(ctx context.Context) (*HealthCheckResult, error)
+	// Version returns the ClamAV API server version info.
+	Version(ctx context.Context) (*VersionResult, error)
+	// ScanFile scans file data provided as a byte slice.
+	ScanFile(ctx context.Context, data []byte, filename string) (*ScanResult, error)
+	// ScanReader scans data read from r.
+	ScanReader(ctx context.Context, r io.Reader, filename string) (*ScanResult, error)
+	// ScanFilePath reads a file from disk and scans it.
+	ScanFilePath(ctx context.Context, filePath string) (*ScanResult, error)
+	// StreamScanReader streams r to the scanner without buffering it in memory where
+	// the transport allows. size is the length of r, or UnknownSize if it is not known.
+	StreamScanReader(ctx context.Context, r io.Reader, filename string, size int64) (*ScanResult, error)
+	// Close releases any resources held by the scanner.
+	Close() error
+}
+
+var _ Scanner = (*Client)(nil)
@@ -0,0 +1,124 @@
+package clamav
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	errScanFinished = errors.New("scan finished")
+	errReaderClosed = errors.New("scanning reader closed")
+)
+
+// ScanningReader passes the content of an io.Reader through while streaming it to a
+// Scanner in the same pass, and withholds io.EOF until the verdict is known.
+//
+// The final Read returns io.EOF only if the content is clean. If it is infected, the
+// final Read returns an error for which IsInfectedError is true; if the scan fails, it
+// returns the scan error. Consumers such as object-storage uploads can therefore abort
+// before committing anything that was not verified clean.
+//
+// Bytes are passed to the scanner as they are read, so a slow scanner slows down the
+// reader. Like most readers, a ScanningReader is not safe for concurrent use.
+type ScanningReader struct {
+	r    io.Reader
+	pw   *io.PipeWriter
+	done chan struct{}
+
+	// result and scanErr are written by the scan goroutine before done is closed.
+	result  *ScanResult
+	scanErr error
+
+	// err is returned by every Read after the stream has ended.
+	err error
+}
+
+// NewScanningReader returns a ScanningReader for r that scans its content with
+// s.StreamScanReader under the given file name and size (UnknownSize if not known).
+// The scan runs until the end of r, ctx is done, or Close is called.
+func NewScanningReader(ctx context.Context, s Scanner, r io.Reader, filename string, size int64) *ScanningReader {
+	pr, pw := io.Pipe()
+	sr := &ScanningReader{r: r, pw: pw, done: make(chan struct{})}
+
+	go func() {
+		defer close(sr.done)
+		sr.result, sr.scanErr = s.StreamScanReader(ctx, pr, filename, size)
+		// Unblock Read if the scanner returned without consuming the whole stream.
+		_ = pr.CloseWithError(errScanFinished)
+	}()
+
+	return sr
+}
+
+// Read reads from the underlying reader and copies the bytes to the scanner.
+// At the end of the stream it waits for the verdict before returning.
+func (sr *ScanningReader) Read(p []byte) (int, error) {
+	if sr.err != nil {
+		return 0, sr.err
+	}
+
+	n, err := sr.r.Read(p)
+	if n > 0 {
+		if _, werr := sr.pw.Write(p[:n]); werr != nil {
+			<-sr.done
+			sr.err = sr.verdict(false)
+			return n, sr.err
+		}
+	}
+
+	switch {
+	case err == io.EOF:
+		_ = sr.pw.Close()
+		<-sr.done
+		sr.err = sr.verdict(true)
+		return n, sr.err
+	case err != nil:
+		_ = sr.pw.CloseWithError(err)
+		<-sr.done
+		sr.err = err
+		return n, err
+	}
+	return n, nil
+}
+
+// Result returns the scan result once Read has returned io.EOF or an infected error,
+// and nil before the verdict is known or if the scan failed.
+func (sr *ScanningReader) Result() *ScanResult {
+	select {
+	case <-sr.done:
+		return sr.result
+	default:
+		return nil
+	}
+}
+
+// Close aborts the scan if the end of the stream has not been reached and waits for it
+// to stop. It does not close the underlying reader.
+func (sr *ScanningReader) Close() error {
+	if sr.err == nil {
+		_ = sr.pw.CloseWithError(errReaderClosed)
+		<-sr.done
+		sr.err = NewValidationError("read from closed scanning reader", errReaderClosed)
+	}
+	return nil
+}
+
+// verdict converts the scan outcome into the error returned at the end of the stream.
+// complete reports whether the scanner consumed the whole stream.
+func (sr *ScanningReader) verdict(complete bool) error {
+	switch {
+	case sr.scanErr != nil:
+		return sr.scanErr
+	case sr.result.IsInfected():
+		return NewInfectedError(fmt.Sprintf("file is infected: %s", sr.result.Message))
+	case sr.result.IsError():
+		return sr.result.Err()
+	case !sr.result.IsClean():
+		return NewServiceError(fmt.Sprintf("unexpected scan status: %s", sr.result.Status), 0, nil)
+	case !complete:
+		return NewServiceError("scanner returned before the end of the stream", 0, nil)
+	}
+	return io.EOF
+}
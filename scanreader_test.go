@@ -0,0 +1,131 @@
+package clamav
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/DevHatRo/clamav-api-sdk-go/internal/testutil"
+)
+
+// --- Scanning reader tests ---
+
+func TestScanningReader(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("clean content ends with EOF", func(t *testing.T) {
+		content := strings.Repeat("clean data ", 10000)
+		sr := NewScanningReader(ctx, infectedStub(), strings.NewReader(content), "a.txt", UnknownSize)
+
+		var out bytes.Buffer
+		if _, err := io.Copy(&out, sr); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if out.String() != content {
+			t.Error("content was not passed through unchanged")
+		}
+		if r := sr.Result(); r == nil || !r.IsClean() {
+			t.Errorf("Result() = %+v, want clean", r)
+		}
+	})
+
+	t.Run("infected content ends with infected error", func(t *testing.T) {
+		sr := NewScanningReader(ctx, infectedStub(), strings.NewReader("xx EICAR xx"), "a.com", UnknownSize)
+
+		data, err := io.ReadAll(sr)
+		if !IsInfectedError(err) {
+			t.Fatalf("expected infected error, got: %v", err)
+		}
+		if !strings.Contains(err.Error(), "Eicar-Test-Signature") {
+			t.Errorf("error %q should name the virus", err)
+		}
+		if string(data) != "xx EICAR xx" {
+			t.Errorf("data = %q", data)
+		}
+		if r := sr.Result(); r == nil || !r.IsInfected() {
+			t.Errorf("Result() = %+v, want infected", r)
+		}
+
+		// The error is sticky.
+		if _, err := sr.Read(make([]byte, 1)); !IsInfectedError(err) {
+			t.Errorf("second Read: expected infected error, got: %v", err)
+		}
+	})
+
+	t.Run("scan error", func(t *testing.T) {
+		stub := &stubScanner{scanFunc: func([]byte, string) (*ScanResult, error) {
+			return nil, NewConnectionError("connection failed", nil)
+		}}
+		sr := NewScanningReader(ctx, stub, strings.NewReader("data"), "a.txt", UnknownSize)
+		if _, err := io.ReadAll(sr); !IsConnectionError(err) {
+			t.Errorf("expected connection error, got: %v", err)
+		}
+	})
+
+	t.Run("source error", func(t *testing.T) {
+		sourceErr := errors.New("disk failure")
+		sr := NewScanningReader(ctx, infectedStub(), &errReader{data: []byte("abc"), err: sourceErr}, "a.txt", UnknownSize)
+		if _, err := io.ReadAll(sr); !errors.Is(err, sourceErr) {
+			t.Errorf("expected source error, got: %v", err)
+		}
+	})
+
+	t.Run("scanner returning early is not clean", func(t *testing.T) {
+		partial := &partialScanner{stubScanner: infectedStub()}
+		early := &earlyScanner{partial}
+		sr := NewScanningReader(ctx, early, strings.NewReader(strings.Repeat("x", 1<<20)), "a.txt", UnknownSize)
+		if _, err := io.ReadAll(sr); !IsServiceError(err) {
+			t.Errorf("expected service error, got: %v", err)
+		}
+	})
+
+	t.Run("close aborts the scan", func(t *testing.T) {
+		pr, pw := io.Pipe()
+		defer func() { _ = pw.Close() }()
+		sr := NewScanningReader(ctx, infectedStub(), pr, "a.txt", UnknownSize)
+		if err := sr.Close(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := sr.Read(make([]byte, 1)); err == nil || err == io.EOF {
+			t.Errorf("Read after Close = %v, want an error", err)
+		}
+	})
+
+	t.Run("REST stream scan", func(t *testing.T) {
+		srv := testutil.NewMockServer(map[string]http.HandlerFunc{
+			"/api/stream-scan": testutil.ScanHandler(func(data []byte, _ string) (int, interface{}) {
+				if bytes.Contains(data, []byte("EICAR")) {
+					return http.StatusOK, testutil.InfectedScanResponse()
+				}
+				return http.StatusOK, testutil.CleanScanResponse()
+			}),
+		})
+		defer srv.Close()
+		client := mustNewClient(t, srv.URL)
+		defer func() { _ = client.Close() }()
+
+		for _, tc := range []struct {
+			content  string
+			infected bool
+		}{{"clean", false}, {"EICAR", true}} {
+			sr := NewScanningReader(ctx, client, strings.NewReader(tc.content), "f", int64(len(tc.content)))
+			_, err := io.ReadAll(sr)
+			if IsInfectedError(err) != tc.infected || (!tc.infected && err != nil) {
+				t.Errorf("%q: err = %v, want infected=%v", tc.content, err, tc.infected)
+			}
+		}
+	})
+}
+
+// earlyScanner reads only part of streamed readers before returning a clean verdict.
+type earlyScanner struct {
+	*partialScanner
+}
+
+func (e *earlyScanner) StreamScanReader(ctx context.Context, r io.Reader, filename string, _ int64) (*ScanResult, error) {
+	return e.ScanReader(ctx, r, filename)
+}
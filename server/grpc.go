@@ -0,0 +1,181 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+
+	clamav "github.com/DevHatRo/clamav-api-sdk-go"
+	pb "github.com/DevHatRo/clamav-api-sdk-go/grpc/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// HealthCheck implements pb.ClamAVScannerServer.
+func (s *Server) HealthCheck(ctx context.Context, _ *pb.HealthCheckRequest) (*pb.HealthCheckResponse, error) {
+	health, err := s.engine.HealthCheck(ctx)
+	switch {
+	case err != nil:
+		return &pb.HealthCheckResponse{Status: "unhealthy", Message: err.Error()}, nil
+	case !health.Healthy:
+		return &pb.HealthCheckResponse{Status: "unhealthy", Message: health.Message}, nil
+	}
+	return &pb.HealthCheckResponse{Status: "healthy", Message: "ok"}, nil
+}
+
+// ScanFile implements pb.ClamAVScannerServer.
+func (s *Server) ScanFile(ctx context.Context, req *pb.ScanFileRequest) (*pb.ScanResponse, error) {
+	result, err := s.scan(ctx, bytes.NewReader(req.Data), req.Filename)
+	if err != nil {
+		return nil, s.grpcError(ctx, err)
+	}
+	return scanResponse(result), nil
+}
+
+// ScanStream implements pb.ClamAVScannerServer. The chunks are streamed to the engine
+// as they arrive.
+func (s *Server) ScanStream(stream pb.ClamAVScanner_ScanStreamServer) error {
+	ctx := stream.Context()
+	f := &chunkReader{recv: stream.Recv}
+	if err := f.next(); err != nil {
+		return err
+	}
+	result, err := s.scan(ctx, f, f.filename)
+	if f.err != nil {
+		return f.err
+	}
+	if err != nil {
+		return s.grpcError(ctx, err)
+	}
+	return stream.SendAndClose(scanResponse(result))
+}
+
+// ScanMultiple implements pb.ClamAVScannerServer. Each file ends with a chunk that
+// has IsLast set, and gets one response; a file that fails to scan gets a response
+// with Status "ERROR".
+func (s *Server) ScanMultiple(stream pb.ClamAVScanner_ScanMultipleServer) error {
+	ctx := stream.Context()
+	for {
+		f := &chunkReader{recv: stream.Recv}
+		if err := f.next(); err != nil {
+			return err
+		}
+		if !f.started {
+			return nil
+		}
+
+		result, err := s.scan(ctx, f, f.filename)
+		// The engine may have given up before the end of the file.
+		if derr := f.drain(); derr != nil {
+			return derr
+		}
+		resp := &pb.ScanResponse{}
+		if err != nil {
+			resp.Status = string(clamav.StatusError)
+			resp.Message = status.Convert(s.grpcError(ctx, err)).Message()
+			resp.Filename = f.filename
+		} else {
+			resp = scanResponse(result)
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+		if f.eof {
+			return nil
+		}
+	}
+}
+
+// grpcError returns the status error the gRPC client maps back to the type of err.
+func (s *Server) grpcError(ctx context.Context, err error) error {
+	msg := err.Error()
+	var cerr *clamav.Error
+	if errors.As(err, &cerr) {
+		msg = cerr.Message
+	}
+
+	switch {
+	case errors.Is(err, errTooLarge):
+		return status.Error(codes.InvalidArgument, s.tooLargeMessage())
+	case errors.Is(err, errEmpty):
+		return status.Error(codes.InvalidArgument, msg)
+	case ctx.Err() != nil:
+		return status.FromContextError(ctx.Err()).Err()
+	case clamav.IsValidationError(err):
+		return status.Error(codes.InvalidArgument, msg)
+	case clamav.IsTimeoutError(err):
+		return status.Error(codes.DeadlineExceeded, msg)
+	case clamav.IsConnectionError(err):
+		return status.Error(codes.Unavailable, msg)
+	default:
+		return status.Error(codes.Internal, msg)
+	}
+}
+
+func scanResponse(r *clamav.ScanResult) *pb.ScanResponse {
+	return &pb.ScanResponse{
+		Status:   string(r.Status),
+		Message:  r.Message,
+		ScanTime: r.ScanTime,
+		Filename: r.Filename,
+	}
+}
+
+// chunkReader reads the content of one file from a stream of chunks, up to and
+// including the chunk that has IsLast set.
+type chunkReader struct {
+	recv     func() (*pb.ScanStreamRequest, error)
+	buf      []byte
+	filename string
+	started  bool  // a chunk was received
+	done     bool  // the last chunk was received or the stream ended
+	eof      bool  // the client closed the stream
+	err      error // error receiving from the stream, other than its end
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for len(c.buf) == 0 {
+		if c.done {
+			return 0, io.EOF
+		}
+		if err := c.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, c.buf)
+	c.buf = c.buf[n:]
+	return n, nil
+}
+
+// next receives the next chunk of the file.
+func (c *chunkReader) next() error {
+	req, err := c.recv()
+	if errors.Is(err, io.EOF) {
+		c.done, c.eof = true, true
+		return nil
+	}
+	if err != nil {
+		c.done, c.err = true, err
+		return err
+	}
+	if c.filename == "" {
+		c.filename = req.Filename
+	}
+	c.started = true
+	c.buf = req.Chunk
+	c.done = req.IsLast
+	return nil
+}
+
+// drain discards the rest of the file.
+func (c *chunkReader) drain() error {
+	c.buf = nil
+	for !c.done {
+		if err := c.next(); err != nil {
+			return err
+		}
+	}
+	c.buf = nil
+	return c.err
+}
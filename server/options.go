@@ -0,0 +1,50 @@
+package server
+
+import (
+	"github.com/DevHatRo/clamav-api-sdk-go/clamd"
+	"google.golang.org/grpc"
+)
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithEngine sets the engine that scans content, replacing the default clamd engine.
+// The server does not close it. A nil engine is ignored (no-op).
+func WithEngine(e Engine) Option {
+	return func(s *Server) {
+		if e != nil {
+			s.engine = e
+		}
+	}
+}
+
+// WithClamd sets the address and options of the default clamd engine
+// (default: DefaultClamdAddress), e.g. "unix:///run/clamav/clamd.ctl" and
+// clamd.WithSessions(8). It is ignored with WithEngine. An empty address keeps the default.
+func WithClamd(address string, opts ...clamd.ClientOption) Option {
+	return func(s *Server) {
+		if address != "" {
+			s.clamdAddress = address
+		}
+		s.clamdOpts = append(s.clamdOpts, opts...)
+	}
+}
+
+// WithMaxSize sets the largest content accepted, in bytes (default: 200MB). Larger
+// content is rejected with 413 over REST and InvalidArgument over gRPC. Non-positive
+// values are ignored (no-op).
+func WithMaxSize(n int64) Option {
+	return func(s *Server) {
+		if n > 0 {
+			s.maxSize = n
+		}
+	}
+}
+
+// WithGRPCServerOptions adds options for the gRPC server, e.g. credentials or
+// interceptors.
+func WithGRPCServerOptions(opts ...grpc.ServerOption) Option {
+	return func(s *Server) {
+		s.grpcOpts = append(s.grpcOpts, opts...)
+	}
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"mime"
+	"mime/multipart"
+	"net/http"
+
+	clamav "github.com/DevHatRo/clamav-api-sdk-go"
+)
+
+// REST endpoint paths.
+const (
+	PathHealthCheck = "/api/health-check"
+	PathVersion     = "/api/version"
+	PathScan        = "/api/scan"
+	PathStreamScan  = "/api/stream-scan"
+)
+
+// statusClientClosedRequest is the non-standard status for a request the client gave
+// up on, which the REST client maps to a timeout error.
+const statusClientClosedRequest = 499
+
+func (s *Server) newHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(PathHealthCheck, handle(http.MethodGet, s.healthCheck))
+	mux.HandleFunc(PathVersion, handle(http.MethodGet, s.version))
+	mux.HandleFunc(PathScan, handle(http.MethodPost, s.scanMultipart))
+	mux.HandleFunc(PathStreamScan, handle(http.MethodPost, s.streamScan))
+	return mux
+}
+
+// handle wraps an endpoint handler with method checking.
+func handle(method string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"message": "method not allowed"})
+			return
+		}
+		h(w, r)
+	}
+}
+
+// healthCheck responds with 200 and the message "ok" if the engine is healthy, and
+// with 502 otherwise.
+func (s *Server) healthCheck(w http.ResponseWriter, r *http.Request) {
+	health, err := s.engine.HealthCheck(r.Context())
+	switch {
+	case err != nil:
+		writeJSON(w, http.StatusBadGateway, map[string]string{"message": err.Error()})
+	case !health.Healthy:
+		writeJSON(w, http.StatusBadGateway, map[string]string{"message": health.Message})
+	default:
+		writeJSON(w, http.StatusOK, map[string]string{"message": "ok"})
+	}
+}
+
+func (s *Server) version(w http.ResponseWriter, r *http.Request) {
+	version, err := s.engine.Version(r.Context())
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, version)
+}
+
+// scanMultipart serves multipart uploads with a "file" part, which is streamed to
+// the engine.
+func (s *Server) scanMultipart(w http.ResponseWriter, r *http.Request) {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": "Provide a single file"})
+		return
+	}
+
+	mr := multipart.NewReader(r.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"message": "Provide a single file"})
+			return
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+		result, err := s.scan(r.Context(), part, part.FileName())
+		if err != nil {
+			s.writeError(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+}
+
+// streamScan serves raw bodies, with or without a Content-Length.
+func (s *Server) streamScan(w http.ResponseWriter, r *http.Request) {
+	if r.ContentLength > s.maxSize {
+		s.writeError(w, r, errTooLarge)
+		return
+	}
+	result, err := s.scan(r.Context(), r.Body, "")
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// writeError responds with the status the REST client maps back to the type of err.
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	code, msg := http.StatusInternalServerError, err.Error()
+	var cerr *clamav.Error
+	if errors.As(err, &cerr) {
+		msg = cerr.Message
+	}
+
+	switch {
+	case errors.Is(err, errTooLarge):
+		code, msg = http.StatusRequestEntityTooLarge, s.tooLargeMessage()
+	case errors.Is(err, errEmpty):
+		code = http.StatusBadRequest
+	case errors.Is(r.Context().Err(), context.Canceled):
+		code, msg = statusClientClosedRequest, "request canceled"
+	case clamav.IsValidationError(err):
+		code = http.StatusBadRequest
+	case clamav.IsTimeoutError(err):
+		code = http.StatusGatewayTimeout
+	case clamav.IsConnectionError(err), clamav.IsServiceError(err):
+		code = http.StatusBadGateway
+	}
+	writeJSON(w, code, map[string]string{"message": msg})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
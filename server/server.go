@@ -0,0 +1,287 @@
+// Package server is a reference implementation of the ClamAV API, for embedding
+// virus scanning into your own binaries.
+//
+// A Server implements pb.ClamAVScannerServer and the four REST endpoints
+// (/api/health-check, /api/version, /api/scan and /api/stream-scan) with the same JSON
+// shapes the clients of this SDK decode. Scanning is delegated to an Engine; by
+// default the server talks to clamd with the clamd package:
+//
+//	srv, err := server.New(server.WithClamd("unix:///run/clamav/clamd.ctl"))
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	go func() {
+//	    if err := srv.ListenAndServe(":6000", ":9000"); err != nil {
+//	        log.Fatal(err)
+//	    }
+//	}()
+//	...
+//	_ = srv.Shutdown(ctx) // stop accepting, wait for scans in flight
+//
+// Content larger than the maximum size (see WithMaxSize) is rejected with 413 over
+// REST and InvalidArgument over gRPC, which the SDK clients report as validation errors.
+// Handler and the pb.ClamAVScannerServer methods can also be mounted on an existing
+// HTTP mux or gRPC server.
+//
+// This package is a separate module because it depends on google.golang.org/grpc.
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	clamav "github.com/DevHatRo/clamav-api-sdk-go"
+	"github.com/DevHatRo/clamav-api-sdk-go/clamd"
+	pb "github.com/DevHatRo/clamav-api-sdk-go/grpc/proto"
+	"google.golang.org/grpc"
+)
+
+const (
+	// DefaultClamdAddress is the clamd the default engine connects to.
+	DefaultClamdAddress = "localhost:3310"
+
+	defaultMaxSize    = 200 * 1024 * 1024 // 200MB, as the ClamAV API
+	readHeaderTimeout = 10 * time.Second
+	// grpcMessageOverhead is added to the maximum size for the gRPC message size
+	// limit, so that ScanFile requests up to the maximum size are not rejected.
+	grpcMessageOverhead = 64 * 1024
+)
+
+var (
+	// errTooLarge is returned by limitReader once the content exceeds the maximum size.
+	errTooLarge = errors.New("file too large")
+	// errEmpty is returned for requests without content.
+	errEmpty = errors.New("file data is required")
+)
+
+// Engine scans content for the server. clamd.Client implements it, and so do the REST
+// and gRPC clients, e.g. to run the server as a proxy. Implementations must be safe
+// for concurrent use.
+type Engine interface {
+	// HealthCheck reports whether the engine can scan.
+	HealthCheck(ctx context.Context) (*clamav.HealthCheckResult, error)
+	// Version returns the version reported by /api/version.
+	Version(ctx context.Context) (*clamav.VersionResult, error)
+	// ScanReader scans the content read from r.
+	ScanReader(ctx context.Context, r io.Reader, filename string) (*clamav.ScanResult, error)
+}
+
+// Server serves the ClamAV API over REST and gRPC. It is safe for concurrent use.
+type Server struct {
+	pb.UnimplementedClamAVScannerServer
+
+	engine       Engine
+	ownsEngine   bool
+	maxSize      int64
+	clamdAddress string
+	clamdOpts    []clamd.ClientOption
+	grpcOpts     []grpc.ServerOption
+
+	handler    http.Handler
+	httpServer *http.Server
+	grpcServer *grpc.Server
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+var _ pb.ClamAVScannerServer = (*Server)(nil)
+
+// New creates a server. Without WithEngine, it scans with a clamd client for
+// DefaultClamdAddress or the address set with WithClamd; no connection is made until
+// the first request.
+func New(opts ...Option) (*Server, error) {
+	s := &Server{
+		maxSize:      defaultMaxSize,
+		clamdAddress: DefaultClamdAddress,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.engine == nil {
+		client, err := clamd.NewClient(s.clamdAddress, s.clamdOpts...)
+		if err != nil {
+			return nil, err
+		}
+		s.engine, s.ownsEngine = client, true
+	}
+
+	s.handler = s.newHandler()
+	s.httpServer = &http.Server{
+		Handler:           s.handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+	grpcOpts := append([]grpc.ServerOption{
+		grpc.MaxRecvMsgSize(int(min(s.maxSize+grpcMessageOverhead, math.MaxInt32))),
+	}, s.grpcOpts...)
+	s.grpcServer = grpc.NewServer(grpcOpts...)
+	pb.RegisterClamAVScannerServer(s.grpcServer, s)
+	return s, nil
+}
+
+// Handler returns the handler serving the REST endpoints, e.g. to mount them on an
+// existing mux.
+func (s *Server) Handler() http.Handler {
+	return s.handler
+}
+
+// ListenAndServe listens on the TCP addresses httpAddr for the REST API and grpcAddr
+// for the gRPC API, and calls Serve. An empty address disables that API.
+func (s *Server) ListenAndServe(httpAddr, grpcAddr string) error {
+	var httpLis, grpcLis net.Listener
+	var err error
+	if httpAddr != "" {
+		if httpLis, err = net.Listen("tcp", httpAddr); err != nil {
+			return err
+		}
+	}
+	if grpcAddr != "" {
+		if grpcLis, err = net.Listen("tcp", grpcAddr); err != nil {
+			if httpLis != nil {
+				_ = httpLis.Close()
+			}
+			return err
+		}
+	}
+	return s.Serve(httpLis, grpcLis)
+}
+
+// Serve serves the REST API on httpLis and the gRPC API on grpcLis; either may be nil.
+// It blocks until Shutdown or Close is called, and then returns nil. If either API
+// fails, the other one is stopped too and the error is returned.
+func (s *Server) Serve(httpLis, grpcLis net.Listener) error {
+	if httpLis == nil && grpcLis == nil {
+		return errors.New("server: no listener")
+	}
+
+	errc := make(chan error, 2)
+	n := 0
+	if httpLis != nil {
+		n++
+		go func() {
+			err := s.httpServer.Serve(httpLis)
+			if errors.Is(err, http.ErrServerClosed) {
+				err = nil
+			}
+			errc <- err
+		}()
+	}
+	if grpcLis != nil {
+		n++
+		go func() {
+			err := s.grpcServer.Serve(grpcLis)
+			if errors.Is(err, grpc.ErrServerStopped) {
+				err = nil
+			}
+			errc <- err
+		}()
+	}
+
+	var first error
+	for i := 0; i < n; i++ {
+		if err := <-errc; err != nil && first == nil {
+			first = err
+			_ = s.Close()
+		}
+	}
+	return first
+}
+
+// Shutdown stops the server gracefully: it stops accepting connections and waits for
+// requests in flight until ctx is done, when the remaining ones are aborted. It then
+// closes the default clamd engine.
+func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.httpServer.Shutdown(ctx)
+
+	stopped := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.grpcServer.Stop()
+		<-stopped
+		if err == nil {
+			err = ctx.Err()
+		}
+	}
+
+	if cerr := s.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Close stops the server immediately, aborting requests in flight, and closes the
+// default clamd engine.
+func (s *Server) Close() error {
+	s.closeOnce.Do(func() {
+		s.closeErr = s.httpServer.Close()
+		s.grpcServer.Stop()
+		if c, ok := s.engine.(io.Closer); ok && s.ownsEngine {
+			if err := c.Close(); s.closeErr == nil {
+				s.closeErr = err
+			}
+		}
+	})
+	return s.closeErr
+}
+
+// scan scans r with the engine. It fails with errTooLarge if r is longer than the
+// maximum size and with errEmpty if r is empty.
+func (s *Server) scan(ctx context.Context, r io.Reader, filename string) (*clamav.ScanResult, error) {
+	br := bufio.NewReader(&limitReader{r: r, n: s.maxSize})
+	if _, err := br.Peek(1); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errEmpty
+		}
+		return nil, err
+	}
+
+	result, err := s.engine.ScanReader(ctx, br, filename)
+	if err != nil {
+		return nil, err
+	}
+	if result.Filename == "" {
+		result.Filename = filename
+	}
+	return result, nil
+}
+
+// tooLargeMessage is the error message for content larger than the maximum size.
+func (s *Server) tooLargeMessage() string {
+	return fmt.Sprintf("file size exceeds the maximum of %d bytes", s.maxSize)
+}
+
+// limitReader reads from r and fails with errTooLarge once more than n bytes were read.
+type limitReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, errTooLarge
+	}
+	// Read at most one byte past the limit, to detect that it was exceeded.
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, errTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,415 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	clamav "github.com/DevHatRo/clamav-api-sdk-go"
+	"github.com/DevHatRo/clamav-api-sdk-go/clamavtest"
+	"github.com/DevHatRo/clamav-api-sdk-go/clamd"
+	clamavgrpc "github.com/DevHatRo/clamav-api-sdk-go/grpc"
+	"github.com/DevHatRo/clamav-api-sdk-go/internal/fakeclamd"
+)
+
+// testServer is a Server serving both APIs on loopback ports, with clients for each.
+type testServer struct {
+	*Server
+	baseURL string
+	rest    *clamav.Client
+	grpc    *clamavgrpc.Client
+	served  chan error
+}
+
+func newTestServer(t *testing.T, opts ...Option) *testServer {
+	t.Helper()
+	srv, err := New(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpLis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcLis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := &testServer{Server: srv, baseURL: "http://" + httpLis.Addr().String(), served: make(chan error, 1)}
+	go func() { ts.served <- srv.Serve(httpLis, grpcLis) }()
+	t.Cleanup(func() { _ = srv.Close() })
+
+	if ts.rest, err = clamav.NewClient(ts.baseURL); err != nil {
+		t.Fatal(err)
+	}
+	if ts.grpc, err = clamavgrpc.NewClient(grpcLis.Addr().String(), clamavgrpc.WithChunkSize(16)); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ts.grpc.Close() })
+	return ts
+}
+
+func newFakeClamd(t *testing.T) *fakeclamd.Server {
+	t.Helper()
+	srv, err := fakeclamd.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// stubEngine is an Engine returning canned results.
+type stubEngine struct {
+	health  *clamav.HealthCheckResult
+	err     error
+	started chan struct{} // closed when ScanReader is called, if not nil
+	release chan struct{} // ScanReader waits for it or the end of its context, if not nil
+}
+
+func (e *stubEngine) HealthCheck(context.Context) (*clamav.HealthCheckResult, error) {
+	if e.health == nil {
+		return nil, e.err
+	}
+	return e.health, e.err
+}
+
+func (e *stubEngine) Version(context.Context) (*clamav.VersionResult, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	return &clamav.VersionResult{Version: "stub", Commit: "abc", Build: "today"}, nil
+}
+
+func (e *stubEngine) ScanReader(ctx context.Context, r io.Reader, filename string) (*clamav.ScanResult, error) {
+	if e.started != nil {
+		close(e.started)
+	}
+	if e.release != nil {
+		select {
+		case <-e.release:
+		case <-ctx.Done():
+			return nil, clamav.NewTimeoutError("request canceled", ctx.Err())
+		}
+	}
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return nil, clamav.NewValidationError("failed to read data", err)
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	return &clamav.ScanResult{Status: clamav.StatusOK, ScanTime: 0.5, Filename: filename}, nil
+}
+
+// --- New tests ---
+
+func TestNew(t *testing.T) {
+	if _, err := New(WithClamd("localhost")); !clamav.IsValidationError(err) {
+		t.Errorf("expected validation error for an invalid clamd address, got: %v", err)
+	}
+
+	s, err := New(WithMaxSize(-1), WithEngine(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.maxSize != defaultMaxSize || !s.ownsEngine {
+		t.Errorf("invalid options should be ignored: maxSize %d, ownsEngine %v", s.maxSize, s.ownsEngine)
+	}
+	_ = s.Close()
+
+	if err := s.Serve(nil, nil); err == nil {
+		t.Error("expected an error without listeners")
+	}
+}
+
+// --- REST tests ---
+
+func TestREST(t *testing.T) {
+	clamdSrv := newFakeClamd(t)
+	ts := newTestServer(t, WithClamd(clamdSrv.Addr), WithMaxSize(1024))
+	ctx := context.Background()
+	eicar := []byte(clamavtest.EICAR)
+
+	t.Run("health and version", func(t *testing.T) {
+		health, err := ts.rest.HealthCheck(ctx)
+		if err != nil || !health.Healthy {
+			t.Errorf("HealthCheck = %+v, %v", health, err)
+		}
+		version, err := ts.rest.Version(ctx)
+		if err != nil || version.Version != "1.4.1" {
+			t.Errorf("Version = %+v, %v", version, err)
+		}
+	})
+
+	t.Run("scan", func(t *testing.T) {
+		result, err := ts.rest.ScanFile(ctx, eicar, "eicar.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.IsInfected() || result.Message != clamavtest.EICARSignature || result.Filename != "eicar.com" {
+			t.Errorf("result = %+v", result)
+		}
+
+		result, err = ts.rest.ScanFile(ctx, []byte("hello"), "a.txt")
+		if err != nil || !result.IsClean() {
+			t.Errorf("result = %+v, %v", result, err)
+		}
+	})
+
+	t.Run("stream scan", func(t *testing.T) {
+		result, err := ts.rest.StreamScan(ctx, bytes.NewReader(eicar), int64(len(eicar)))
+		if err != nil || !result.IsInfected() {
+			t.Errorf("result = %+v, %v", result, err)
+		}
+
+		chunked, err := clamav.NewClient(ts.baseURL, clamav.WithChunkedStreamScan(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err = chunked.StreamScanReader(ctx, io.MultiReader(strings.NewReader("x"), bytes.NewReader(eicar)), "", clamav.UnknownSize)
+		if err != nil || !result.IsInfected() {
+			t.Errorf("chunked: result = %+v, %v", result, err)
+		}
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		big := bytes.Repeat([]byte("x"), 2048)
+		if _, err := ts.rest.ScanFile(ctx, big, "big.bin"); !clamav.IsValidationError(err) {
+			t.Errorf("scan: expected validation error, got: %v", err)
+		}
+		if _, err := ts.rest.StreamScan(ctx, bytes.NewReader(big), int64(len(big))); !clamav.IsValidationError(err) {
+			t.Errorf("stream scan: expected validation error, got: %v", err)
+		}
+
+		resp, err := http.Post(ts.baseURL+PathStreamScan, "application/octet-stream", io.MultiReader(bytes.NewReader(big)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusRequestEntityTooLarge {
+			t.Errorf("chunked: status = %d, want 413", resp.StatusCode)
+		}
+	})
+
+	t.Run("bad requests", func(t *testing.T) {
+		if _, err := ts.rest.ScanFile(ctx, nil, "empty.txt"); !clamav.IsValidationError(err) {
+			t.Errorf("empty: expected validation error, got: %v", err)
+		}
+
+		resp, err := http.Post(ts.baseURL+PathScan, "text/plain", strings.NewReader("hello"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("not multipart: status = %d, want 400", resp.StatusCode)
+		}
+
+		resp, err = http.Get(ts.baseURL + PathScan)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusMethodNotAllowed || resp.Header.Get("Allow") != http.MethodPost {
+			t.Errorf("GET: status = %d, Allow = %q", resp.StatusCode, resp.Header.Get("Allow"))
+		}
+	})
+}
+
+// --- gRPC tests ---
+
+func TestGRPC(t *testing.T) {
+	clamdSrv := newFakeClamd(t)
+	ts := newTestServer(t, WithClamd(clamdSrv.Addr), WithMaxSize(1024))
+	ctx := context.Background()
+	eicar := []byte(clamavtest.EICAR)
+
+	t.Run("health", func(t *testing.T) {
+		health, err := ts.grpc.HealthCheck(ctx)
+		if err != nil || !health.Healthy || health.Message != "ok" {
+			t.Errorf("HealthCheck = %+v, %v", health, err)
+		}
+	})
+
+	t.Run("scan file", func(t *testing.T) {
+		result, err := ts.grpc.ScanFile(ctx, eicar, "eicar.com")
+		if err != nil || !result.IsInfected() || result.Filename != "eicar.com" {
+			t.Errorf("result = %+v, %v", result, err)
+		}
+	})
+
+	t.Run("scan stream", func(t *testing.T) {
+		result, err := ts.grpc.ScanStream(ctx, append([]byte("prefix "), eicar...), "eicar.com")
+		if err != nil || !result.IsInfected() || result.Filename != "eicar.com" {
+			t.Errorf("result = %+v, %v", result, err)
+		}
+		if chunks := clamdSrv.StreamChunks(); len(chunks) == 0 {
+			t.Error("no stream reached clamd")
+		}
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		big := bytes.Repeat([]byte("x"), 2048)
+		if _, err := ts.grpc.ScanFile(ctx, big, "big.bin"); !clamav.IsValidationError(err) {
+			t.Errorf("scan file: expected validation error, got: %v", err)
+		}
+		if _, err := ts.grpc.ScanStream(ctx, big, "big.bin"); !clamav.IsValidationError(err) {
+			t.Errorf("scan stream: expected validation error, got: %v", err)
+		}
+	})
+
+	t.Run("scan multiple", func(t *testing.T) {
+		files := []clamav.FileInput{
+			{Data: []byte("hello"), Filename: "a.txt"},
+			{Data: bytes.Repeat([]byte("x"), 2048), Filename: "big.bin"},
+			{Data: eicar, Filename: "eicar.com"},
+		}
+		results, err := ts.grpc.ScanMultiple(ctx, files)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []*clamav.ScanResult
+		for r := range results {
+			got = append(got, r)
+		}
+		if len(got) != 3 {
+			t.Fatalf("got %d results, want 3", len(got))
+		}
+		if !got[0].IsClean() || got[0].Filename != "a.txt" {
+			t.Errorf("a.txt: %+v", got[0])
+		}
+		if !got[1].IsError() || got[1].Filename != "big.bin" || !strings.Contains(got[1].Message, "exceeds") {
+			t.Errorf("big.bin: %+v", got[1])
+		}
+		if !got[2].IsInfected() || got[2].Filename != "eicar.com" {
+			t.Errorf("eicar.com: %+v", got[2])
+		}
+	})
+}
+
+// --- Engine tests ---
+
+func TestEngineErrors(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name      string
+		err       error
+		restCheck func(error) bool
+		grpcCheck func(error) bool
+	}{
+		{"connection", clamav.NewConnectionError("clamd is down", nil), clamav.IsServiceError, clamav.IsConnectionError},
+		{"timeout", clamav.NewTimeoutError("clamd timed out", nil), clamav.IsTimeoutError, clamav.IsTimeoutError},
+		{"service", clamav.NewServiceError("clamd said no", 0, nil), clamav.IsServiceError, clamav.IsServiceError},
+		{"validation", clamav.NewValidationError("bad file", nil), clamav.IsValidationError, clamav.IsValidationError},
+		{"other", errors.New("boom"), clamav.IsServiceError, clamav.IsServiceError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, WithEngine(&stubEngine{err: tt.err}))
+
+			if _, err := ts.rest.ScanFile(ctx, []byte("hello"), "a.txt"); !tt.restCheck(err) {
+				t.Errorf("REST: unexpected error type: %v", err)
+			}
+			if _, err := ts.grpc.ScanFile(ctx, []byte("hello"), "a.txt"); !tt.grpcCheck(err) {
+				t.Errorf("gRPC: unexpected error type: %v", err)
+			}
+			if health, err := ts.rest.HealthCheck(ctx); err != nil || health.Healthy {
+				t.Errorf("REST HealthCheck = %+v, %v", health, err)
+			}
+			if health, err := ts.grpc.HealthCheck(ctx); err != nil || health.Healthy {
+				t.Errorf("gRPC HealthCheck = %+v, %v", health, err)
+			}
+		})
+	}
+
+	t.Run("unhealthy", func(t *testing.T) {
+		ts := newTestServer(t, WithEngine(&stubEngine{health: &clamav.HealthCheckResult{Message: "PANG"}}))
+		health, err := ts.rest.HealthCheck(ctx)
+		if err != nil || health.Healthy || health.Message != "PANG" {
+			t.Errorf("HealthCheck = %+v, %v", health, err)
+		}
+		version, err := ts.rest.Version(ctx)
+		if err != nil || version.Version != "stub" || version.Commit != "abc" {
+			t.Errorf("Version = %+v, %v", version, err)
+		}
+	})
+}
+
+// --- Shutdown tests ---
+
+func TestShutdown(t *testing.T) {
+	t.Run("waits for scans in flight", func(t *testing.T) {
+		engine := &stubEngine{started: make(chan struct{}), release: make(chan struct{})}
+		ts := newTestServer(t, WithEngine(engine))
+
+		scanned := make(chan error, 1)
+		go func() {
+			_, err := ts.rest.ScanFile(context.Background(), []byte("hello"), "a.txt")
+			scanned <- err
+		}()
+		<-engine.started
+
+		shutdown := make(chan error, 1)
+		go func() { shutdown <- ts.Shutdown(context.Background()) }()
+		select {
+		case err := <-shutdown:
+			t.Fatalf("Shutdown returned while a scan was in flight: %v", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		close(engine.release)
+		if err := <-scanned; err != nil {
+			t.Errorf("scan in flight failed: %v", err)
+		}
+		if err := <-shutdown; err != nil {
+			t.Errorf("Shutdown: %v", err)
+		}
+		if err := <-ts.served; err != nil {
+			t.Errorf("Serve: %v", err)
+		}
+		if _, err := ts.rest.HealthCheck(context.Background()); !clamav.IsConnectionError(err) {
+			t.Errorf("expected connection error after Shutdown, got: %v", err)
+		}
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		engine := &stubEngine{started: make(chan struct{}), release: make(chan struct{})}
+		defer close(engine.release)
+		ts := newTestServer(t, WithEngine(engine))
+
+		go func() { _, _ = ts.grpc.ScanFile(context.Background(), []byte("hello"), "a.txt") }()
+		<-engine.started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if err := ts.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Shutdown = %v, want context.DeadlineExceeded", err)
+		}
+	})
+
+	t.Run("closes the clamd engine", func(t *testing.T) {
+		clamdSrv := newFakeClamd(t)
+		ts := newTestServer(t, WithClamd(clamdSrv.Addr, clamd.WithSessions(1)))
+		if _, err := ts.rest.HealthCheck(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if err := ts.Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		// clamd reads END after the session was closed on the client side.
+		deadline := time.Now().Add(5 * time.Second)
+		for cmds := clamdSrv.Commands(); cmds[len(cmds)-1] != "END"; cmds = clamdSrv.Commands() {
+			if time.Now().After(deadline) {
+				t.Fatalf("commands = %q, want the session ended", cmds)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	})
+}
@@ -0,0 +1,57 @@
+package clamav
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+const defaultSpillThreshold = 8 * 1024 * 1024 // 8MB
+
+// spooledReader holds a reader's full content in memory or in a temporary file,
+// so it can be sent with a Content-Length and rewound for retries.
+type spooledReader struct {
+	io.ReadSeeker
+	size int64
+	file *os.File
+}
+
+// spool reads r to EOF. Up to threshold bytes are kept in memory; larger content
+// is spilled to a temporary file in dir (os.TempDir if empty). Close must be called
+// to remove the temporary file.
+func spool(r io.Reader, threshold int64, dir string) (*spooledReader, error) {
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, io.LimitReader(r, threshold+1))
+	if err != nil {
+		return nil, NewValidationError("failed to read data", err)
+	}
+	if n <= threshold {
+		return &spooledReader{ReadSeeker: bytes.NewReader(buf.Bytes()), size: n}, nil
+	}
+
+	f, err := os.CreateTemp(dir, "clamav-stream-*")
+	if err != nil {
+		return nil, NewValidationError("failed to create temporary file", err)
+	}
+	s := &spooledReader{ReadSeeker: f, file: f}
+
+	if s.size, err = io.Copy(f, io.MultiReader(&buf, r)); err != nil {
+		_ = s.Close()
+		return nil, NewValidationError("failed to spool data to temporary file", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		_ = s.Close()
+		return nil, NewValidationError("failed to rewind temporary file", err)
+	}
+
+	return s, nil
+}
+
+// Close removes the temporary file, if any.
+func (s *spooledReader) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	_ = s.file.Close()
+	return os.Remove(s.file.Name())
+}
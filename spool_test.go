@@ -0,0 +1,129 @@
+package clamav
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/DevHatRo/clamav-api-sdk-go/internal/testutil"
+)
+
+func TestSpool(t *testing.T) {
+	t.Run("small content stays in memory", func(t *testing.T) {
+		s, err := spool(strings.NewReader("small"), 10, t.TempDir())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer func() { _ = s.Close() }()
+
+		if s.file != nil {
+			t.Error("expected in-memory spool")
+		}
+		if s.size != 5 {
+			t.Errorf("size = %d, want 5", s.size)
+		}
+	})
+
+	t.Run("large content spills to temp file", func(t *testing.T) {
+		dir := t.TempDir()
+		data := bytes.Repeat([]byte("x"), 100)
+		s, err := spool(io.MultiReader(bytes.NewReader(data)), 10, dir)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if s.file == nil {
+			t.Fatal("expected temp file spool")
+		}
+		if s.size != 100 {
+			t.Errorf("size = %d, want 100", s.size)
+		}
+		got, _ := io.ReadAll(s)
+		if !bytes.Equal(got, data) {
+			t.Errorf("spooled %d bytes, want %d", len(got), len(data))
+		}
+
+		if err := s.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		entries, _ := os.ReadDir(dir)
+		if len(entries) != 0 {
+			t.Errorf("temp dir has %d entries after Close, want 0", len(entries))
+		}
+	})
+}
+
+func TestStreamScanUnknownSize(t *testing.T) {
+	type received struct {
+		contentLength int64
+		data          string
+	}
+	var got received
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.contentLength = r.ContentLength
+		testutil.ScanHandler(func(data []byte, filename string) (int, interface{}) {
+			got.data = string(data)
+			return http.StatusOK, testutil.CleanScanResponse()
+		})(w, r)
+	}))
+	defer srv.Close()
+
+	// pipeReader hides the length of its content.
+	pipeReader := func(s string) io.Reader { return io.MultiReader(strings.NewReader(s)) }
+
+	t.Run("spooled in memory", func(t *testing.T) {
+		client := mustNewClient(t, srv.URL)
+		defer func() { _ = client.Close() }()
+
+		if _, err := client.StreamScanReader(context.Background(), pipeReader("in memory"), "", UnknownSize); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.contentLength != int64(len("in memory")) || got.data != "in memory" {
+			t.Errorf("received %+v", got)
+		}
+	})
+
+	t.Run("spilled to temp file", func(t *testing.T) {
+		dir := t.TempDir()
+		client := mustNewClient(t, srv.URL, WithSpillThreshold(4), WithTempDir(dir))
+		defer func() { _ = client.Close() }()
+
+		if _, err := client.StreamScanReader(context.Background(), pipeReader("spilled to disk"), "", UnknownSize); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.contentLength != int64(len("spilled to disk")) || got.data != "spilled to disk" {
+			t.Errorf("received %+v", got)
+		}
+		entries, _ := os.ReadDir(dir)
+		if len(entries) != 0 {
+			t.Errorf("temp file not removed: %d entries", len(entries))
+		}
+	})
+
+	t.Run("chunked", func(t *testing.T) {
+		client := mustNewClient(t, srv.URL, WithChunkedStreamScan(true))
+		defer func() { _ = client.Close() }()
+
+		if _, err := client.StreamScanReader(context.Background(), pipeReader("chunked body"), "", UnknownSize); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.contentLength != -1 || got.data != "chunked body" {
+			t.Errorf("received %+v", got)
+		}
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		client := mustNewClient(t, srv.URL)
+		defer func() { _ = client.Close() }()
+
+		_, err := client.StreamScanReader(context.Background(), &errReader{err: io.ErrUnexpectedEOF}, "", UnknownSize)
+		if !IsValidationError(err) {
+			t.Errorf("expected validation error, got: %v", err)
+		}
+	})
+}
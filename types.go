@@ -1,25 +1,80 @@
 package clamav
 
+import "io"
+
+// ScanStatus is the verdict of a scan as reported by the server.
+type ScanStatus string
+
+// Scan statuses.
+const (
+	// StatusOK means the file is clean.
+	StatusOK ScanStatus = "OK"
+	// StatusFound means a virus was found; ScanResult.Message holds its name.
+	StatusFound ScanStatus = "FOUND"
+	// StatusError means the file could not be scanned; ScanResult.Message holds the reason.
+	StatusError ScanStatus = "ERROR"
+)
+
+// Valid reports whether s is one of the known statuses. The clients reject
+// responses with other statuses with a service error.
+func (s ScanStatus) Valid() bool {
+	switch s {
+	case StatusOK, StatusFound, StatusError:
+		return true
+	}
+	return false
+}
+
 // ScanResult represents the result of a virus scan.
 type ScanResult struct {
-	// Status is "OK" (clean), "FOUND" (infected), or "ERROR".
-	Status string `json:"status"`
+	// Status is StatusOK (clean), StatusFound (infected), or StatusError.
+	Status ScanStatus `json:"status"`
 	// Message contains the virus name if infected, error description if error, or empty if clean.
 	Message string `json:"message"`
 	// ScanTime is the scan duration in seconds.
 	ScanTime float64 `json:"time"`
 	// Filename is the scanned file's name, if provided.
 	Filename string `json:"filename,omitempty"`
+	// Cached is true when the verdict was served from a CachingScanner cache
+	// instead of being produced by a new scan.
+	Cached bool `json:"-"`
+	// TransportErr is set, with Status StatusError, when the result was produced by
+	// the client because the file could not be sent or its result not received, as in
+	// a gRPC ScanMultiple stream. It is always a *Error, and nil for errors reported
+	// by the server.
+	TransportErr error `json:"-"`
 }
 
 // IsInfected returns true if the scan found a virus.
 func (r *ScanResult) IsInfected() bool {
-	return r.Status == "FOUND"
+	return r.Status == StatusFound
 }
 
 // IsClean returns true if the file is clean.
 func (r *ScanResult) IsClean() bool {
-	return r.Status == "OK"
+	return r.Status == StatusOK
+}
+
+// IsError returns true if the file could not be scanned.
+func (r *ScanResult) IsError() bool {
+	return r.Status == StatusError
+}
+
+// Err returns nil unless the status is StatusError. It then returns TransportErr
+// if set, or else a scan error (see IsScanError) with the server's message, so
+// callers can tell a file the server failed to scan from a failed transport.
+func (r *ScanResult) Err() error {
+	if r.Status != StatusError {
+		return nil
+	}
+	if r.TransportErr != nil {
+		return r.TransportErr
+	}
+	msg := r.Message
+	if msg == "" {
+		msg = "scan failed"
+	}
+	return NewScanError(msg)
 }
 
 // HealthCheckResult represents the health status of the ClamAV service.
@@ -47,3 +102,30 @@ type FileInput struct {
 	// Filename is the name of the file.
 	Filename string
 }
+
+// ReaderInput represents a file to scan provided as a reader (used by ScanMultipleReaders).
+type ReaderInput struct {
+	// Reader is the file content.
+	Reader io.Reader
+	// Filename is the name of the file.
+	Filename string
+	// Size is the length of Reader, or UnknownSize if it is not known.
+	Size int64
+}
+
+// BatchResult is the outcome of scanning one file of a batch.
+type BatchResult struct {
+	// Index is the position of the file in the input slice.
+	Index int
+	// Filename is the name of the file from the input.
+	Filename string
+	// Result is the scan result, or nil if Err is set.
+	Result *ScanResult
+	// Err is set when the file could not be scanned, including when the server
+	// returned an ERROR verdict (see IsScanError). It is always a *Error, so the
+	// IsXxxError helpers and errors.As can be used on it.
+	Err error
+
+	// skipped is set when the file was not scanned because ctx was canceled.
+	skipped bool
+}
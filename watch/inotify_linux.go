@@ -0,0 +1,141 @@
+//go:build linux
+
+package watch
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_MODIFY | syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO
+
+// inotify is a notifier backed by Linux inotify watches on the directory and, when
+// recursive, on every subdirectory.
+type inotify struct {
+	fd        int
+	f         *os.File
+	root      string
+	recursive bool
+	skipDir   func(string) bool
+	dirs      map[int32]string // watch descriptor to directory; used by the read loop only
+
+	ch   chan string
+	done chan struct{}
+}
+
+func newInotify(root string, recursive bool, skipDir func(string) bool) (notifier, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	n := &inotify{
+		fd: fd,
+		// The descriptor is non-blocking, so reads go through the runtime poller and
+		// are interrupted by Close.
+		f:         os.NewFile(uintptr(fd), "inotify"),
+		root:      root,
+		recursive: recursive,
+		skipDir:   skipDir,
+		dirs:      map[int32]string{},
+		ch:        make(chan string),
+		done:      make(chan struct{}),
+	}
+	if err := n.addWatch(root); err != nil {
+		_ = n.f.Close()
+		return nil, err
+	}
+	if recursive {
+		n.addTree(root)
+	}
+	go n.loop()
+	return n, nil
+}
+
+func (n *inotify) events() <-chan string {
+	return n.ch
+}
+
+func (n *inotify) close() {
+	close(n.done)
+	_ = n.f.Close()
+}
+
+func (n *inotify) addWatch(dir string) error {
+	wd, err := syscall.InotifyAddWatch(n.fd, dir, inotifyMask)
+	if err != nil {
+		return os.NewSyscallError("inotify_add_watch", err)
+	}
+	n.dirs[int32(wd)] = dir
+	return nil
+}
+
+// addTree watches the subdirectories of dir. Directories that cannot be watched,
+// e.g. for lack of permission, are ignored.
+func (n *inotify) addTree(dir string) {
+	_ = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() || p == dir {
+			return nil
+		}
+		if n.skipDir(p) {
+			return fs.SkipDir
+		}
+		_ = n.addWatch(p)
+		return nil
+	})
+}
+
+func (n *inotify) loop() {
+	defer close(n.ch)
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+
+	for {
+		size, err := n.f.Read(buf)
+		if err != nil {
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= size; {
+			ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			offset = nameStart + int(ev.Len)
+			name := strings.TrimRight(string(buf[nameStart:offset]), "\x00")
+
+			var path string
+			switch {
+			case ev.Mask&syscall.IN_Q_OVERFLOW != 0:
+				// Events were lost; have the whole tree checked.
+				path = n.root
+			case ev.Mask&syscall.IN_IGNORED != 0:
+				delete(n.dirs, ev.Wd)
+				continue
+			default:
+				dir, ok := n.dirs[ev.Wd]
+				if !ok || name == "" {
+					continue
+				}
+				path = filepath.Join(dir, name)
+				if ev.Mask&syscall.IN_ISDIR != 0 {
+					if !n.recursive || ev.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) == 0 || n.skipDir(path) {
+						continue
+					}
+					// Watch the new directory before its files are listed, so that no
+					// file created in between is missed.
+					if n.addWatch(path) != nil {
+						continue
+					}
+					n.addTree(path)
+				}
+			}
+
+			select {
+			case n.ch <- path:
+			case <-n.done:
+				return
+			}
+		}
+	}
+}
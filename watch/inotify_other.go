@@ -0,0 +1,10 @@
+//go:build !linux
+
+package watch
+
+import "errors"
+
+// newInotify is only implemented on Linux; elsewhere the watcher always polls.
+func newInotify(string, bool, func(string) bool) (notifier, error) {
+	return nil, errors.New("inotify is only available on Linux")
+}
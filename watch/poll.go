@@ -0,0 +1,82 @@
+package watch
+
+import (
+	"io/fs"
+	"time"
+)
+
+// poller is a notifier that walks the directory at a fixed interval and reports files
+// that are new or whose size or modification time changed since the previous walk.
+type poller struct {
+	root      string
+	recursive bool
+	skipDir   func(string) bool
+	stamps    map[string]fileStamp
+
+	ch   chan string
+	done chan struct{}
+}
+
+func newPoller(root string, recursive bool, skipDir func(string) bool, interval time.Duration) *poller {
+	p := &poller{
+		root:      root,
+		recursive: recursive,
+		skipDir:   skipDir,
+		ch:        make(chan string),
+		done:      make(chan struct{}),
+	}
+	p.stamps = p.walk(nil)
+	go p.loop(interval)
+	return p
+}
+
+func (p *poller) events() <-chan string {
+	return p.ch
+}
+
+func (p *poller) close() {
+	close(p.done)
+}
+
+func (p *poller) loop(interval time.Duration) {
+	defer close(p.ch)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+
+		var changed []string
+		stamps := p.walk(func(path string, stamp fileStamp) {
+			if old, ok := p.stamps[path]; !ok || old != stamp {
+				changed = append(changed, path)
+			}
+		})
+		p.stamps = stamps
+
+		for _, path := range changed {
+			select {
+			case p.ch <- path:
+			case <-p.done:
+				return
+			}
+		}
+	}
+}
+
+// walk returns the stamps of the files under the root, calling fn, if non-nil, for each.
+func (p *poller) walk(fn func(string, fileStamp)) map[string]fileStamp {
+	stamps := make(map[string]fileStamp, len(p.stamps))
+	walkFiles(p.root, p.root, p.recursive, p.skipDir, func(path string, info fs.FileInfo) {
+		stamp := stampOf(info)
+		stamps[path] = stamp
+		if fn != nil {
+			fn(path, stamp)
+		}
+	})
+	return stamps
+}
@@ -0,0 +1,365 @@
+// Package watch scans files as they land in a directory, such as an SFTP drop directory.
+//
+// A Watcher is notified of new and modified files by inotify on Linux, and by polling
+// the directory elsewhere or when inotify is unavailable. Writes are debounced: a file
+// is scanned once its size and modification time have not changed for
+// Options.StableFor, so files still being uploaded are not scanned half-written.
+//
+//	w, err := watch.New(client, "/srv/sftp/incoming", &watch.Options{Recursive: true})
+//	...
+//	err = w.Run(ctx, func(r *clamav.FileResult) {
+//	    if r.Result != nil && r.Result.IsInfected() {
+//	        log.Printf("%s: %s", r.Path, r.Result.Message)
+//	    }
+//	})
+//
+// Set Options.OnInfected to a quarantine.Store's OnInfected method to move infected
+// files aside as they are found.
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	clamav "github.com/DevHatRo/clamav-api-sdk-go"
+	"github.com/DevHatRo/clamav-api-sdk-go/internal/pathmatch"
+)
+
+const (
+	defaultStableFor    = 2 * time.Second
+	defaultPollInterval = time.Second
+	defaultConcurrency  = 4
+
+	minCheckInterval = 10 * time.Millisecond
+)
+
+// Options configures a Watcher. The zero value watches the top level of the directory
+// with inotify where available, and scans files once they have been stable for 2s.
+type Options struct {
+	// Recursive also watches subdirectories, including ones created later.
+	Recursive bool
+	// Include, if non-empty, limits scanning to files matching at least one pattern.
+	// Patterns use path.Match syntax and are matched against both the slash-separated
+	// path relative to the watched directory and the base name.
+	Include []string
+	// Exclude skips files and directories matching any pattern (same syntax as Include),
+	// e.g. "*.filepart" for uploads that are renamed once complete.
+	Exclude []string
+	// StableFor is how long a file must stay unchanged before it is scanned (default: 2s).
+	StableFor time.Duration
+	// PollInterval is the interval between directory walks when polling (default: 1s).
+	PollInterval time.Duration
+	// Poll disables inotify and always polls, e.g. for network file systems where
+	// inotify does not see changes made by other hosts.
+	Poll bool
+	// ScanExisting also scans the files present when the watcher starts.
+	ScanExisting bool
+	// Concurrency is the number of files scanned at once (default: 4).
+	Concurrency int
+	// OnInfected, if set, is called for each infected file after it has been scanned,
+	// before the result is delivered. It may be called concurrently. Its error is
+	// recorded in FileResult.HookErr.
+	OnInfected func(ctx context.Context, r *clamav.FileResult) error
+}
+
+// Watcher scans the new and modified files of a directory.
+type Watcher struct {
+	s    clamav.Scanner
+	dir  string
+	opts Options
+
+	err error // set by Results before its channel is closed
+}
+
+// notifier reports paths of files and directories that may have been created or changed.
+// A directory path means that its files should be checked, e.g. after it was created.
+type notifier interface {
+	events() <-chan string
+	close()
+}
+
+// New returns a Watcher for dir. Nothing is watched until Run or Results is called.
+func New(s clamav.Scanner, dir string, opts *Options) (*Watcher, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, clamav.NewValidationError(fmt.Sprintf("failed to stat directory: %s", dir), err)
+	}
+	if !info.IsDir() {
+		return nil, clamav.NewValidationError(fmt.Sprintf("not a directory: %s", dir), nil)
+	}
+
+	w := &Watcher{s: s, dir: filepath.Clean(dir)}
+	if opts != nil {
+		w.opts = *opts
+	}
+	if pattern, err := pathmatch.Validate(append(append([]string(nil), w.opts.Include...), w.opts.Exclude...)); err != nil {
+		return nil, clamav.NewValidationError(fmt.Sprintf("invalid pattern: %q", pattern), err)
+	}
+	if w.opts.StableFor <= 0 {
+		w.opts.StableFor = defaultStableFor
+	}
+	if w.opts.PollInterval <= 0 {
+		w.opts.PollInterval = defaultPollInterval
+	}
+	if w.opts.Concurrency <= 0 {
+		w.opts.Concurrency = defaultConcurrency
+	}
+	return w, nil
+}
+
+// pendingFile is a file waiting to become stable.
+type pendingFile struct {
+	stamp   fileStamp
+	changed time.Time
+}
+
+// fileStamp identifies a version of a file.
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+func stampOf(info fs.FileInfo) fileStamp {
+	return fileStamp{size: info.Size(), modTime: info.ModTime()}
+}
+
+// Run watches the directory until ctx is done, calling fn with the result of every
+// file scanned. fn is called from a single goroutine. Run returns nil when ctx is done,
+// and an error if watching fails; failed scans are reported through fn.
+func (w *Watcher) Run(ctx context.Context, fn func(*clamav.FileResult)) error {
+	n := w.newNotifier()
+	defer n.close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan string)
+	results := make(chan *clamav.FileResult)
+	var wg sync.WaitGroup
+	for i := 0; i < w.opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range jobs {
+				results <- w.scan(ctx, p)
+			}
+		}()
+	}
+	defer func() {
+		// Stop the workers, discarding the results of scans interrupted by cancel.
+		cancel()
+		close(jobs)
+		go func() {
+			wg.Wait()
+			close(results)
+		}()
+		for range results {
+		}
+	}()
+
+	pending := map[string]*pendingFile{}
+	busy := map[string]bool{} // queued or being scanned
+	var queue []string
+
+	if w.opts.ScanExisting {
+		w.track(pending, w.dir, time.Now())
+	}
+
+	check := time.NewTicker(max(w.opts.StableFor/4, minCheckInterval))
+	defer check.Stop()
+
+	for {
+		var next chan<- string
+		if len(queue) > 0 {
+			next = jobs
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case p, ok := <-n.events():
+			if !ok {
+				return clamav.NewValidationError(fmt.Sprintf("stopped watching directory: %s", w.dir), nil)
+			}
+			w.track(pending, p, time.Now())
+		case now := <-check.C:
+			for p, f := range pending {
+				if busy[p] {
+					continue
+				}
+				info, err := os.Lstat(p)
+				switch {
+				case err != nil || !info.Mode().IsRegular():
+					delete(pending, p)
+				case stampOf(info) != f.stamp:
+					f.stamp, f.changed = stampOf(info), now
+				case now.Sub(f.changed) >= w.opts.StableFor:
+					delete(pending, p)
+					busy[p] = true
+					queue = append(queue, p)
+				}
+			}
+		case next <- queueHead(queue):
+			queue = queue[1:]
+		case r := <-results:
+			delete(busy, r.Path)
+			if ctx.Err() == nil {
+				fn(r)
+			}
+		}
+	}
+}
+
+func queueHead(queue []string) string {
+	if len(queue) == 0 {
+		return ""
+	}
+	return queue[0]
+}
+
+// Results runs the watcher in a new goroutine until ctx is done and returns a channel
+// of its results, which is closed when the watcher stops. The channel must be drained:
+// scanning pauses while a result is not received. Err reports why the watcher stopped.
+func (w *Watcher) Results(ctx context.Context) <-chan *clamav.FileResult {
+	ch := make(chan *clamav.FileResult)
+	go func() {
+		defer close(ch)
+		w.err = w.Run(ctx, func(r *clamav.FileResult) {
+			select {
+			case ch <- r:
+			case <-ctx.Done():
+			}
+		})
+	}()
+	return ch
+}
+
+// Err returns the error that stopped a watcher started with Results. It must only be
+// called after the results channel has been closed.
+func (w *Watcher) Err() error {
+	return w.err
+}
+
+// newNotifier starts inotify, or polling if it is disabled or unavailable.
+func (w *Watcher) newNotifier() notifier {
+	if !w.opts.Poll {
+		if n, err := newInotify(w.dir, w.opts.Recursive, w.skipDir); err == nil {
+			return n
+		}
+	}
+	return newPoller(w.dir, w.opts.Recursive, w.skipDir, w.opts.PollInterval)
+}
+
+// track records a change to p. Directories are expanded into their files.
+func (w *Watcher) track(pending map[string]*pendingFile, p string, now time.Time) {
+	info, err := os.Lstat(p)
+	if err != nil {
+		return
+	}
+	if info.IsDir() {
+		walkFiles(p, w.dir, w.opts.Recursive, w.skipDir, func(file string, info fs.FileInfo) {
+			w.trackFile(pending, file, info, now)
+		})
+		return
+	}
+	if info.Mode().IsRegular() {
+		w.trackFile(pending, p, info, now)
+	}
+}
+
+func (w *Watcher) trackFile(pending map[string]*pendingFile, p string, info fs.FileInfo, now time.Time) {
+	if !w.wanted(p) {
+		return
+	}
+	if f, ok := pending[p]; ok {
+		f.stamp, f.changed = stampOf(info), now
+		return
+	}
+	pending[p] = &pendingFile{stamp: stampOf(info), changed: now}
+}
+
+// wanted applies the include and exclude patterns to a file.
+func (w *Watcher) wanted(p string) bool {
+	rel, ok := w.rel(p)
+	if !ok || pathmatch.Any(w.opts.Exclude, rel) {
+		return false
+	}
+	return len(w.opts.Include) == 0 || pathmatch.Any(w.opts.Include, rel)
+}
+
+// skipDir reports whether a subdirectory is excluded from watching.
+func (w *Watcher) skipDir(p string) bool {
+	rel, ok := w.rel(p)
+	return !ok || pathmatch.Any(w.opts.Exclude, rel)
+}
+
+// rel returns p relative to the watched directory, slash-separated.
+func (w *Watcher) rel(p string) (string, bool) {
+	rel, err := filepath.Rel(w.dir, p)
+	if err != nil {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// scan opens and scans one file.
+func (w *Watcher) scan(ctx context.Context, p string) *clamav.FileResult {
+	res := &clamav.FileResult{Path: p}
+	f, err := os.Open(p)
+	if err != nil {
+		res.Err = clamav.NewValidationError(fmt.Sprintf("failed to open file: %s", p), err)
+		return res
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		res.Err = clamav.NewValidationError(fmt.Sprintf("failed to stat file: %s", p), err)
+		return res
+	}
+	res.Size = info.Size()
+
+	res.Result, err = w.s.StreamScanReader(ctx, f, filepath.Base(p), info.Size())
+	_ = f.Close()
+	if err != nil {
+		var e *clamav.Error
+		if !errors.As(err, &e) {
+			err = clamav.NewServiceError("scan failed", 0, err)
+		}
+		res.Result, res.Err = nil, err
+		return res
+	}
+
+	if w.opts.OnInfected != nil && res.Result.IsInfected() {
+		res.HookErr = w.opts.OnInfected(ctx, res)
+	}
+	return res
+}
+
+// walkFiles calls fn for the regular files under dir, descending into subdirectories
+// of root only if recursive, and never into those for which skipDir returns true.
+func walkFiles(dir, root string, recursive bool, skipDir func(string) bool, fn func(string, fs.FileInfo)) {
+	_ = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if p != root && (!recursive || skipDir(p)) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			fn(p, info)
+		}
+		return nil
+	})
+}
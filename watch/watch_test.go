@@ -0,0 +1,256 @@
+package watch
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	clamav "github.com/DevHatRo/clamav-api-sdk-go"
+)
+
+// stubScanner reports files containing "EICAR" as infected and records what it scanned.
+type stubScanner struct {
+	clamav.Scanner
+
+	mu      sync.Mutex
+	scanned map[string][]string // filename to the contents of each scan
+}
+
+func (s *stubScanner) StreamScanReader(_ context.Context, r io.Reader, filename string, _ int64) (*clamav.ScanResult, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	if s.scanned == nil {
+		s.scanned = map[string][]string{}
+	}
+	s.scanned[filename] = append(s.scanned[filename], string(data))
+	s.mu.Unlock()
+
+	if strings.Contains(string(data), "EICAR") {
+		return &clamav.ScanResult{Status: "FOUND", Message: "Eicar-Test-Signature", Filename: filename}, nil
+	}
+	return &clamav.ScanResult{Status: "OK", Filename: filename}, nil
+}
+
+func (s *stubScanner) scans(filename string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.scanned[filename]...)
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// startWatcher runs a watcher on dir and returns its results channel.
+func startWatcher(t *testing.T, s clamav.Scanner, dir string, opts *Options) <-chan *clamav.FileResult {
+	t.Helper()
+	opts.StableFor = 100 * time.Millisecond
+	opts.PollInterval = 20 * time.Millisecond
+	w, err := New(s, dir, opts)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	results := w.Results(ctx)
+	t.Cleanup(func() {
+		cancel()
+		for range results {
+		}
+		if err := w.Err(); err != nil {
+			t.Errorf("watcher stopped with error: %v", err)
+		}
+	})
+	// Give the watcher time to register, and the poller to take its first snapshot.
+	time.Sleep(50 * time.Millisecond)
+	return results
+}
+
+// next returns the next result, failing the test if none arrives in time.
+func next(t *testing.T, results <-chan *clamav.FileResult) *clamav.FileResult {
+	t.Helper()
+	select {
+	case r := <-results:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a scan result")
+		return nil
+	}
+}
+
+// expectNone fails the test if a result arrives within d.
+func expectNone(t *testing.T, results <-chan *clamav.FileResult, d time.Duration) {
+	t.Helper()
+	select {
+	case r := <-results:
+		t.Errorf("unexpected result for %s", r.Path)
+	case <-time.After(d):
+	}
+}
+
+// backends runs a test with inotify (where available) and with polling.
+func backends(t *testing.T, test func(t *testing.T, poll bool)) {
+	t.Run("notify", func(t *testing.T) { test(t, false) })
+	t.Run("poll", func(t *testing.T) { test(t, true) })
+}
+
+// --- Watcher tests ---
+
+func TestWatchNewFiles(t *testing.T) {
+	backends(t, func(t *testing.T, poll bool) {
+		dir := t.TempDir()
+		var hooked []string
+		var mu sync.Mutex
+		opts := &Options{Poll: poll, OnInfected: func(_ context.Context, r *clamav.FileResult) error {
+			mu.Lock()
+			defer mu.Unlock()
+			hooked = append(hooked, r.Path)
+			return nil
+		}}
+		results := startWatcher(t, &stubScanner{}, dir, opts)
+
+		writeFile(t, filepath.Join(dir, "clean.txt"), "hello")
+		r := next(t, results)
+		if r.Path != filepath.Join(dir, "clean.txt") || r.Err != nil || !r.Result.IsClean() || r.Size != 5 {
+			t.Errorf("result = %+v", r)
+		}
+
+		eicar := filepath.Join(dir, "eicar.com")
+		writeFile(t, eicar, "EICAR")
+		r = next(t, results)
+		if r.Path != eicar || !r.Result.IsInfected() {
+			t.Errorf("result = %+v", r)
+		}
+		mu.Lock()
+		if len(hooked) != 1 || hooked[0] != eicar {
+			t.Errorf("OnInfected called for %v, want [%s]", hooked, eicar)
+		}
+		mu.Unlock()
+
+		expectNone(t, results, 300*time.Millisecond)
+	})
+}
+
+func TestWatchDebounce(t *testing.T) {
+	backends(t, func(t *testing.T, poll bool) {
+		dir := t.TempDir()
+		s := &stubScanner{}
+		results := startWatcher(t, s, dir, &Options{Poll: poll})
+
+		p := filepath.Join(dir, "upload.bin")
+		f, err := os.Create(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, chunk := range []string{"part1 ", "part2 ", "EICAR"} {
+			if _, err := f.WriteString(chunk); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(40 * time.Millisecond)
+		}
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		r := next(t, results)
+		if !r.Result.IsInfected() {
+			t.Errorf("result = %+v", r.Result)
+		}
+		expectNone(t, results, 300*time.Millisecond)
+		if scans := s.scans("upload.bin"); len(scans) != 1 || scans[0] != "part1 part2 EICAR" {
+			t.Errorf("scans = %q, want one scan of the complete file", scans)
+		}
+	})
+}
+
+func TestWatchRecursive(t *testing.T) {
+	backends(t, func(t *testing.T, poll bool) {
+		dir := t.TempDir()
+		opts := &Options{Poll: poll, Recursive: true, Exclude: []string{"*.filepart", "tmp"}}
+		results := startWatcher(t, &stubScanner{}, dir, opts)
+
+		for _, sub := range []string{"a/b", "tmp"} {
+			if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		writeFile(t, filepath.Join(dir, "tmp", "skipped.txt"), "x")
+		writeFile(t, filepath.Join(dir, "a", "b", "upload.filepart"), "x")
+		writeFile(t, filepath.Join(dir, "a", "b", "nested.txt"), "x")
+
+		r := next(t, results)
+		if r.Path != filepath.Join(dir, "a", "b", "nested.txt") {
+			t.Errorf("scanned %s, want the nested file", r.Path)
+		}
+		expectNone(t, results, 300*time.Millisecond)
+	})
+}
+
+func TestWatchScanExisting(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "existing.txt"), "hello")
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "sub", "nested.txt"), "hello")
+
+	results := startWatcher(t, &stubScanner{}, dir, &Options{ScanExisting: true})
+	r := next(t, results)
+	if r.Path != filepath.Join(dir, "existing.txt") {
+		t.Errorf("scanned %s, want only the top-level file", r.Path)
+	}
+	expectNone(t, results, 300*time.Millisecond)
+}
+
+func TestNewErrors(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.txt")
+	writeFile(t, file, "x")
+
+	tests := []struct {
+		name string
+		dir  string
+		opts *Options
+	}{
+		{"missing directory", filepath.Join(dir, "missing"), nil},
+		{"not a directory", file, nil},
+		{"invalid pattern", dir, &Options{Exclude: []string{"["}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(&stubScanner{}, tt.dir, tt.opts); !clamav.IsValidationError(err) {
+				t.Errorf("expected validation error, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestRunStopsOnCancel(t *testing.T) {
+	w, err := New(&stubScanner{}, t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- w.Run(ctx, func(*clamav.FileResult) {}) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+}